@@ -0,0 +1,49 @@
+// Command audit-restore loads an audit log archive back into the database.
+//
+//	audit-restore -manifest ./data/audit-archive/audit-000000000001-000000001000.manifest.json
+//
+// The archive file must sit next to its manifest. Its checksum and every
+// entry's hash and link are checked before anything is loaded.
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/FRFebi/bot-management-backend/internal/audit"
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	manifest := flag.String("manifest", "", "path to the manifest of the archive to restore")
+	flag.Parse()
+
+	if *manifest == "" {
+		flag.Usage()
+		log.Fatal("-manifest is required")
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+
+	cfg := config.New()
+
+	if err := database.Connect(cfg); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	if err := database.Migrate(); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	loaded, err := audit.Restore(database.DB, *manifest)
+	if err != nil {
+		log.Fatalf("Failed to restore archive: %v", err)
+	}
+
+	log.Printf("Restored %d audit log entries from %s", loaded, *manifest)
+}
@@ -3,18 +3,39 @@ package main
 import (
 	"fmt"
 	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/FRFebi/bot-management-backend/internal/artifacts"
+	"github.com/FRFebi/bot-management-backend/internal/audit"
+	"github.com/FRFebi/bot-management-backend/internal/auditsink"
+	"github.com/FRFebi/bot-management-backend/internal/authn"
 	"github.com/FRFebi/bot-management-backend/internal/config"
 	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/deploy"
 	"github.com/FRFebi/bot-management-backend/internal/handlers"
+	"github.com/FRFebi/bot-management-backend/internal/loginguard"
 	"github.com/FRFebi/bot-management-backend/internal/middleware"
+	"github.com/FRFebi/bot-management-backend/internal/notifier"
+	"github.com/FRFebi/bot-management-backend/internal/ratelimit"
+	"github.com/FRFebi/bot-management-backend/internal/signingkeys"
+	pkgauth "github.com/FRFebi/bot-management-backend/pkg/auth"
 	"github.com/FRFebi/bot-management-backend/pkg/logger"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/joho/godotenv"
 )
 
+// bodyLimit caps request bodies on every route except artifact uploads,
+// which check their own size against the artifact limit.
+const bodyLimit = 4 << 20
+
+var artifactUploadPath = regexp.MustCompile(`(?i)^/api/v1/bots/[^/]+/artifacts/?$`)
+
 func main() {
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
@@ -27,6 +48,11 @@ func main() {
 	// Initialize logger
 	log := logger.New()
 
+	// Refuse to start with an invalid or, in production, insecure config
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+
 	// Connect to database
 	if err := database.Connect(cfg); err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
@@ -39,6 +65,17 @@ func main() {
 	}
 	log.Info("Database migrated successfully")
 
+	// The development seed accounts have public passwords
+	if cfg.Server.IsProduction() {
+		emails, err := database.SeededCredentialsInUse()
+		if err != nil {
+			log.Fatalf("Failed to check for seeded accounts: %v", err)
+		}
+		if len(emails) > 0 {
+			log.Fatalf("Refusing to start: seeded accounts still use their default passwords: %s", strings.Join(emails, ", "))
+		}
+	}
+
 	// Seed database in development
 	if cfg.Server.Env == "development" {
 		if err := database.Seed(); err != nil {
@@ -55,19 +92,45 @@ func main() {
 		}
 	}()
 
-	// Create Fiber app
+	// Create Fiber app. Bodies above the limit are streamed rather than
+	// buffered, so that artifact uploads can be larger; every other route
+	// rejects them.
 	app := fiber.New(fiber.Config{
-		AppName: "Bot Management Backend",
+		AppName:                      "Bot Management Backend",
+		BodyLimit:                    bodyLimit,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
 	})
 
 	// Add middleware
 	app.Use(recover.New())
+	app.Use(middleware.BodyLimit(bodyLimit, func(c *fiber.Ctx) bool {
+		return c.Method() == fiber.MethodPost && artifactUploadPath.MatchString(c.Path())
+	}))
+	app.Use(requestid.New())
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
+		AllowOrigins:  strings.Join(cfg.Server.CORSOrigins, ","),
+		AllowMethods:  "GET,POST,PUT,DELETE,OPTIONS",
+		AllowHeaders:  "Origin,Content-Type,Accept,Authorization,X-Request-ID",
+		ExposeHeaders: "X-Request-ID,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,Retry-After",
+		// The single sign-on state cookie needs credentialed requests, which
+		// browsers refuse for a wildcard origin.
+		AllowCredentials: !slices.Contains(cfg.Server.CORSOrigins, "*"),
 	}))
 
+	// Load token signing keys and keep rotating them
+	signingKeys, err := signingkeys.New(cfg.JWT)
+	if err != nil {
+		log.Fatalf("Failed to initialize signing keys: %v", err)
+	}
+	if err := signingKeys.Start(); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+	jwtManager := pkgauth.NewJWTManager(signingKeys.KeySet(), cfg.JWT.Issuer, cfg.JWT.Audience, cfg.JWT.ExpiryHours)
+
+	// Public keys for verifying our tokens in other services
+	app.Get("/.well-known/jwks.json", handlers.NewJWKSHandler(signingKeys.KeySet()).GetJWKS)
+
 	// Health check endpoint
 	app.Get("/health", func(c *fiber.Ctx) error {
 		dbStatus := "ok"
@@ -94,42 +157,200 @@ func main() {
 		})
 	})
 
+	// Initialize notifier used for password resets and deployment outcomes
+	notify, err := notifier.New(cfg.Notifier)
+	if err != nil {
+		log.Fatalf("Failed to initialize notifier: %v", err)
+	}
+
+	// Initialize login backends
+	authenticator, err := authn.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize authentication: %v", err)
+	}
+
+	loginGuard := loginguard.New(cfg.Login)
+
+	// Sign checkpoints of the audit hash chain periodically
+	trustedKeys, err := audit.LoadTrustedKeys(cfg.Audit.TrustedKeysFile)
+	if err != nil {
+		log.Fatalf("Failed to load trusted audit keys: %v", err)
+	}
+	checkpointer := audit.NewCheckpointer(signingKeys.KeySet(), trustedKeys, cfg.JWT.Issuer,
+		time.Duration(cfg.Audit.CheckpointIntervalMinutes)*time.Minute)
+	checkpointer.Start()
+
+	// Retry audit entries that were queued instead of failing their request.
+	// The worker always runs so entries queued before a switch back to the
+	// fail mode are still delivered.
+	audit.Configure(cfg.Audit)
+	audit.StartRetryWorker(time.Duration(cfg.Audit.RetryIntervalSeconds) * time.Second)
+
+	// Move audit entries past the retention period to archive files
+	audit.NewArchiver(cfg.Audit).Start()
+
+	// Forward committed audit entries to external sinks such as a SIEM
+	sinks, err := auditsink.New(cfg.Audit)
+	if err != nil {
+		log.Fatalf("Failed to initialize audit sinks: %v", err)
+	}
+	if len(sinks) > 0 {
+		forwarder := auditsink.NewForwarder(cfg.Audit, sinks)
+		if err := forwarder.Start(); err != nil {
+			log.Fatalf("Failed to start audit forwarding: %v", err)
+		}
+		defer forwarder.Close()
+	}
+
+	// Watch new deployments and roll back the ones that fail their health
+	// checks
+	probeTargets, err := deploy.NewProbeTargets(cfg.Deploy.HealthProbeAllowedNetworks)
+	if err != nil {
+		log.Fatalf("Failed to configure health probes: %v", err)
+	}
+	deploy.NewGate(cfg.Deploy, probeTargets, notify).Start()
+
+	artifactStore, err := artifacts.New(cfg.Artifacts)
+	if err != nil {
+		log.Fatalf("Failed to initialize artifact store: %v", err)
+	}
+
+	// Initialize rate limits per route group
+	var limitStore ratelimit.Store
+	if cfg.RateLimit.Enabled {
+		limitStore, err = ratelimit.New(cfg.RateLimit, cfg.Redis)
+		if err != nil {
+			log.Fatalf("Failed to initialize rate limiting: %v", err)
+		}
+	}
+	authLimit := middleware.RateLimit(limitStore, ratelimit.NewPolicy("auth", cfg.RateLimit.Auth))
+	apiLimit := middleware.RateLimitByMethod(limitStore,
+		ratelimit.NewPolicy("read", cfg.RateLimit.Read),
+		ratelimit.NewPolicy("write", cfg.RateLimit.Write))
+	lifecycleLimit := middleware.RateLimit(limitStore, ratelimit.NewPolicy("lifecycle", cfg.RateLimit.Lifecycle))
+
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(cfg)
-	botHandler := handlers.NewBotHandler()
-	auditHandler := handlers.NewAuditHandler()
+	authHandler := handlers.NewAuthHandler(cfg, jwtManager, authenticator, loginGuard)
+	botHandler := handlers.NewBotHandler(probeTargets)
+	auditHandler := handlers.NewAuditHandler(checkpointer)
+	legalHoldHandler := handlers.NewLegalHoldHandler()
+	botConfigHandler := handlers.NewBotConfigHandler()
+	deploymentHandler := handlers.NewDeploymentHandler()
+	environmentHandler := handlers.NewEnvironmentHandler(cfg)
+	trustedKeyHandler := handlers.NewTrustedKeyHandler()
+	artifactHandler := handlers.NewArtifactHandler(artifactStore, int64(cfg.Artifacts.MaxSizeMB)<<20)
+	aclHandler := handlers.NewACLHandler()
+	groupHandler := handlers.NewGroupHandler()
+	orgHandler := handlers.NewOrganizationHandler()
+	userHandler := handlers.NewUserHandler(cfg, loginGuard)
+	inviteHandler := handlers.NewInviteHandler(cfg)
+	passwordHandler := handlers.NewPasswordHandler(cfg, jwtManager, notify)
+	mfaHandler := handlers.NewMFAHandler(cfg, jwtManager, loginGuard)
+	oidcHandler := handlers.NewOIDCHandler(cfg, authHandler)
 
 	// Auth routes (public)
-	auth := api.Group("/auth")
+	auth := api.Group("/auth", authLimit)
 	auth.Post("/login", authHandler.Login)
 	auth.Post("/register", authHandler.Register)
 	auth.Post("/refresh", authHandler.RefreshToken)
 	auth.Post("/logout", authHandler.Logout)
+	auth.Post("/forgot-password", passwordHandler.ForgotPassword)
+	auth.Post("/reset-password", passwordHandler.ResetPassword)
+	auth.Post("/mfa/verify", mfaHandler.Verify)
+
+	// Single sign-on routes (public)
+	if cfg.OIDC.Enabled {
+		auth.Get("/oidc/login", oidcHandler.Login)
+		auth.Post("/oidc/callback", oidcHandler.Callback)
+	}
+
+	// MFA enrollment routes also accept the enrollment token issued by login
+	// when the user's role requires MFA
+	mfa := api.Group("/auth/mfa", middleware.AuthMiddleware(jwtManager, pkgauth.PurposeMFAEnrollment), apiLimit)
+	mfa.Post("/enroll", mfaHandler.Enroll)
+	mfa.Post("/enroll/confirm", mfaHandler.ConfirmEnrollment)
+	mfa.Post("/disable", mfaHandler.Disable)
+	mfa.Post("/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
 
 	// Protected routes
-	protected := api.Group("", middleware.AuthMiddleware(cfg))
+	protected := api.Group("", middleware.AuthMiddleware(jwtManager), apiLimit)
 	protected.Get("/me", authHandler.Me)
+	protected.Post("/auth/switch-org", authHandler.SwitchOrganization)
+	protected.Post("/auth/change-password", passwordHandler.ChangePassword)
+	protected.Get("/orgs", orgHandler.GetOrganizations)
+	protected.Post("/orgs", middleware.RequireSuperAdmin(), orgHandler.CreateOrganization)
 
 	// Bot routes (protected)
-	bots := api.Group("/bots", middleware.AuthMiddleware(cfg))
+	bots := api.Group("/bots", middleware.AuthMiddleware(jwtManager), apiLimit)
 	bots.Get("/", botHandler.GetBots)
 	bots.Get("/:id", botHandler.GetBot)
 	bots.Get("/:id/status", botHandler.GetBotStatus)
+	bots.Get("/:id/config/revisions", botConfigHandler.GetRevisions)
+	bots.Get("/:id/config/revisions/:revision", botConfigHandler.GetRevision)
+	bots.Get("/:id/config/diff", botConfigHandler.DiffRevisions)
+	bots.Get("/:id/deployments", deploymentHandler.GetDeployments)
+	bots.Get("/:id/replicas", deploymentHandler.GetReplicas)
+	bots.Get("/:id/environments", environmentHandler.GetEnvironments)
+	bots.Get("/:id/artifacts", artifactHandler.GetArtifacts)
 
 	// Bot management routes (admin only)
 	bots.Post("/", middleware.RequireRole("admin"), botHandler.CreateBot)
 	bots.Put("/:id", middleware.RequireRole("admin"), botHandler.UpdateBot)
 	bots.Delete("/:id", middleware.RequireRole("admin"), botHandler.DeleteBot)
-	bots.Post("/:id/start", middleware.RequireRole("admin"), botHandler.StartBot)
-	bots.Post("/:id/stop", middleware.RequireRole("admin"), botHandler.StopBot)
-	bots.Post("/:id/restart", middleware.RequireRole("admin"), botHandler.RestartBot)
-	bots.Post("/:id/deploy", middleware.RequireRole("admin"), botHandler.DeployBot)
+	bots.Post("/:id/start", middleware.RequireRole("admin"), lifecycleLimit, botHandler.StartBot)
+	bots.Post("/:id/stop", middleware.RequireRole("admin"), lifecycleLimit, botHandler.StopBot)
+	bots.Post("/:id/restart", middleware.RequireRole("admin"), lifecycleLimit, botHandler.RestartBot)
+	bots.Post("/:id/artifacts", middleware.RequireRole("admin"), artifactHandler.UploadArtifact)
+	bots.Get("/:id/artifacts/:digest", middleware.RequireRole("admin"), artifactHandler.DownloadArtifact)
+	bots.Post("/:id/deploy", middleware.RequireRole("admin"), lifecycleLimit, botHandler.DeployBot)
+	bots.Post("/:id/promote", middleware.RequireRole("admin"), lifecycleLimit, environmentHandler.Promote)
+	bots.Put("/:id/environments/:env", middleware.RequireRole("admin"), environmentHandler.UpdateEnvironment)
+	bots.Post("/:id/config/rollback", middleware.RequireRole("admin"), botConfigHandler.RollbackConfig)
+	bots.Post("/:id/rollback", middleware.RequireRole("admin"), lifecycleLimit, deploymentHandler.Rollback)
+
+	// Bot access control routes (admin only, and the caller must manage the bot)
+	bots.Get("/:id/acl", middleware.RequireRole("admin"), aclHandler.GetBotACL)
+	bots.Post("/:id/acl", middleware.RequireRole("admin"), aclHandler.CreateBotACL)
+	bots.Delete("/:id/acl/:entryId", middleware.RequireRole("admin"), aclHandler.DeleteBotACL)
+	bots.Put("/:id/owner", middleware.RequireRole("admin"), aclHandler.TransferOwnership)
 
 	// Admin-only routes
-	admin := api.Group("/admin", middleware.AuthMiddleware(cfg), middleware.RequireRole("admin"))
-	admin.Post("/users", authHandler.Register)
+	admin := api.Group("/admin", middleware.AuthMiddleware(jwtManager), apiLimit, middleware.RequireRole("admin"))
+	admin.Post("/users", userHandler.CreateUser)
+	admin.Get("/users", userHandler.GetUsers)
+	admin.Get("/users/:id", userHandler.GetUser)
+	admin.Put("/users/:id", userHandler.UpdateUser)
+	admin.Post("/users/:id/deactivate", userHandler.DeactivateUser)
+	admin.Post("/users/:id/reactivate", userHandler.ReactivateUser)
+	admin.Delete("/users/:id", userHandler.DeleteUser)
+	admin.Post("/users/:id/mfa/reset", userHandler.ResetMFA)
+	admin.Get("/users/:id/login-status", userHandler.GetLoginStatus)
+	admin.Post("/users/:id/unlock", userHandler.UnlockUser)
 	admin.Get("/audit-logs", auditHandler.GetAuditLogs)
+	admin.Get("/audit-logs/export", auditHandler.ExportAuditLogs)
+	admin.Get("/audit-logs/verify", middleware.RequireSuperAdmin(), auditHandler.VerifyAuditChain)
+	admin.Get("/audit-logs/checkpoints", middleware.RequireSuperAdmin(), auditHandler.GetCheckpoints)
+	admin.Post("/audit-logs/checkpoints", middleware.RequireSuperAdmin(), auditHandler.CreateCheckpoint)
+	admin.Get("/audit-logs/archives", middleware.RequireSuperAdmin(), auditHandler.GetArchives)
+	admin.Get("/audit-logs/sinks", middleware.RequireSuperAdmin(), auditHandler.GetSinks)
+	admin.Get("/legal-holds", middleware.RequireSuperAdmin(), legalHoldHandler.GetLegalHolds)
+	admin.Post("/legal-holds", middleware.RequireSuperAdmin(), legalHoldHandler.CreateLegalHold)
+	admin.Delete("/legal-holds/:id", middleware.RequireSuperAdmin(), legalHoldHandler.ReleaseLegalHold)
 	admin.Get("/audit-logs/:id", auditHandler.GetAuditLog)
+	admin.Get("/invites", inviteHandler.GetInvites)
+	admin.Post("/invites", inviteHandler.CreateInvite)
+	admin.Delete("/invites/:id", inviteHandler.RevokeInvite)
+	admin.Get("/members", orgHandler.GetMembers)
+	admin.Post("/members", orgHandler.SetMember)
+	admin.Delete("/members/:userId", orgHandler.RemoveMember)
+	admin.Get("/trusted-keys", trustedKeyHandler.GetTrustedKeys)
+	admin.Post("/trusted-keys", trustedKeyHandler.CreateTrustedKey)
+	admin.Delete("/trusted-keys/:id", trustedKeyHandler.RevokeTrustedKey)
+	admin.Get("/groups", groupHandler.GetGroups)
+	admin.Post("/groups", groupHandler.CreateGroup)
+	admin.Delete("/groups/:id", groupHandler.DeleteGroup)
+	admin.Post("/groups/:id/members", groupHandler.AddMember)
+	admin.Delete("/groups/:id/members/:userId", groupHandler.RemoveMember)
 
 	// Start server
 	addr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
@@ -138,4 +359,4 @@ func main() {
 	if err := app.Listen(addr); err != nil {
 		log.Errorf("Failed to start server: %v", err)
 	}
-}
\ No newline at end of file
+}
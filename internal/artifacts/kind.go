@@ -0,0 +1,39 @@
+package artifacts
+
+import (
+	"bytes"
+)
+
+// Artifact kinds.
+const (
+	KindScript  = "script"
+	KindBinary  = "binary"
+	KindTarball = "tarball"
+)
+
+// ValidKind reports whether kind is a known artifact kind.
+func ValidKind(kind string) bool {
+	return kind == KindScript || kind == KindBinary || kind == KindTarball
+}
+
+// DetectKind guesses the kind of an artifact from its first bytes: gzip or
+// tar archives are tarballs, executables in ELF, Mach-O or PE format are
+// binaries, and files starting with a #! line are scripts. It returns ""
+// when the content matches none of them.
+func DetectKind(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}),
+		len(head) >= 262 && bytes.Equal(head[257:262], []byte("ustar")):
+		return KindTarball
+	case bytes.HasPrefix(head, []byte("\x7fELF")),
+		bytes.HasPrefix(head, []byte{0xfe, 0xed, 0xfa, 0xce}),
+		bytes.HasPrefix(head, []byte{0xfe, 0xed, 0xfa, 0xcf}),
+		bytes.HasPrefix(head, []byte{0xce, 0xfa, 0xed, 0xfe}),
+		bytes.HasPrefix(head, []byte{0xcf, 0xfa, 0xed, 0xfe}),
+		bytes.HasPrefix(head, []byte("MZ")):
+		return KindBinary
+	case bytes.HasPrefix(head, []byte("#!")):
+		return KindScript
+	}
+	return ""
+}
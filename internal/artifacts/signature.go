@@ -0,0 +1,67 @@
+package artifacts
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidPublicKey is returned for public keys that are not ed25519.
+var ErrInvalidPublicKey = errors.New("public key must be an ed25519 key, PEM or base64 encoded")
+
+// ErrInvalidSignature is returned for signatures that are not a base64
+// encoded ed25519 signature.
+var ErrInvalidSignature = errors.New("signature must be a base64 encoded ed25519 signature")
+
+// Artifact signatures are detached ed25519 signatures over the artifact's
+// digest string, such as "sha256:3f9a...", so a build pipeline can sign
+// the output of sha256sum without the store re-reading the content.
+
+// ParsePublicKey decodes an ed25519 public key given either as a PEM
+// "PUBLIC KEY" block or as the base64 encoding of its 32 raw bytes.
+func ParsePublicKey(value string) (ed25519.PublicKey, error) {
+	value = strings.TrimSpace(value)
+
+	if block, _ := pem.Decode([]byte(value)); block != nil {
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, ErrInvalidPublicKey
+		}
+		key, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return nil, ErrInvalidPublicKey
+		}
+		return key, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, ErrInvalidPublicKey
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// Fingerprint identifies a public key by the hex SHA-256 of its raw bytes.
+func Fingerprint(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseSignature decodes a base64 encoded ed25519 signature.
+func ParseSignature(value string) ([]byte, error) {
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return nil, ErrInvalidSignature
+	}
+	return signature, nil
+}
+
+// VerifySignature reports whether signature is key's signature of digest.
+func VerifySignature(key ed25519.PublicKey, digest string, signature []byte) bool {
+	return ed25519.Verify(key, []byte(digest), signature)
+}
@@ -0,0 +1,60 @@
+package artifacts
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+)
+
+// ErrNotFound is returned when no blob has the requested digest.
+var ErrNotFound = errors.New("artifact not found")
+
+// ErrDigestMismatch is returned by Put when the content does not hash to
+// the digest it was stored under.
+var ErrDigestMismatch = errors.New("artifact content does not match its digest")
+
+var digestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// Store keeps artifact blobs addressed by the SHA-256 of their content, so
+// identical uploads are stored once. Digests have the form
+// "sha256:<64 hex digits>".
+type Store interface {
+	// Put stores the content read from r under digest. Storing a digest
+	// that already exists is a no-op.
+	Put(ctx context.Context, digest string, r io.Reader) error
+	// Open returns the content stored under digest, or ErrNotFound.
+	Open(ctx context.Context, digest string) (io.ReadCloser, error)
+	// Exists reports whether digest is stored.
+	Exists(ctx context.Context, digest string) (bool, error)
+}
+
+// New returns the store selected by cfg.Store.
+func New(cfg config.ArtifactConfig) (Store, error) {
+	switch cfg.Store {
+	case "local", "":
+		return NewLocalStore(cfg.LocalDir)
+	default:
+		return nil, fmt.Errorf("unknown artifact store %q", cfg.Store)
+	}
+}
+
+// Digest returns the digest of the content read from r and its size.
+func Digest(r io.Reader) (string, int64, error) {
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return "sha256:" + hex.EncodeToString(hasher.Sum(nil)), size, nil
+}
+
+// ValidDigest reports whether digest is a well-formed SHA-256 digest.
+func ValidDigest(digest string) bool {
+	return digestPattern.MatchString(digest)
+}
@@ -0,0 +1,444 @@
+package audit
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/FRFebi/bot-management-backend/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// archiveLockID is the advisory lock letting one replica archive at a time.
+const archiveLockID = 0x61726368
+
+// archiveMaxEntries caps the entries written to a single archive file.
+const archiveMaxEntries = 100000
+
+// manifestVersion is the format of the manifests written by this version.
+const manifestVersion = 1
+
+// Manifest describes an archive file. It is written next to the file so an
+// archive can be checked and restored without the database.
+type Manifest struct {
+	Version       int       `json:"version"`
+	FirstSequence int64     `json:"first_sequence"`
+	LastSequence  int64     `json:"last_sequence"`
+	FirstPrevHash string    `json:"first_prev_hash"`
+	LastHash      string    `json:"last_hash"`
+	EntryCount    int64     `json:"entry_count"`
+	OldestAt      time.Time `json:"oldest_at"`
+	NewestAt      time.Time `json:"newest_at"`
+	File          string    `json:"file"`
+	SHA256        string    `json:"sha256"`
+	Size          int64     `json:"size"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// heldCondition matches audit_logs rows under an active legal hold: those
+// by or about a held user, and those about a held bot.
+const heldCondition = `EXISTS (
+	SELECT 1 FROM legal_holds h
+	WHERE h.released_at IS NULL AND (
+		(h.user_id IS NOT NULL AND (
+			audit_logs.user_id = h.user_id
+			OR audit_logs.details->>'user_id' = h.user_id::text
+			OR audit_logs.details->>'target_user_id' = h.user_id::text))
+		OR (h.bot_id IS NOT NULL AND audit_logs.details->>'bot_id' = h.bot_id::text)))`
+
+// Archiver moves audit entries past the retention period out of the
+// database into gzip-compressed NDJSON files. Archives cover contiguous
+// ranges of the chain, so each file can be verified on its own and the
+// files together continue the chain where the previous one ends.
+type Archiver struct {
+	dir       string
+	retention time.Duration
+	interval  time.Duration
+	log       *logger.Logger
+}
+
+func NewArchiver(cfg config.AuditConfig) *Archiver {
+	return &Archiver{
+		dir:       cfg.ArchiveDir,
+		retention: time.Duration(cfg.RetentionDays) * 24 * time.Hour,
+		interval:  time.Duration(cfg.ArchiveIntervalHours) * time.Hour,
+		log:       logger.New(),
+	}
+}
+
+// Start archives once and then every interval. A zero retention disables
+// archiving.
+func (a *Archiver) Start() {
+	if a.retention <= 0 {
+		return
+	}
+
+	run := func() {
+		if count, err := a.Run(); err != nil {
+			a.log.Errorf("Failed to archive audit log: %v", err)
+		} else if count > 0 {
+			a.log.Infof("Archived %d audit log file(s)", count)
+		}
+	}
+
+	go func() {
+		run()
+
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			run()
+		}
+	}()
+}
+
+// Run archives every entry past the retention period and removes archived
+// entries that are not under a legal hold. It returns the number of
+// archive files written.
+func (a *Archiver) Run() (int, error) {
+	if err := os.MkdirAll(a.dir, 0o750); err != nil {
+		return 0, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	count := 0
+	for {
+		archive, err := a.archiveNext()
+		if err != nil {
+			return count, err
+		}
+		if archive == nil {
+			break
+		}
+		count++
+	}
+
+	return count, Purge(database.DB)
+}
+
+// archiveNext writes the next range of entries past the retention period
+// to a file. It returns nil when there is nothing to archive or another
+// replica is archiving.
+func (a *Archiver) archiveNext() (*models.AuditArchive, error) {
+	var archive *models.AuditArchive
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", archiveLockID).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		var previous models.AuditArchive
+		if err := tx.Order("last_sequence DESC").Limit(1).Find(&previous).Error; err != nil {
+			return err
+		}
+
+		// Archive up to the first entry still within the retention period,
+		// so the range stays contiguous even if entries were queued for
+		// retry and appended out of time order.
+		upper := previous.LastSequence + archiveMaxEntries
+		var keep models.AuditLog
+		if err := tx.Select("sequence").
+			Where("sequence > ? AND created_at >= ?", previous.LastSequence, time.Now().Add(-a.retention)).
+			Order("sequence ASC").Limit(1).
+			Find(&keep).Error; err != nil {
+			return err
+		}
+		if keep.Sequence != 0 && keep.Sequence-1 < upper {
+			upper = keep.Sequence - 1
+		}
+		if upper <= previous.LastSequence {
+			return nil
+		}
+
+		written, err := a.write(tx, previous, upper)
+		if err != nil || written == nil {
+			return err
+		}
+		if err := tx.Create(written).Error; err != nil {
+			return err
+		}
+		archive = written
+		return nil
+	})
+
+	return archive, err
+}
+
+// write streams the entries after previous up to sequence upper into a new
+// archive file and its manifest, checking the chain as it goes so a broken
+// chain is never archived and deleted.
+func (a *Archiver) write(tx *gorm.DB, previous models.AuditArchive, upper int64) (*models.AuditArchive, error) {
+	archive := &models.AuditArchive{
+		FirstSequence: previous.LastSequence + 1,
+		FirstPrevHash: previous.LastHash,
+	}
+	base := fmt.Sprintf("audit-%012d-%012d", archive.FirstSequence, upper)
+	archive.File = base + ".ndjson.gz"
+	archive.Manifest = base + ".manifest.json"
+
+	path := filepath.Join(a.dir, archive.File)
+	f, err := os.OpenFile(path+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path + ".tmp")
+	defer f.Close()
+
+	hasher := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(f, hasher))
+	enc := json.NewEncoder(gz)
+
+	lastHash := previous.LastHash
+	next := archive.FirstSequence
+	for next <= upper {
+		var entries []models.AuditLog
+		if err := tx.Where("sequence >= ? AND sequence <= ?", next, upper).
+			Order("sequence ASC").Limit(verifyBatchSize).
+			Find(&entries).Error; err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			break
+		}
+
+		for _, entry := range entries {
+			switch {
+			case entry.Sequence != next:
+				return nil, fmt.Errorf("audit chain is missing entry %d, not archiving", next)
+			case entry.PrevHash != lastHash || entry.Hash != entry.ComputeHash():
+				return nil, fmt.Errorf("audit chain is broken at entry %d, not archiving", entry.Sequence)
+			}
+
+			if err := enc.Encode(entry); err != nil {
+				return nil, err
+			}
+
+			if archive.EntryCount == 0 || entry.CreatedAt.Before(archive.OldestAt) {
+				archive.OldestAt = entry.CreatedAt
+			}
+			if entry.CreatedAt.After(archive.NewestAt) {
+				archive.NewestAt = entry.CreatedAt
+			}
+			archive.EntryCount++
+			lastHash = entry.Hash
+			next++
+		}
+	}
+	if archive.EntryCount == 0 {
+		return nil, nil
+	}
+	archive.LastSequence = next - 1
+	archive.LastHash = lastHash
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	archive.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	archive.Size = info.Size()
+
+	// The file was named for the most it could hold; name it for what it
+	// does hold.
+	if archive.LastSequence != upper {
+		base = fmt.Sprintf("audit-%012d-%012d", archive.FirstSequence, archive.LastSequence)
+		archive.File = base + ".ndjson.gz"
+		archive.Manifest = base + ".manifest.json"
+		path = filepath.Join(a.dir, archive.File)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return nil, err
+	}
+
+	manifest, err := json.MarshalIndent(manifestFor(archive), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(a.dir, archive.Manifest), manifest, 0o640); err != nil {
+		return nil, err
+	}
+
+	return archive, nil
+}
+
+func manifestFor(archive *models.AuditArchive) Manifest {
+	return Manifest{
+		Version:       manifestVersion,
+		FirstSequence: archive.FirstSequence,
+		LastSequence:  archive.LastSequence,
+		FirstPrevHash: archive.FirstPrevHash,
+		LastHash:      archive.LastHash,
+		EntryCount:    archive.EntryCount,
+		OldestAt:      archive.OldestAt.UTC(),
+		NewestAt:      archive.NewestAt.UTC(),
+		File:          archive.File,
+		SHA256:        archive.SHA256,
+		Size:          archive.Size,
+		CreatedAt:     time.Now().UTC(),
+	}
+}
+
+// Purge deletes entries that are safely archived, except those under an
+// active legal hold and those of restored archives. Entries kept by a hold
+// are deleted by the first run after it is released.
+func Purge(db *gorm.DB) error {
+	return db.Exec(`
+		DELETE FROM audit_logs
+		WHERE EXISTS (
+			SELECT 1 FROM audit_archives a
+			WHERE a.restored_at IS NULL
+			AND audit_logs.sequence BETWEEN a.first_sequence AND a.last_sequence)
+		AND NOT ` + heldCondition).Error
+}
+
+// Restore loads the archive described by the manifest at manifestPath back
+// into audit_logs. The file must match the manifest's checksum and every
+// entry its hash and link; otherwise nothing is loaded. A manifest for a
+// range the database already has an archive record of must match that
+// record exactly, so a forged archive cannot be passed off as a known one.
+// Entries still online, such as those kept by a legal hold, are left as
+// they are.
+// Restored entries stay online and are not purged again. It returns the
+// number of entries loaded.
+func Restore(db *gorm.DB, manifestPath string) (int64, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return 0, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return 0, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.Version != manifestVersion {
+		return 0, fmt.Errorf("unsupported manifest version %d", manifest.Version)
+	}
+
+	f, err := os.Open(filepath.Join(filepath.Dir(manifestPath), manifest.File))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var loaded int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var recorded models.AuditArchive
+		err := tx.Where("first_sequence = ? OR last_sequence = ?", manifest.FirstSequence, manifest.LastSequence).
+			First(&recorded).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && (recorded.FirstSequence != manifest.FirstSequence ||
+			recorded.LastSequence != manifest.LastSequence ||
+			recorded.FirstPrevHash != manifest.FirstPrevHash ||
+			recorded.LastHash != manifest.LastHash ||
+			recorded.SHA256 != manifest.SHA256) {
+			return fmt.Errorf("manifest does not match the archive recorded for entries %d to %d",
+				recorded.FirstSequence, recorded.LastSequence)
+		}
+
+		hasher := sha256.New()
+		tee := io.TeeReader(f, hasher)
+		gz, err := gzip.NewReader(tee)
+		if err != nil {
+			return fmt.Errorf("invalid archive: %w", err)
+		}
+		dec := json.NewDecoder(gz)
+
+		lastHash := manifest.FirstPrevHash
+		next := manifest.FirstSequence
+		batch := make([]models.AuditLog, 0, verifyBatchSize)
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			result := tx.Omit("User").Clauses(clause.OnConflict{DoNothing: true}).Create(&batch)
+			if result.Error != nil {
+				return result.Error
+			}
+			loaded += result.RowsAffected
+			batch = batch[:0]
+			return nil
+		}
+
+		for {
+			var entry models.AuditLog
+			if err := dec.Decode(&entry); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return fmt.Errorf("invalid archive: %w", err)
+			}
+
+			if entry.Sequence != next || entry.PrevHash != lastHash || entry.Hash != entry.ComputeHash() {
+				return fmt.Errorf("archive is broken at entry %d", next)
+			}
+			lastHash = entry.Hash
+			next++
+
+			batch = append(batch, entry)
+			if len(batch) == cap(batch) {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(io.Discard, tee); err != nil {
+			return err
+		}
+		if sum := hex.EncodeToString(hasher.Sum(nil)); sum != manifest.SHA256 {
+			return fmt.Errorf("archive checksum %s does not match manifest %s", sum, manifest.SHA256)
+		}
+		if next-1 != manifest.LastSequence || lastHash != manifest.LastHash {
+			return fmt.Errorf("archive ends at entry %d, manifest says %d", next-1, manifest.LastSequence)
+		}
+
+		// Restoring into a database that never saw the archive, such as a
+		// rebuilt one, records it too.
+		now := time.Now().UTC()
+		archive := models.AuditArchive{
+			FirstSequence: manifest.FirstSequence,
+			LastSequence:  manifest.LastSequence,
+			FirstPrevHash: manifest.FirstPrevHash,
+			LastHash:      manifest.LastHash,
+			EntryCount:    manifest.EntryCount,
+			OldestAt:      manifest.OldestAt,
+			NewestAt:      manifest.NewestAt,
+			File:          manifest.File,
+			Manifest:      filepath.Base(manifestPath),
+			SHA256:        manifest.SHA256,
+			Size:          manifest.Size,
+		}
+		if err := tx.Where("first_sequence = ?", manifest.FirstSequence).FirstOrCreate(&archive).Error; err != nil {
+			return err
+		}
+		return tx.Model(&archive).Update("restored_at", now).Error
+	})
+
+	return loaded, err
+}
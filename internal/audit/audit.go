@@ -0,0 +1,222 @@
+// Package audit appends entries to the tamper-evident audit log and
+// verifies its hash chain.
+package audit
+
+import (
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// verifyBatchSize is how many entries Verify loads at a time.
+const verifyBatchSize = 1000
+
+// Record appends entry to the chain. Appends are serialized with an
+// advisory lock so every entry links to the one written just before it.
+// When db is a transaction the entry commits or rolls back with it. A
+// CreatedAt already set on entry, such as the time an entry was queued
+// for retry, is kept.
+func Record(db *gorm.DB, entry *models.AuditLog) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", database.AuditChainLockID).Error; err != nil {
+			return err
+		}
+
+		var last models.AuditLog
+		if err := tx.Select("sequence", "hash").
+			Order("sequence DESC").Limit(1).
+			Find(&last).Error; err != nil {
+			return err
+		}
+
+		entry.ID = 0
+		entry.Sequence = last.Sequence + 1
+		entry.PrevHash = last.Hash
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = time.Now()
+		}
+		entry.CreatedAt = entry.CreatedAt.UTC().Truncate(time.Microsecond)
+		entry.Hash = entry.ComputeHash()
+
+		return tx.Omit("User").Create(entry).Error
+	})
+}
+
+// Break describes the first point where the chain does not hold.
+type Break struct {
+	Sequence   int64  `json:"sequence"`
+	AuditLogID uint   `json:"audit_log_id,omitempty"`
+	Reason     string `json:"reason"`
+}
+
+// Report is the outcome of verifying the chain.
+type Report struct {
+	Valid               bool   `json:"valid"`
+	EntriesChecked      int64  `json:"entries_checked"`
+	LastSequence        int64  `json:"last_sequence"`
+	LastHash            string `json:"last_hash"`
+	CheckpointsVerified int    `json:"checkpoints_verified"`
+	ArchivedThrough     int64  `json:"archived_through,omitempty"`
+	Broken              *Break `json:"broken,omitempty"`
+}
+
+// Verify walks the whole chain, recomputing every hash and link, and
+// compares it with the stored checkpoints. Checkpoints also reveal
+// entries deleted from the end of the chain, which links alone cannot, so
+// entries old enough to be covered by a checkpoint must be.
+// Entries moved to archives may be missing; where an archive ends just
+// before an online entry, the entry must link to the archive's last hash.
+// Archive files themselves are checked when they are restored.
+func Verify(db *gorm.DB, checkpoints *Checkpointer) (*Report, error) {
+	var archives []models.AuditArchive
+	if err := db.Order("first_sequence ASC").Find(&archives).Error; err != nil {
+		return nil, err
+	}
+
+	var stored []models.AuditCheckpoint
+	if err := db.Order("sequence ASC").Find(&stored).Error; err != nil {
+		return nil, err
+	}
+
+	for _, checkpoint := range stored {
+		if err := checkpoints.verifySignature(checkpoint); err != nil {
+			return &Report{Broken: &Break{
+				Sequence: checkpoint.Sequence,
+				Reason:   "checkpoint signature invalid: " + err.Error(),
+			}}, nil
+		}
+	}
+
+	check := newChainCheck(checkpoints, archives, stored)
+	for {
+		var entries []models.AuditLog
+		if err := db.Where("sequence > ?", check.report.LastSequence).
+			Order("sequence ASC").Limit(verifyBatchSize).
+			Find(&entries).Error; err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			break
+		}
+
+		for _, entry := range entries {
+			if !check.add(entry) {
+				return check.report, nil
+			}
+		}
+	}
+	return check.finish(), nil
+}
+
+// chainCheck follows the chain entry by entry for Verify. Archives and
+// checkpoints are given up front, sorted by sequence; checkpoint
+// signatures must already be verified.
+type chainCheck struct {
+	checkpointer    *Checkpointer
+	report          *Report
+	archivedHash    map[int64]string
+	archivedThrough int64
+	stored          []models.AuditCheckpoint
+	bySequence      map[int64]models.AuditCheckpoint
+	mustCover       int64
+}
+
+func newChainCheck(checkpointer *Checkpointer, archives []models.AuditArchive, stored []models.AuditCheckpoint) *chainCheck {
+	check := &chainCheck{
+		checkpointer: checkpointer,
+		archivedHash: make(map[int64]string, len(archives)),
+		stored:       stored,
+		bySequence:   make(map[int64]models.AuditCheckpoint, len(stored)),
+	}
+	for _, archive := range archives {
+		check.archivedHash[archive.LastSequence] = archive.LastHash
+		check.archivedThrough = archive.LastSequence
+	}
+	for _, checkpoint := range stored {
+		check.bySequence[checkpoint.Sequence] = checkpoint
+	}
+	check.report = &Report{ArchivedThrough: check.archivedThrough}
+	return check
+}
+
+// add checks the next entry in sequence order. It returns false, with the
+// break recorded in the report, when the chain does not hold.
+func (v *chainCheck) add(entry models.AuditLog) bool {
+	report := v.report
+	fail := func(reason string) bool {
+		report.Broken = &Break{Sequence: entry.Sequence, AuditLogID: entry.ID, Reason: reason}
+		return false
+	}
+
+	if entry.Sequence != report.LastSequence+1 {
+		if entry.Sequence-1 > v.archivedThrough {
+			report.Broken = &Break{Sequence: max(report.LastSequence, v.archivedThrough) + 1, Reason: "entry missing"}
+			return false
+		}
+
+		// The preceding entries are archived. Their hash is known where an
+		// archive ends; otherwise the archive holds the link.
+		if hash, ok := v.archivedHash[entry.Sequence-1]; ok {
+			report.LastHash = hash
+		} else {
+			report.LastHash = entry.PrevHash
+		}
+	}
+
+	switch {
+	case entry.PrevHash != report.LastHash:
+		return fail("previous hash does not match the preceding entry")
+	case entry.Hash != entry.ComputeHash():
+		return fail("content does not match its hash")
+	}
+
+	if checkpoint, ok := v.bySequence[entry.Sequence]; ok {
+		if checkpoint.Hash != entry.Hash {
+			return fail("hash does not match the signed checkpoint")
+		}
+		report.CheckpointsVerified++
+	}
+	if v.checkpointer.mustCover(entry, report.EntriesChecked == 0 && v.archivedThrough == 0) {
+		v.mustCover = entry.Sequence
+	}
+
+	report.EntriesChecked++
+	report.LastSequence = entry.Sequence
+	report.LastHash = entry.Hash
+	return true
+}
+
+// finish checks that the checkpoints match the end of the chain and cover
+// the entries they should, and completes the report.
+func (v *chainCheck) finish() *Report {
+	report := v.report
+
+	var covered int64
+	if len(v.stored) > 0 {
+		covered = v.stored[len(v.stored)-1].Sequence
+		if covered > max(report.LastSequence, v.archivedThrough) {
+			report.Broken = &Break{
+				Sequence: report.LastSequence + 1,
+				Reason:   "entries covered by a signed checkpoint are missing",
+			}
+			return report
+		}
+	}
+
+	mustCover := v.mustCover
+	if v.archivedThrough > 0 && len(v.stored) == 0 {
+		mustCover = max(mustCover, v.archivedThrough)
+	}
+	if mustCover > covered {
+		report.Broken = &Break{
+			Sequence: covered + 1,
+			Reason:   "entries are not covered by a signed checkpoint",
+		}
+		return report
+	}
+
+	report.Valid = true
+	return report
+}
@@ -0,0 +1,239 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"gorm.io/datatypes"
+)
+
+// testChain returns entries from..to linked to prevHash, created at the
+// given time.
+func testChain(from, to int64, prevHash string, createdAt time.Time) []models.AuditLog {
+	var entries []models.AuditLog
+	for seq := from; seq <= to; seq++ {
+		entry := models.AuditLog{
+			ID:             uint(seq),
+			OrganizationID: 1,
+			Action:         "bot.update",
+			Details:        datatypes.JSON(`{"bot_id":1}`),
+			CreatedAt:      createdAt.UTC().Truncate(time.Microsecond),
+			Sequence:       seq,
+			PrevHash:       prevHash,
+		}
+		entry.Hash = entry.ComputeHash()
+		prevHash = entry.Hash
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// runChainCheck feeds entries to a chainCheck the way Verify does.
+func runChainCheck(checkpointer *Checkpointer, archives []models.AuditArchive, stored []models.AuditCheckpoint, entries []models.AuditLog) *Report {
+	check := newChainCheck(checkpointer, archives, stored)
+	for _, entry := range entries {
+		if !check.add(entry) {
+			return check.report
+		}
+	}
+	return check.finish()
+}
+
+func checkpointAt(entry models.AuditLog) models.AuditCheckpoint {
+	return models.AuditCheckpoint{Sequence: entry.Sequence, Hash: entry.Hash}
+}
+
+func TestChainCheck(t *testing.T) {
+	old := time.Now().Add(-24 * time.Hour)
+	chain := testChain(1, 6, "", old)
+
+	// The first three entries archived; online copies of 4 to 6.
+	archived := []models.AuditArchive{{FirstSequence: 1, LastSequence: 3, LastHash: chain[2].Hash}}
+	// Two archives, with entries 5 and 6 restored from the second.
+	archives := []models.AuditArchive{
+		{FirstSequence: 1, LastSequence: 3, LastHash: chain[2].Hash},
+		{FirstSequence: 4, LastSequence: 6, FirstPrevHash: chain[2].Hash, LastHash: chain[5].Hash},
+	}
+
+	tampered := append([]models.AuditLog(nil), chain...)
+	tampered[2].Action = "bot.delete"
+
+	relinked := testChain(1, 6, "", old)
+	relinked[3].PrevHash = "forged"
+	relinked[3].Hash = relinked[3].ComputeHash()
+
+	tests := []struct {
+		name         string
+		interval     time.Duration
+		archives     []models.AuditArchive
+		stored       []models.AuditCheckpoint
+		entries      []models.AuditLog
+		valid        bool
+		brokenAt     int64
+		reason       string
+		checked      int64
+		checkpointed int
+	}{
+		{
+			name:         "intact chain",
+			stored:       []models.AuditCheckpoint{checkpointAt(chain[2]), checkpointAt(chain[5])},
+			entries:      chain,
+			valid:        true,
+			checked:      6,
+			checkpointed: 2,
+		},
+		{
+			name:     "entry missing",
+			stored:   []models.AuditCheckpoint{checkpointAt(chain[5])},
+			entries:  append(append([]models.AuditLog(nil), chain[:2]...), chain[3:]...),
+			brokenAt: 3,
+			reason:   "entry missing",
+		},
+		{
+			name:     "content changed",
+			stored:   []models.AuditCheckpoint{checkpointAt(chain[5])},
+			entries:  tampered,
+			brokenAt: 3,
+			reason:   "content does not match its hash",
+		},
+		{
+			name:     "link changed",
+			stored:   []models.AuditCheckpoint{checkpointAt(chain[5])},
+			entries:  relinked,
+			brokenAt: 4,
+			reason:   "previous hash does not match the preceding entry",
+		},
+		{
+			name:     "checkpoint does not match",
+			stored:   []models.AuditCheckpoint{{Sequence: 2, Hash: chain[1].PrevHash}, checkpointAt(chain[5])},
+			entries:  chain,
+			brokenAt: 2,
+			reason:   "hash does not match the signed checkpoint",
+		},
+		{
+			name:     "entries deleted from the end",
+			stored:   []models.AuditCheckpoint{checkpointAt(chain[5])},
+			entries:  chain[:4],
+			brokenAt: 5,
+			reason:   "entries covered by a signed checkpoint are missing",
+		},
+		{
+			name:     "no checkpoints",
+			entries:  chain,
+			brokenAt: 1,
+			reason:   "entries are not covered by a signed checkpoint",
+		},
+		{
+			name:  "empty log",
+			valid: true,
+		},
+		{
+			name:         "archived prefix",
+			archives:     archived,
+			stored:       []models.AuditCheckpoint{checkpointAt(chain[5])},
+			entries:      chain[3:],
+			valid:        true,
+			checked:      3,
+			checkpointed: 1,
+		},
+		{
+			name:     "archive ends on another hash",
+			archives: []models.AuditArchive{{FirstSequence: 1, LastSequence: 3, LastHash: chain[1].Hash}},
+			stored:   []models.AuditCheckpoint{checkpointAt(chain[5])},
+			entries:  chain[3:],
+			brokenAt: 4,
+			reason:   "previous hash does not match the preceding entry",
+		},
+		{
+			name:     "gap after the archives",
+			archives: archived,
+			stored:   []models.AuditCheckpoint{checkpointAt(chain[5])},
+			entries:  chain[4:],
+			brokenAt: 4,
+			reason:   "entry missing",
+		},
+		{
+			name:         "restored entries inside an archive",
+			archives:     archives,
+			stored:       []models.AuditCheckpoint{checkpointAt(chain[5])},
+			entries:      chain[4:],
+			valid:        true,
+			checked:      2,
+			checkpointed: 1,
+		},
+		{
+			name:     "archived log without checkpoints",
+			archives: archives,
+			brokenAt: 1,
+			reason:   "entries are not covered by a signed checkpoint",
+		},
+		{
+			name:     "archived log with checkpoint",
+			archives: archives,
+			stored:   []models.AuditCheckpoint{checkpointAt(chain[5])},
+			valid:    true,
+		},
+		{
+			name:     "old entries past the last checkpoint",
+			interval: time.Hour,
+			stored:   []models.AuditCheckpoint{checkpointAt(chain[3])},
+			entries:  chain,
+			brokenAt: 5,
+			reason:   "entries are not covered by a signed checkpoint",
+		},
+		{
+			name:         "recent entries past the last checkpoint",
+			interval:     time.Hour,
+			stored:       []models.AuditCheckpoint{checkpointAt(chain[3])},
+			entries:      append(append([]models.AuditLog(nil), chain[:4]...), testChain(5, 6, chain[3].Hash, time.Now())...),
+			valid:        true,
+			checked:      6,
+			checkpointed: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkpointer := NewCheckpointer(nil, nil, "bot-management", tt.interval)
+			report := runChainCheck(checkpointer, tt.archives, tt.stored, tt.entries)
+
+			if tt.valid {
+				if !report.Valid || report.Broken != nil {
+					t.Fatalf("expected a valid chain, got %+v", report.Broken)
+				}
+				if report.EntriesChecked != tt.checked {
+					t.Errorf("expected %d entries checked, got %d", tt.checked, report.EntriesChecked)
+				}
+				if report.CheckpointsVerified != tt.checkpointed {
+					t.Errorf("expected %d checkpoints verified, got %d", tt.checkpointed, report.CheckpointsVerified)
+				}
+				return
+			}
+
+			if report.Valid || report.Broken == nil {
+				t.Fatal("expected a broken chain")
+			}
+			if report.Broken.Sequence != tt.brokenAt || report.Broken.Reason != tt.reason {
+				t.Errorf("expected break at %d (%s), got %d (%s)", tt.brokenAt, tt.reason, report.Broken.Sequence, report.Broken.Reason)
+			}
+		})
+	}
+}
+
+func TestChainCheckReport(t *testing.T) {
+	chain := testChain(1, 5, "", time.Now().Add(-24*time.Hour))
+	archives := []models.AuditArchive{{FirstSequence: 1, LastSequence: 2, LastHash: chain[1].Hash}}
+
+	report := runChainCheck(NewCheckpointer(nil, nil, "bot-management", 0), archives,
+		[]models.AuditCheckpoint{checkpointAt(chain[4])}, chain[2:])
+	if !report.Valid {
+		t.Fatalf("expected a valid chain, got %+v", report.Broken)
+	}
+	if report.LastSequence != 5 || report.LastHash != chain[4].Hash {
+		t.Errorf("expected the chain to end at 5 with %s, got %d with %s", chain[4].Hash, report.LastSequence, report.LastHash)
+	}
+	if report.ArchivedThrough != 2 {
+		t.Errorf("expected entries archived through 2, got %d", report.ArchivedThrough)
+	}
+}
@@ -0,0 +1,243 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/FRFebi/bot-management-backend/pkg/auth"
+	"github.com/FRFebi/bot-management-backend/pkg/logger"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// checkpointType marks checkpoint signatures so they cannot be mistaken
+// for other documents signed with the same keys.
+const checkpointType = "audit_checkpoint"
+
+// CheckpointClaims is the signed content of a checkpoint.
+type CheckpointClaims struct {
+	Type     string `json:"typ"`
+	Sequence int64  `json:"seq"`
+	Hash     string `json:"hash"`
+	jwt.RegisteredClaims
+}
+
+// Checkpointer periodically signs the head of the audit chain with the
+// token signing keys. Exported checkpoints let an auditor prove the log
+// was not rewritten after the fact.
+//
+// Checkpoints only verify with keys from the signing_keys table, which
+// keeps the public half of every key after it expires, or the trusted
+// public keys the operator configured, never with the key stored next to
+// the checkpoint, which anyone able to rewrite the log could replace as
+// well.
+type Checkpointer struct {
+	keys     *auth.KeySet
+	trusted  map[string]auth.JWK
+	issuer   string
+	interval time.Duration
+	log      *logger.Logger
+}
+
+func NewCheckpointer(keys *auth.KeySet, trusted []auth.JWK, issuer string, interval time.Duration) *Checkpointer {
+	byID := make(map[string]auth.JWK, len(trusted))
+	for _, jwk := range trusted {
+		byID[jwk.KeyID] = jwk
+	}
+
+	return &Checkpointer{
+		keys:     keys,
+		trusted:  byID,
+		issuer:   issuer,
+		interval: interval,
+		log:      logger.New(),
+	}
+}
+
+// LoadTrustedKeys reads a JWK Set of public keys that verify checkpoints
+// signed by keys missing from the signing_keys table, for instance by a
+// previous database. An empty path trusts no extra keys.
+func LoadTrustedKeys(path string) ([]auth.JWK, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks auth.JWKS
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for _, jwk := range jwks.Keys {
+		if jwk.KeyID == "" {
+			return nil, fmt.Errorf("%s: key without kid", path)
+		}
+		if _, err := jwk.PublicKey(); err != nil {
+			return nil, fmt.Errorf("%s: key %s: %w", path, jwk.KeyID, err)
+		}
+	}
+	return jwks.Keys, nil
+}
+
+// Start signs a checkpoint now and then every interval while the chain
+// grows. A zero interval disables periodic checkpoints.
+func (c *Checkpointer) Start() {
+	if c.interval <= 0 {
+		return
+	}
+
+	go func() {
+		if _, err := c.Create(); err != nil {
+			c.log.Errorf("Failed to create audit checkpoint: %v", err)
+		}
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := c.Create(); err != nil {
+				c.log.Errorf("Failed to create audit checkpoint: %v", err)
+			}
+		}
+	}()
+}
+
+// Create signs a checkpoint for the current head of the chain. It returns
+// the existing checkpoint when the head is already covered, and nil when
+// the log is empty.
+func (c *Checkpointer) Create() (*models.AuditCheckpoint, error) {
+	var checkpoint *models.AuditCheckpoint
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", database.AuditChainLockID).Error; err != nil {
+			return err
+		}
+
+		var head models.AuditLog
+		if err := tx.Select("sequence", "hash").
+			Order("sequence DESC").Limit(1).
+			Find(&head).Error; err != nil {
+			return err
+		}
+		if head.Sequence == 0 {
+			return nil
+		}
+
+		var existing models.AuditCheckpoint
+		if err := tx.Where("sequence = ?", head.Sequence).Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if existing.ID != 0 {
+			checkpoint = &existing
+			return nil
+		}
+
+		now := time.Now().UTC()
+		signature, key, err := c.keys.Sign(CheckpointClaims{
+			Type:     checkpointType,
+			Sequence: head.Sequence,
+			Hash:     head.Hash,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:   c.issuer,
+				IssuedAt: jwt.NewNumericDate(now),
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		jwk, err := json.Marshal(key.JWK())
+		if err != nil {
+			return err
+		}
+
+		checkpoint = &models.AuditCheckpoint{
+			Sequence:  head.Sequence,
+			Hash:      head.Hash,
+			KeyID:     key.ID,
+			PublicKey: datatypes.JSON(jwk),
+			Signature: signature,
+			CreatedAt: now,
+		}
+		return tx.Create(checkpoint).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return checkpoint, nil
+}
+
+// verifySignature checks that checkpoint was signed over its sequence and
+// hash by a trusted key. The JWK stored with the checkpoint is ignored.
+func (c *Checkpointer) verifySignature(checkpoint models.AuditCheckpoint) error {
+	var claims CheckpointClaims
+	_, err := jwt.ParseWithClaims(checkpoint.Signature, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid != checkpoint.KeyID {
+			return nil, errors.New("key ID does not match")
+		}
+
+		if key, ok := c.keys.Verification(kid); ok {
+			if token.Method.Alg() != key.Algorithm {
+				return nil, errors.New("unexpected signing method")
+			}
+			return key.PrivateKey.Public(), nil
+		}
+
+		jwk, ok := c.trusted[kid]
+		if !ok {
+			jwk, ok = retiredKey(kid)
+		}
+		if !ok {
+			return nil, fmt.Errorf("signing key %s is not trusted", kid)
+		}
+		if token.Method.Alg() != jwk.Algorithm {
+			return nil, errors.New("unexpected signing method")
+		}
+		return jwk.PublicKey()
+	}, jwt.WithValidMethods([]string{auth.AlgorithmRS256, auth.AlgorithmEdDSA}))
+	if err != nil {
+		return err
+	}
+
+	if claims.Type != checkpointType || claims.Sequence != checkpoint.Sequence || claims.Hash != checkpoint.Hash {
+		return fmt.Errorf("signed content does not match checkpoint %d", checkpoint.ID)
+	}
+	return nil
+}
+
+// retiredKey returns the public half kept in signing_keys for kid once the
+// key no longer verifies tokens.
+func retiredKey(kid string) (auth.JWK, bool) {
+	var row models.SigningKey
+	if err := database.DB.Where("kid = ? AND COALESCE(public_key, '') <> ''", kid).First(&row).Error; err != nil {
+		return auth.JWK{}, false
+	}
+
+	var jwk auth.JWK
+	if err := json.Unmarshal([]byte(row.PublicKey), &jwk); err != nil || jwk.KeyID != kid || jwk.Algorithm != row.Algorithm {
+		return auth.JWK{}, false
+	}
+	return jwk, true
+}
+
+// mustCover reports whether a checkpoint should already cover entry. With
+// periodic checkpoints that is every entry older than two intervals;
+// otherwise only the first entry, so that a log whose checkpoints were all
+// deleted does not pass.
+func (c *Checkpointer) mustCover(entry models.AuditLog, first bool) bool {
+	if c.interval <= 0 {
+		return first
+	}
+	return entry.CreatedAt.Before(time.Now().Add(-2 * c.interval))
+}
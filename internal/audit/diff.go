@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+
+	"gorm.io/datatypes"
+)
+
+// Change is one field that differs between two versions of a resource.
+// Path is dotted for nested objects, e.g. "config.timeout". Before or
+// After is null when the field was added or removed.
+type Change struct {
+	Path   string      `json:"path"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Diff compares the JSON forms of before and after, either of which may
+// be nil for a created or deleted resource, and returns the changes as
+// JSON. Objects, including JSON columns such as a bot's config, are
+// compared field by field; arrays are compared whole. Top-level fields
+// listed in ignore, such as timestamps, are skipped. It returns nil when
+// nothing changed.
+func Diff(before, after interface{}, ignore ...string) datatypes.JSON {
+	var changes []Change
+	walk("", toJSONValue(before), toJSONValue(after), ignore, &changes)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return nil
+	}
+	return datatypes.JSON(data)
+}
+
+func walk(path string, before, after interface{}, ignore []string, changes *[]Change) {
+	beforeMap, beforeIsMap := before.(map[string]interface{})
+	afterMap, afterIsMap := after.(map[string]interface{})
+
+	if (beforeIsMap || before == nil) && (afterIsMap || after == nil) && (beforeIsMap || afterIsMap) {
+		keys := make([]string, 0, len(beforeMap)+len(afterMap))
+		for key := range beforeMap {
+			keys = append(keys, key)
+		}
+		for key := range afterMap {
+			if _, ok := beforeMap[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if path == "" && slices.Contains(ignore, key) {
+				continue
+			}
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			walk(child, beforeMap[key], afterMap[key], nil, changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		*changes = append(*changes, Change{Path: path, Before: before, After: after})
+	}
+}
+
+// toJSONValue converts v to the generic form produced by decoding its
+// JSON encoding, keeping numbers as written.
+func toJSONValue(v interface{}) interface{} {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/FRFebi/bot-management-backend/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Outbox retry tuning.
+const (
+	outboxBatchSize  = 100
+	outboxMaxBackoff = time.Hour
+)
+
+// failureMode is set by Configure; entries fail their request by default.
+var failureMode = config.AuditFailureFail
+
+// Configure sets how Write handles entries that cannot be appended.
+func Configure(cfg config.AuditConfig) {
+	failureMode = cfg.FailureMode
+}
+
+// Write appends entry in db, normally the transaction of the change being
+// audited. If the append fails and the failure mode is retry, the entry is
+// queued in the outbox in the same transaction instead, so the change and
+// the promise to audit it still commit together. An error means neither
+// happened and the caller must fail the request.
+func Write(db *gorm.DB, entry *models.AuditLog) error {
+	err := Record(db, entry)
+	if err == nil || failureMode != config.AuditFailureRetry {
+		return err
+	}
+
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+	data, marshalErr := json.Marshal(entry)
+	if marshalErr != nil {
+		return fmt.Errorf("%w (and failed to queue: %v)", err, marshalErr)
+	}
+
+	queued := models.AuditOutbox{
+		Entry:         data,
+		LastError:     err.Error(),
+		NextAttemptAt: time.Now().UTC(),
+	}
+	if queueErr := db.Create(&queued).Error; queueErr != nil {
+		return fmt.Errorf("%w (and failed to queue: %v)", err, queueErr)
+	}
+	return nil
+}
+
+// StartRetryWorker appends queued outbox entries to the chain every
+// interval, backing off per entry while they keep failing.
+func StartRetryWorker(interval time.Duration) {
+	log := logger.New()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := drainOutbox(); err != nil {
+				log.Errorf("Failed to drain audit outbox: %v", err)
+			}
+		}
+	}()
+}
+
+func drainOutbox() error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var queued []models.AuditOutbox
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("next_attempt_at <= ?", time.Now().UTC()).
+			Order("id ASC").Limit(outboxBatchSize).
+			Find(&queued).Error; err != nil {
+			return err
+		}
+
+		for _, item := range queued {
+			var entry models.AuditLog
+			err := json.Unmarshal(item.Entry, &entry)
+			if err == nil {
+				err = tx.Transaction(func(tx *gorm.DB) error {
+					if err := Record(tx, &entry); err != nil {
+						return err
+					}
+					return tx.Delete(&item).Error
+				})
+			}
+			if err == nil {
+				continue
+			}
+
+			item.Attempts++
+			backoff := time.Duration(1<<min(item.Attempts, 12)) * time.Second
+			item.NextAttemptAt = time.Now().UTC().Add(min(backoff, outboxMaxBackoff))
+			item.LastError = err.Error()
+			if err := tx.Save(&item).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,145 @@
+package auditsink
+
+import (
+	"context"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/FRFebi/bot-management-backend/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sendTimeout bounds a single delivery attempt.
+const sendTimeout = 30 * time.Second
+
+// Forwarder copies committed audit entries to each sink. It reads the
+// chain rather than taking entries as they are written, so an entry whose
+// transaction rolled back is never forwarded and nothing is lost across
+// restarts. Each sink has its own cursor; a sink that is down falls behind
+// and backs off without holding up the others.
+type Forwarder struct {
+	sinks      []Sink
+	batchSize  int
+	interval   time.Duration
+	maxBackoff time.Duration
+	log        *logger.Logger
+}
+
+func NewForwarder(cfg config.AuditConfig, sinks []Sink) *Forwarder {
+	return &Forwarder{
+		sinks:      sinks,
+		batchSize:  cfg.SinkBatchSize,
+		interval:   time.Duration(cfg.SinkIntervalSeconds) * time.Second,
+		maxBackoff: time.Duration(cfg.SinkMaxBackoffSeconds) * time.Second,
+		log:        logger.New(),
+	}
+}
+
+// Start creates the cursor of each new sink at the current head of the
+// chain, so a sink receives entries from when it was added, and then
+// forwards in the background.
+func (f *Forwarder) Start() error {
+	var head models.AuditLog
+	if err := database.DB.Select("sequence").Order("sequence DESC").Limit(1).Find(&head).Error; err != nil {
+		return err
+	}
+
+	for _, sink := range f.sinks {
+		cursor := models.AuditSinkCursor{Sink: sink.Name(), LastSequence: head.Sequence}
+		if err := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&cursor).Error; err != nil {
+			return err
+		}
+
+		go f.run(sink)
+	}
+	return nil
+}
+
+func (f *Forwarder) run(sink Sink) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// Keep going while full batches come back, to catch up quickly.
+		for {
+			delivered, err := f.deliver(sink)
+			if err != nil {
+				f.log.Errorf("Failed to forward audit entries to %s: %v", sink.Name(), err)
+				break
+			}
+			if delivered < f.batchSize {
+				break
+			}
+		}
+	}
+}
+
+// deliver sends the next batch after the sink's cursor and advances it. The
+// cursor row stays locked while sending, so replicas never deliver the
+// same batch concurrently.
+func (f *Forwarder) deliver(sink Sink) (int, error) {
+	var delivered int
+	var sendErr error
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var cursor models.AuditSinkCursor
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("sink = ?", sink.Name()).
+			Find(&cursor).Error; err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		if cursor.Sink == "" || now.Before(cursor.NextAttemptAt) {
+			return nil
+		}
+
+		var entries []models.AuditLog
+		if err := tx.Where("sequence > ?", cursor.LastSequence).
+			Order("sequence ASC").Limit(f.batchSize).
+			Find(&entries).Error; err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+
+		if sendErr = sink.Send(ctx, entries); sendErr != nil {
+			cursor.Failures++
+			backoff := f.interval << min(cursor.Failures, 16)
+			cursor.NextAttemptAt = now.Add(min(backoff, f.maxBackoff))
+			cursor.LastError = sendErr.Error()
+			return tx.Save(&cursor).Error
+		}
+
+		cursor.LastSequence = entries[len(entries)-1].Sequence
+		cursor.Failures = 0
+		cursor.LastError = ""
+		cursor.NextAttemptAt = now
+		cursor.DeliveredAt = &now
+		if err := tx.Save(&cursor).Error; err != nil {
+			return err
+		}
+		delivered = len(entries)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return delivered, sendErr
+}
+
+// Close closes every sink.
+func (f *Forwarder) Close() {
+	for _, sink := range f.sinks {
+		if err := sink.Close(); err != nil {
+			f.log.Errorf("Failed to close audit sink %s: %v", sink.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package auditsink
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+)
+
+// Sink delivers audit entries to a system outside the database, such as a
+// SIEM. Send delivers the whole batch, in chain order, or returns an
+// error. A batch that failed is sent again, possibly after part of it got
+// through, so receivers should deduplicate on the entry sequence.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, entries []models.AuditLog) error
+	Close() error
+}
+
+// New returns the sinks named in cfg.Sinks.
+func New(cfg config.AuditConfig) ([]Sink, error) {
+	sinks := make([]Sink, 0, len(cfg.Sinks))
+	for _, name := range cfg.Sinks {
+		switch name {
+		case "syslog":
+			sinks = append(sinks, NewSyslogSink(cfg.Syslog))
+		case "webhook":
+			sinks = append(sinks, NewWebhookSink(cfg.Webhook))
+		case "file":
+			sink, err := NewFileSink(cfg.File)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		default:
+			return nil, fmt.Errorf("unknown audit sink %q", name)
+		}
+	}
+	return sinks, nil
+}
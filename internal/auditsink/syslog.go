@@ -0,0 +1,220 @@
+package auditsink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+)
+
+// syslogSDID names the structured data element carrying the entry's
+// fields. 32473 is the private enterprise number reserved for
+// documentation (RFC 5612).
+const syslogSDID = "audit@32473"
+
+// Syslog severities by audit outcome.
+const (
+	severityError   = 3
+	severityWarning = 4
+	severityInfo    = 6
+)
+
+// SyslogSink sends each entry as an RFC 5424 message. Over TCP messages
+// use octet-counting framing (RFC 6587) on a connection kept open between
+// batches; over UDP each message is one datagram.
+//
+// A datagram cannot be larger than maxSize, and retrying one that is would
+// fail the same way forever and hold up every later entry. Such entries are
+// sent as a summary instead, marked with a "truncated" parameter giving
+// the size of the full entry, which stays in the audit log under its
+// sequence.
+type SyslogSink struct {
+	network  string
+	address  string
+	appName  string
+	facility int
+	maxSize  int
+	hostname string
+	procID   string
+	conn     net.Conn
+}
+
+// syslogSummary replaces the body of an entry too large for a datagram.
+type syslogSummary struct {
+	Sequence       int64  `json:"sequence"`
+	Hash           string `json:"hash"`
+	Action         string `json:"action"`
+	OrganizationID uint   `json:"organization_id"`
+	UserID         *uint  `json:"user_id,omitempty"`
+	Truncated      bool   `json:"truncated"`
+}
+
+func NewSyslogSink(cfg config.AuditSyslogConfig) *SyslogSink {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return &SyslogSink{
+		network:  cfg.Network,
+		address:  cfg.Address,
+		appName:  cfg.AppName,
+		facility: cfg.Facility,
+		maxSize:  cfg.MaxMessageSize,
+		hostname: hostname,
+		procID:   strconv.Itoa(os.Getpid()),
+	}
+}
+
+func (s *SyslogSink) Name() string {
+	return "syslog"
+}
+
+func (s *SyslogSink) Send(ctx context.Context, entries []models.AuditLog) error {
+	if s.conn == nil {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, s.network, s.address)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+
+	for i := range entries {
+		msg, err := s.format(&entries[i])
+		if err != nil {
+			return err
+		}
+		if s.network == "tcp" {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+
+		if _, err := s.conn.Write(msg); err != nil {
+			// A failed write leaves the stream in an unknown state; start
+			// over on a new connection.
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// format renders entry as an RFC 5424 message: the entry's fields as
+// structured data for filtering, and the full entry as JSON in the body.
+// Over UDP, an entry that does not fit in maxSize gets a summary body.
+func (s *SyslogSink) format(entry *models.AuditLog) ([]byte, error) {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := s.message(entry, body, nil)
+	if s.network != "udp" || len(msg) <= s.maxSize {
+		return msg, nil
+	}
+
+	summary, err := json.Marshal(syslogSummary{
+		Sequence:       entry.Sequence,
+		Hash:           entry.Hash,
+		Action:         entry.Action,
+		OrganizationID: entry.OrganizationID,
+		UserID:         entry.UserID,
+		Truncated:      true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.message(entry, summary, []string{sdParam("truncated", strconv.Itoa(len(body)))}), nil
+}
+
+// message assembles the header, with extra structured data parameters,
+// and body of entry.
+func (s *SyslogSink) message(entry *models.AuditLog, body []byte, extra []string) []byte {
+	severity := severityInfo
+	switch entry.Outcome {
+	case models.AuditOutcomeDenied:
+		severity = severityWarning
+	case models.AuditOutcomeFailure:
+		severity = severityError
+	}
+
+	params := []string{
+		sdParam("seq", strconv.FormatInt(entry.Sequence, 10)),
+		sdParam("org", strconv.FormatUint(uint64(entry.OrganizationID), 10)),
+	}
+	if entry.UserID != nil {
+		params = append(params, sdParam("user", strconv.FormatUint(uint64(*entry.UserID), 10)))
+	}
+	for _, field := range []struct{ name, value string }{
+		{"outcome", entry.Outcome},
+		{"ip", entry.IPAddress},
+		{"request_id", entry.RequestID},
+		{"hash", entry.Hash},
+	} {
+		if field.value != "" {
+			params = append(params, sdParam(field.name, field.value))
+		}
+	}
+	if entry.StatusCode != 0 {
+		params = append(params, sdParam("status", strconv.Itoa(entry.StatusCode)))
+	}
+	params = append(params, extra...)
+
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] BOM MSG
+	header := fmt.Sprintf("<%d>1 %s %s %s %s %s [%s %s] \ufeff",
+		s.facility*8+severity,
+		entry.CreatedAt.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		headerField(s.hostname, 255),
+		headerField(s.appName, 48),
+		headerField(s.procID, 128),
+		headerField(entry.Action, 32),
+		syslogSDID,
+		strings.Join(params, " "),
+	)
+	return append([]byte(header), body...)
+}
+
+func (s *SyslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// headerField fits value to a header field: printable US-ASCII without
+// spaces, at most max characters, and "-" when empty.
+func headerField(value string, max int) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if len(cleaned) > max {
+		cleaned = cleaned[:max]
+	}
+	if cleaned == "" {
+		return "-"
+	}
+	return cleaned
+}
+
+// sdParam renders a structured data parameter, escaping the characters
+// RFC 5424 reserves in values.
+func sdParam(name, value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+	return name + `="` + escaped + `"`
+}
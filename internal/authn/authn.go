@@ -0,0 +1,88 @@
+// Package authn verifies primary login credentials. Each backend implements
+// Authenticator and the configured backends are tried in order by Chain.
+package authn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+)
+
+var (
+	// ErrInvalidCredentials means the backend knows the user but the
+	// password was wrong, or the user could not be found anywhere.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrUnknownUser means the backend has no such user and the next
+	// backend should be tried.
+	ErrUnknownUser = errors.New("unknown user")
+)
+
+type Authenticator interface {
+	// Name identifies the backend in audit entries.
+	Name() string
+	// Authenticate verifies the credentials and returns the local user,
+	// provisioning it first if the backend supports that.
+	Authenticate(ctx context.Context, username, password string) (*models.User, error)
+}
+
+// Chain tries each authenticator in order and returns the first success.
+type Chain []Authenticator
+
+func (c Chain) Name() string {
+	return "chain"
+}
+
+func (c Chain) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	user, _, err := c.AuthenticateWith(ctx, username, password)
+	return user, err
+}
+
+// AuthenticateWith is Authenticate that also reports which backend accepted
+// the credentials. A backend that rejected the password decides the outcome
+// even if another one failed, so an unreachable backend cannot turn a wrong
+// password into a server error; backend errors are only returned when no
+// backend could judge the credentials at all.
+func (c Chain) AuthenticateWith(ctx context.Context, username, password string) (*models.User, string, error) {
+	var lastErr error
+	rejected := false
+	for _, authenticator := range c {
+		user, err := authenticator.Authenticate(ctx, username, password)
+		switch {
+		case err == nil:
+			return user, authenticator.Name(), nil
+		case errors.Is(err, ErrInvalidCredentials):
+			rejected = true
+		case !errors.Is(err, ErrUnknownUser):
+			lastErr = err
+		}
+	}
+
+	if lastErr != nil && !rejected {
+		return nil, "", lastErr
+	}
+	return nil, "", ErrInvalidCredentials
+}
+
+// New builds the chain of backends listed in cfg.Auth.Providers.
+func New(cfg *config.Config) (Chain, error) {
+	chain := make(Chain, 0, len(cfg.Auth.Providers))
+	for _, name := range cfg.Auth.Providers {
+		switch name {
+		case "local":
+			chain = append(chain, NewLocalAuthenticator())
+		case "ldap":
+			chain = append(chain, NewLDAPAuthenticator(cfg.LDAP))
+		default:
+			return nil, fmt.Errorf("unknown authentication provider %q", name)
+		}
+	}
+
+	if len(chain) == 0 {
+		return nil, errors.New("no authentication providers configured")
+	}
+
+	return chain, nil
+}
@@ -0,0 +1,112 @@
+package authn
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPAuthenticator verifies passwords by binding to an LDAP directory as
+// the user. Users are found with a service account search, and their group
+// memberships decide their role.
+type LDAPAuthenticator struct {
+	cfg config.LDAPConfig
+}
+
+func NewLDAPAuthenticator(cfg config.LDAPConfig) *LDAPAuthenticator {
+	return &LDAPAuthenticator{cfg: cfg}
+}
+
+func (a *LDAPAuthenticator) Name() string {
+	return models.AuthProviderLDAP
+}
+
+func (a *LDAPAuthenticator) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	// An empty password would be an unauthenticated bind, which most
+	// directories accept without checking anything.
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := a.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if a.cfg.BindDN != "" {
+		if err := conn.Bind(a.cfg.BindDN, a.cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("ldap service bind failed: %w", err)
+		}
+	}
+
+	escaped := ldap.EscapeFilter(username)
+	filter := strings.ReplaceAll(a.cfg.UserFilter, "%s", escaped)
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.cfg.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2, 0, false,
+		filter,
+		[]string{"dn", a.cfg.EmailAttribute, a.cfg.NameAttribute, a.cfg.GroupAttribute},
+		nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("ldap search failed: %w", err)
+	}
+
+	if len(result.Entries) != 1 {
+		return nil, ErrUnknownUser
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap user bind failed: %w", err)
+	}
+
+	email := entry.GetAttributeValue(a.cfg.EmailAttribute)
+	if email == "" {
+		return nil, fmt.Errorf("ldap entry %s has no %s attribute", entry.DN, a.cfg.EmailAttribute)
+	}
+
+	role := MapRole(entry.GetAttributeValues(a.cfg.GroupAttribute), a.cfg.RoleMapping, a.cfg.DefaultRole)
+	if role == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	return Provision(ctx, ExternalIdentity{
+		Provider:     models.AuthProviderLDAP,
+		Subject:      entry.DN,
+		Email:        email,
+		Name:         entry.GetAttributeValue(a.cfg.NameAttribute),
+		Role:         role,
+		Organization: a.cfg.Organization,
+	})
+}
+
+func (a *LDAPAuthenticator) dial() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: a.cfg.InsecureSkipVerify}
+
+	conn, err := ldap.DialURL(a.cfg.URL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ldap: %w", err)
+	}
+
+	if a.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap starttls failed: %w", err)
+		}
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,36 @@
+package authn
+
+import (
+	"context"
+
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// LocalAuthenticator checks passwords stored as bcrypt hashes on the user.
+// Accounts provisioned by an external provider are left to that provider.
+type LocalAuthenticator struct{}
+
+func NewLocalAuthenticator() *LocalAuthenticator {
+	return &LocalAuthenticator{}
+}
+
+func (a *LocalAuthenticator) Name() string {
+	return models.AuthProviderLocal
+}
+
+func (a *LocalAuthenticator) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	var user models.User
+	if err := database.DB.WithContext(ctx).
+		Where("email = ? AND auth_provider = ?", username, models.AuthProviderLocal).
+		First(&user).Error; err != nil {
+		return nil, ErrUnknownUser
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &user, nil
+}
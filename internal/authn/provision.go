@@ -0,0 +1,130 @@
+package authn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/FRFebi/bot-management-backend/pkg/auth"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ErrAccountConflict means an existing account has the identity's email
+// but may not be linked to the identity.
+var ErrAccountConflict = errors.New("an account with this email already exists and cannot be linked automatically")
+
+// ExternalIdentity is a user as asserted by an external identity provider.
+type ExternalIdentity struct {
+	Provider string
+	Subject  string
+	Email    string
+	Name     string
+	Role     string
+	// Organization is the slug of the organization the user is placed in.
+	Organization string
+}
+
+// Provision finds the local account for an external identity, linking an
+// existing account with the same email on first login or creating one just
+// in time. Only accounts of the same provider that have no external
+// identity yet are linked this way; local accounts, super-admins, accounts
+// of another provider and accounts already linked to another identity
+// would be taken over by anyone able to assert their email, so Provision
+// returns ErrAccountConflict for them. The user's role in the
+// identity's organization is kept in sync with the provider on every
+// login.
+func Provision(ctx context.Context, identity ExternalIdentity) (*models.User, error) {
+	db := database.DB.WithContext(ctx)
+
+	var org models.Organization
+	if err := db.Where("slug = ?", identity.Organization).First(&org).Error; err != nil {
+		return nil, fmt.Errorf("failed to load organization %q: %w", identity.Organization, err)
+	}
+
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("auth_provider = ? AND external_id = ?", identity.Provider, identity.Subject).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = tx.Unscoped().Where("email = ?", identity.Email).First(&user).Error
+			if err == nil && (user.DeletedAt.Valid || user.AuthProvider != identity.Provider || user.IsSuperAdmin || user.ExternalID != nil) {
+				return ErrAccountConflict
+			}
+		}
+
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// Externally managed users never sign in with a local password;
+			// store a hash of random bytes so the column stays populated but
+			// unusable.
+			unusable, _, err := auth.GenerateOpaqueToken()
+			if err != nil {
+				return err
+			}
+			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(unusable), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+
+			name := identity.Name
+			if name == "" {
+				name = strings.Split(identity.Email, "@")[0]
+			}
+
+			subject := identity.Subject
+			user = models.User{
+				Name:         name,
+				Email:        identity.Email,
+				PasswordHash: string(hashedPassword),
+				Role:         identity.Role,
+				AuthProvider: identity.Provider,
+				ExternalID:   &subject,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case user.ExternalID == nil:
+			subject := identity.Subject
+			user.ExternalID = &subject
+			if err := tx.Model(&user).Update("external_id", subject).Error; err != nil {
+				return err
+			}
+		}
+
+		member := models.OrganizationMember{OrganizationID: org.ID, UserID: user.ID}
+		if err := tx.Where(&member).FirstOrInit(&member).Error; err != nil {
+			return err
+		}
+		member.Role = identity.Role
+		return tx.Save(&member).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// MapRole returns the highest role any of the groups maps to, falling back
+// to defaultRole. An empty result means the user should be denied.
+func MapRole(groups []string, mapping map[string]string, defaultRole string) string {
+	role := ""
+	for _, group := range groups {
+		switch mapping[group] {
+		case "admin":
+			return "admin"
+		case "viewer":
+			role = "viewer"
+		}
+	}
+
+	if role == "" {
+		return defaultRole
+	}
+	return role
+}
@@ -3,19 +3,45 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
-	Server ServerConfig
-	DB     DatabaseConfig
-	JWT    JWTConfig
-	Redis  RedisConfig
+	Server       ServerConfig
+	DB           DatabaseConfig
+	JWT          JWTConfig
+	Redis        RedisConfig
+	Registration RegistrationConfig
+	Notifier     NotifierConfig
+	Password     PasswordConfig
+	MFA          MFAConfig
+	OIDC         OIDCConfig
+	Auth         AuthConfig
+	LDAP         LDAPConfig
+	Login        LoginProtectionConfig
+	RateLimit    RateLimitConfig
+	Audit        AuditConfig
+	Artifacts    ArtifactConfig
+	Deploy       DeployConfig
 }
 
 type ServerConfig struct {
 	Port string
 	Host string
 	Env  string
+	// PublicURL is the address of the dashboard, used to build links sent
+	// to users such as invitations.
+	PublicURL string
+	// CORSOrigins lists the origins allowed to call the API from a browser.
+	CORSOrigins []string
+}
+
+// EnvProduction is the ENVIRONMENT value that enables the strict checks in
+// Validate.
+const EnvProduction = "production"
+
+func (s ServerConfig) IsProduction() bool {
+	return s.Env == EnvProduction
 }
 
 type DatabaseConfig struct {
@@ -27,9 +53,133 @@ type DatabaseConfig struct {
 	SSLMode  string
 }
 
+// JWTConfig configures token signing. Tokens are signed with asymmetric
+// keys (RS256 or EdDSA) stored in the database; SecretKey encrypts their
+// private halves at rest. A new key is generated every RotationDays and the
+// previous one keeps verifying tokens for OverlapHours.
 type JWTConfig struct {
-	SecretKey string
-	ExpiryHours int
+	SecretKey    string
+	ExpiryHours  int
+	Algorithm    string
+	RSAKeyBits   int
+	Issuer       string
+	Audience     []string
+	RotationDays int
+	OverlapHours int
+}
+
+// Registration modes for the public /auth/register endpoint.
+const (
+	RegistrationDisabled = "disabled"
+	RegistrationInvite   = "invite"
+	RegistrationOpen     = "open"
+)
+
+type RegistrationConfig struct {
+	// Mode is one of RegistrationDisabled, RegistrationInvite or
+	// RegistrationOpen. Open registrations always receive the viewer role.
+	Mode string
+	// AllowedDomains restricts open registrations to these email domains.
+	// Empty means any domain.
+	AllowedDomains    []string
+	InviteExpiryHours int
+}
+
+type NotifierConfig struct {
+	// Driver is "smtp", "file" or "log".
+	Driver       string
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// FilePath is where the file driver appends messages.
+	FilePath string
+}
+
+type PasswordConfig struct {
+	MinLength          int
+	ResetExpiryMinutes int
+	// ResetIntervalSeconds is the minimum time between two reset links
+	// mailed to the same account.
+	ResetIntervalSeconds int
+}
+
+type MFAConfig struct {
+	// Issuer is the account label shown in authenticator apps.
+	Issuer string
+	// EnforcedRoles lists roles that must enroll in TOTP before they can
+	// use the API.
+	EnforcedRoles    []string
+	ChallengeMinutes int
+}
+
+type OIDCConfig struct {
+	Enabled      bool
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the dashboard page the provider sends the user back
+	// to; it posts the code and state to /auth/oidc/callback.
+	RedirectURL string
+	Scopes      []string
+	// GroupsClaim names the ID token claim holding the user's groups.
+	GroupsClaim string
+	// RoleMapping maps provider groups to roles, e.g. "bot-admins" to
+	// "admin". When a user is in several mapped groups the highest role
+	// wins.
+	RoleMapping map[string]string
+	// DefaultRole is given to users in no mapped group. Empty denies them.
+	DefaultRole string
+	// Organization is the slug of the organization SSO users are placed in.
+	Organization string
+}
+
+type AuthConfig struct {
+	// Providers lists the password backends tried by login, in order:
+	// "local" and/or "ldap".
+	Providers []string
+}
+
+type LDAPConfig struct {
+	URL      string
+	StartTLS bool
+	// InsecureSkipVerify disables certificate checks; only for testing
+	// against self-signed directories.
+	InsecureSkipVerify bool
+	// BindDN and BindPassword are the service account used to look users
+	// up before binding as them.
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserFilter finds the user entry; %s is replaced by the escaped login
+	// name.
+	UserFilter     string
+	EmailAttribute string
+	NameAttribute  string
+	GroupAttribute string
+	// RoleMapping maps group DNs to roles. When a user is in several mapped
+	// groups the highest role wins.
+	RoleMapping map[string]string
+	// DefaultRole is given to users in no mapped group. Empty denies them.
+	DefaultRole string
+	// Organization is the slug of the organization LDAP users are placed in.
+	Organization string
+}
+
+// LoginProtectionConfig tunes brute-force protection. After FreeAttempts
+// consecutive failures each further attempt must wait BaseDelaySeconds,
+// doubling up to MaxDelaySeconds. Reaching the max failures locks the
+// account or IP for LockoutMinutes. Counters are forgotten after
+// WindowMinutes without failures.
+type LoginProtectionConfig struct {
+	FreeAttempts       int
+	BaseDelaySeconds   int
+	MaxDelaySeconds    int
+	AccountMaxFailures int
+	IPMaxFailures      int
+	LockoutMinutes     int
+	WindowMinutes      int
 }
 
 type RedisConfig struct {
@@ -39,12 +189,145 @@ type RedisConfig struct {
 	DB       int
 }
 
+// RateLimitConfig sets request budgets per route group. Authenticated
+// requests are counted per user, anonymous ones per client IP. Store is
+// "memory" or "redis"; use redis when running several replicas.
+type RateLimitConfig struct {
+	Enabled bool
+	Store   string
+	// Auth covers the public login, registration and reset endpoints.
+	Auth RateLimitRule
+	// Read covers GET requests to the API.
+	Read RateLimitRule
+	// Write covers other requests to the API.
+	Write RateLimitRule
+	// Lifecycle covers starting, stopping, restarting and deploying bots,
+	// on top of the write budget.
+	Lifecycle RateLimitRule
+}
+
+// Audit failure modes.
+const (
+	AuditFailureFail  = "fail"
+	AuditFailureRetry = "retry"
+)
+
+// AuditConfig controls the audit log. Every CheckpointIntervalMinutes the
+// head of the hash chain is signed; 0 disables periodic checkpoints.
+// TrustedKeysFile is a JWK Set of extra public keys that verify
+// checkpoints, for keys missing from the signing_keys table.
+//
+// Audit entries commit in the same transaction as the change they record.
+// FailureMode decides what happens when the entry cannot be written:
+// AuditFailureFail rolls the change back and fails the request, while
+// AuditFailureRetry commits the change and queues the entry in a durable
+// outbox retried every RetryIntervalSeconds.
+//
+// Entries older than RetentionDays are moved to compressed archives in
+// ArchiveDir every ArchiveIntervalHours, except those under a legal hold;
+// 0 keeps every entry online.
+//
+// Sinks names the external destinations ("syslog", "webhook", "file")
+// every committed entry is forwarded to, in batches of up to
+// SinkBatchSize checked for every SinkIntervalSeconds. Failed deliveries
+// are retried with a backoff of at most SinkMaxBackoffSeconds.
+type AuditConfig struct {
+	CheckpointIntervalMinutes int
+	TrustedKeysFile           string
+	FailureMode               string
+	RetryIntervalSeconds      int
+	RetentionDays             int
+	ArchiveDir                string
+	ArchiveIntervalHours      int
+	Sinks                     []string
+	SinkBatchSize             int
+	SinkIntervalSeconds       int
+	SinkMaxBackoffSeconds     int
+	Syslog                    AuditSyslogConfig
+	Webhook                   AuditWebhookConfig
+	File                      AuditFileConfig
+}
+
+// AuditSyslogConfig sends entries as RFC 5424 messages. Network is "tcp"
+// (octet-counted framing) or "udp" (one message per datagram). Over UDP,
+// messages longer than MaxMessageSize bytes carry a summary of the entry
+// instead of the full entry.
+type AuditSyslogConfig struct {
+	Network        string
+	Address        string
+	AppName        string
+	Facility       int
+	MaxMessageSize int
+}
+
+// AuditWebhookConfig posts batches of entries as a JSON array. When Secret
+// is set each request carries an HMAC-SHA256 signature of its body.
+type AuditWebhookConfig struct {
+	URL            string
+	Secret         string
+	TimeoutSeconds int
+}
+
+// AuditFileConfig appends entries as NDJSON, rotating the file once it
+// reaches MaxSizeMB and keeping MaxBackups rotated files.
+type AuditFileConfig struct {
+	Path       string
+	MaxSizeMB  int
+	MaxBackups int
+}
+
+// ArtifactConfig selects where uploaded bot artifacts are stored. Store is
+// "local", keeping blobs under LocalDir. Uploads larger than MaxSizeMB are
+// rejected.
+type ArtifactConfig struct {
+	Store     string
+	LocalDir  string
+	MaxSizeMB int
+}
+
+// DeployConfig tunes the health gate new deployments pass through. For
+// HealthWindowSeconds after a deploy the bot's health probe is called
+// every HealthIntervalSeconds and its runs are counted; the deployment is
+// rolled back after MaxProbeFailures failed probes in a row or when fewer
+// than MinRunSuccessPercent of its finished runs succeed. A zero window
+// disables the gate.
+//
+// Rolling deployments update a batch of replicas every RolloutStepSeconds
+// and start the window once all are updated. Canary deployments run the
+// window on a share of the replicas and are promoted only if, over at
+// least CanaryMinRuns runs, the canary's success rate is no more than
+// CanaryMaxSuccessDropPercent points below the old version's and its mean
+// run duration no more than CanaryMaxDurationIncreasePercent above it.
+//
+// Health probes only call public addresses, and those in
+// HealthProbeAllowedNetworks (CIDRs) for bots on internal networks.
+type DeployConfig struct {
+	HealthWindowSeconds              int
+	HealthIntervalSeconds            int
+	HealthProbeTimeoutSeconds        int
+	HealthProbeAllowedNetworks       []string
+	MaxProbeFailures                 int
+	MinRunSuccessPercent             int
+	RolloutStepSeconds               int
+	CanaryMinRuns                    int
+	CanaryMaxSuccessDropPercent      int
+	CanaryMaxDurationIncreasePercent int
+}
+
+// RateLimitRule allows Requests per WindowSeconds.
+type RateLimitRule struct {
+	Requests      int
+	WindowSeconds int
+}
+
 func New() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("PORT", "8080"),
-			Host: getEnv("HOST", "0.0.0.0"),
-			Env:  getEnv("ENVIRONMENT", "development"),
+			Port:        getEnv("PORT", "8080"),
+			Host:        getEnv("HOST", "0.0.0.0"),
+			Env:         getEnv("ENVIRONMENT", "development"),
+			PublicURL:   getEnv("PUBLIC_URL", "http://localhost:3000"),
+			CORSOrigins: getEnvAsSlice("CORS_ALLOWED_ORIGINS", []string{"*"}),
 		},
 		DB: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -55,8 +338,14 @@ func New() *Config {
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
 		JWT: JWTConfig{
-			SecretKey:   getEnv("JWT_SECRET", "your-secret-key"),
-			ExpiryHours: getEnvAsInt("JWT_EXPIRY_HOURS", 24),
+			SecretKey:    getEnv("JWT_SECRET", "your-secret-key"),
+			ExpiryHours:  getEnvAsInt("JWT_EXPIRY_HOURS", 24),
+			Algorithm:    getEnv("JWT_ALGORITHM", "RS256"),
+			RSAKeyBits:   getEnvAsInt("JWT_RSA_KEY_BITS", 2048),
+			Issuer:       getEnv("JWT_ISSUER", "bot-management-backend"),
+			Audience:     getEnvAsSlice("JWT_AUDIENCE", []string{"bot-management-api"}),
+			RotationDays: getEnvAsInt("JWT_KEY_ROTATION_DAYS", 30),
+			OverlapHours: getEnvAsInt("JWT_KEY_OVERLAP_HOURS", getEnvAsInt("JWT_EXPIRY_HOURS", 24)),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -64,6 +353,136 @@ func New() *Config {
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getEnvAsInt("REDIS_DB", 0),
 		},
+		Registration: RegistrationConfig{
+			Mode:              getEnv("REGISTRATION_MODE", RegistrationInvite),
+			AllowedDomains:    getEnvAsSlice("REGISTRATION_ALLOWED_DOMAINS", nil),
+			InviteExpiryHours: getEnvAsInt("INVITE_EXPIRY_HOURS", 72),
+		},
+		Notifier: NotifierConfig{
+			Driver:       getEnv("NOTIFIER_DRIVER", "log"),
+			From:         getEnv("NOTIFIER_FROM", "no-reply@localhost"),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     getEnv("SMTP_PORT", "587"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			FilePath:     getEnv("NOTIFIER_FILE_PATH", "notifications.log"),
+		},
+		Password: PasswordConfig{
+			MinLength:            getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
+			ResetExpiryMinutes:   getEnvAsInt("PASSWORD_RESET_EXPIRY_MINUTES", 60),
+			ResetIntervalSeconds: getEnvAsInt("PASSWORD_RESET_INTERVAL_SECONDS", 300),
+		},
+		MFA: MFAConfig{
+			Issuer:           getEnv("MFA_ISSUER", "Bot Management"),
+			EnforcedRoles:    getEnvAsSlice("MFA_ENFORCED_ROLES", nil),
+			ChallengeMinutes: getEnvAsInt("MFA_CHALLENGE_MINUTES", 5),
+		},
+		OIDC: OIDCConfig{
+			Enabled:      getEnvAsBool("OIDC_ENABLED", false),
+			IssuerURL:    getEnv("OIDC_ISSUER_URL", ""),
+			ClientID:     getEnv("OIDC_CLIENT_ID", ""),
+			ClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+			RedirectURL:  getEnv("OIDC_REDIRECT_URL", "http://localhost:3000/auth/oidc/callback"),
+			Scopes:       getEnvAsSlice("OIDC_SCOPES", []string{"openid", "profile", "email", "groups"}),
+			GroupsClaim:  getEnv("OIDC_GROUPS_CLAIM", "groups"),
+			RoleMapping:  getEnvAsMap("OIDC_ROLE_MAPPING", nil),
+			DefaultRole:  getEnv("OIDC_DEFAULT_ROLE", "viewer"),
+			Organization: getEnv("OIDC_ORGANIZATION", "default"),
+		},
+		Auth: AuthConfig{
+			Providers: getEnvAsSlice("AUTH_PROVIDERS", []string{"local"}),
+		},
+		LDAP: LDAPConfig{
+			URL:                getEnv("LDAP_URL", "ldap://localhost:389"),
+			StartTLS:           getEnvAsBool("LDAP_START_TLS", false),
+			InsecureSkipVerify: getEnvAsBool("LDAP_INSECURE_SKIP_VERIFY", false),
+			BindDN:             getEnv("LDAP_BIND_DN", ""),
+			BindPassword:       getEnv("LDAP_BIND_PASSWORD", ""),
+			BaseDN:             getEnv("LDAP_BASE_DN", ""),
+			UserFilter:         getEnv("LDAP_USER_FILTER", "(|(uid=%s)(mail=%s))"),
+			EmailAttribute:     getEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+			NameAttribute:      getEnv("LDAP_NAME_ATTRIBUTE", "cn"),
+			GroupAttribute:     getEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+			RoleMapping:        getEnvAsRoleMapping("LDAP_ROLE_MAPPING"),
+			DefaultRole:        getEnv("LDAP_DEFAULT_ROLE", ""),
+			Organization:       getEnv("LDAP_ORGANIZATION", "default"),
+		},
+		Login: LoginProtectionConfig{
+			FreeAttempts:       getEnvAsInt("LOGIN_FREE_ATTEMPTS", 3),
+			BaseDelaySeconds:   getEnvAsInt("LOGIN_BASE_DELAY_SECONDS", 1),
+			MaxDelaySeconds:    getEnvAsInt("LOGIN_MAX_DELAY_SECONDS", 60),
+			AccountMaxFailures: getEnvAsInt("LOGIN_ACCOUNT_MAX_FAILURES", 10),
+			IPMaxFailures:      getEnvAsInt("LOGIN_IP_MAX_FAILURES", 50),
+			LockoutMinutes:     getEnvAsInt("LOGIN_LOCKOUT_MINUTES", 15),
+			WindowMinutes:      getEnvAsInt("LOGIN_WINDOW_MINUTES", 15),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: getEnvAsBool("RATE_LIMIT_ENABLED", true),
+			Store:   getEnv("RATE_LIMIT_STORE", "memory"),
+			Auth: RateLimitRule{
+				Requests:      getEnvAsInt("RATE_LIMIT_AUTH_REQUESTS", 20),
+				WindowSeconds: getEnvAsInt("RATE_LIMIT_AUTH_WINDOW_SECONDS", 60),
+			},
+			Read: RateLimitRule{
+				Requests:      getEnvAsInt("RATE_LIMIT_READ_REQUESTS", 300),
+				WindowSeconds: getEnvAsInt("RATE_LIMIT_READ_WINDOW_SECONDS", 60),
+			},
+			Write: RateLimitRule{
+				Requests:      getEnvAsInt("RATE_LIMIT_WRITE_REQUESTS", 60),
+				WindowSeconds: getEnvAsInt("RATE_LIMIT_WRITE_WINDOW_SECONDS", 60),
+			},
+			Lifecycle: RateLimitRule{
+				Requests:      getEnvAsInt("RATE_LIMIT_LIFECYCLE_REQUESTS", 10),
+				WindowSeconds: getEnvAsInt("RATE_LIMIT_LIFECYCLE_WINDOW_SECONDS", 60),
+			},
+		},
+		Audit: AuditConfig{
+			CheckpointIntervalMinutes: getEnvAsInt("AUDIT_CHECKPOINT_INTERVAL_MINUTES", 60),
+			TrustedKeysFile:           getEnv("AUDIT_TRUSTED_KEYS_FILE", ""),
+			FailureMode:               getEnv("AUDIT_FAILURE_MODE", AuditFailureFail),
+			RetryIntervalSeconds:      getEnvAsInt("AUDIT_RETRY_INTERVAL_SECONDS", 30),
+			RetentionDays:             getEnvAsInt("AUDIT_RETENTION_DAYS", 0),
+			ArchiveDir:                getEnv("AUDIT_ARCHIVE_DIR", "./data/audit-archive"),
+			ArchiveIntervalHours:      getEnvAsInt("AUDIT_ARCHIVE_INTERVAL_HOURS", 24),
+			Sinks:                     getEnvAsSlice("AUDIT_SINKS", nil),
+			SinkBatchSize:             getEnvAsInt("AUDIT_SINK_BATCH_SIZE", 100),
+			SinkIntervalSeconds:       getEnvAsInt("AUDIT_SINK_INTERVAL_SECONDS", 2),
+			SinkMaxBackoffSeconds:     getEnvAsInt("AUDIT_SINK_MAX_BACKOFF_SECONDS", 300),
+			Syslog: AuditSyslogConfig{
+				Network:        getEnv("AUDIT_SYSLOG_NETWORK", "tcp"),
+				Address:        getEnv("AUDIT_SYSLOG_ADDRESS", ""),
+				AppName:        getEnv("AUDIT_SYSLOG_APP_NAME", "bot-management"),
+				Facility:       getEnvAsInt("AUDIT_SYSLOG_FACILITY", 13),
+				MaxMessageSize: getEnvAsInt("AUDIT_SYSLOG_MAX_MESSAGE_SIZE", 8192),
+			},
+			Webhook: AuditWebhookConfig{
+				URL:            getEnv("AUDIT_WEBHOOK_URL", ""),
+				Secret:         getEnv("AUDIT_WEBHOOK_SECRET", ""),
+				TimeoutSeconds: getEnvAsInt("AUDIT_WEBHOOK_TIMEOUT_SECONDS", 10),
+			},
+			File: AuditFileConfig{
+				Path:       getEnv("AUDIT_FILE_PATH", "./data/audit.log"),
+				MaxSizeMB:  getEnvAsInt("AUDIT_FILE_MAX_SIZE_MB", 100),
+				MaxBackups: getEnvAsInt("AUDIT_FILE_MAX_BACKUPS", 10),
+			},
+		},
+		Artifacts: ArtifactConfig{
+			Store:     getEnv("ARTIFACT_STORE", "local"),
+			LocalDir:  getEnv("ARTIFACT_DIR", "./data/artifacts"),
+			MaxSizeMB: getEnvAsInt("ARTIFACT_MAX_SIZE_MB", 100),
+		},
+		Deploy: DeployConfig{
+			HealthWindowSeconds:              getEnvAsInt("DEPLOY_HEALTH_WINDOW_SECONDS", 300),
+			HealthIntervalSeconds:            getEnvAsInt("DEPLOY_HEALTH_INTERVAL_SECONDS", 15),
+			HealthProbeTimeoutSeconds:        getEnvAsInt("DEPLOY_HEALTH_PROBE_TIMEOUT_SECONDS", 5),
+			HealthProbeAllowedNetworks:       getEnvAsSlice("DEPLOY_HEALTH_PROBE_ALLOWED_NETWORKS", nil),
+			MaxProbeFailures:                 getEnvAsInt("DEPLOY_MAX_PROBE_FAILURES", 3),
+			MinRunSuccessPercent:             getEnvAsInt("DEPLOY_MIN_RUN_SUCCESS_PERCENT", 90),
+			RolloutStepSeconds:               getEnvAsInt("DEPLOY_ROLLOUT_STEP_SECONDS", 60),
+			CanaryMinRuns:                    getEnvAsInt("DEPLOY_CANARY_MIN_RUNS", 5),
+			CanaryMaxSuccessDropPercent:      getEnvAsInt("DEPLOY_CANARY_MAX_SUCCESS_DROP_PERCENT", 5),
+			CanaryMaxDurationIncreasePercent: getEnvAsInt("DEPLOY_CANARY_MAX_DURATION_INCREASE_PERCENT", 20),
+		},
 	}
 }
 
@@ -81,4 +500,68 @@ func getEnvAsInt(key string, fallback int) int {
 		}
 	}
 	return fallback
-}
\ No newline at end of file
+}
+
+func getEnvAsSlice(key string, fallback []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvAsBool(key string, fallback bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return fallback
+}
+
+// getEnvAsMap parses comma-separated key:value pairs.
+func getEnvAsMap(key string, fallback map[string]string) map[string]string {
+	items := getEnvAsSlice(key, nil)
+	if items == nil {
+		return fallback
+	}
+
+	result := make(map[string]string, len(items))
+	for _, item := range items {
+		k, v, ok := strings.Cut(item, ":")
+		if !ok {
+			continue
+		}
+		result[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return result
+}
+
+// getEnvAsRoleMapping parses role mappings whose keys may themselves contain
+// commas, such as LDAP DNs: entries are separated by semicolons and the role
+// follows the last "=".
+//
+//	cn=admins,ou=groups,dc=example,dc=com=admin;cn=ops,ou=groups,dc=example,dc=com=viewer
+func getEnvAsRoleMapping(key string) map[string]string {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+
+	result := make(map[string]string)
+	for _, item := range strings.Split(value, ";") {
+		i := strings.LastIndex(item, "=")
+		if i <= 0 {
+			continue
+		}
+		result[strings.TrimSpace(item[:i])] = strings.TrimSpace(item[i+1:])
+	}
+	return result
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// minSecretLength is the shortest JWT_SECRET accepted in production. The
+// secret is hashed into an AES-256 key, so shorter values only weaken it.
+const minSecretLength = 32
+
+// minRSAKeyBits is the smallest RSA signing key accepted in production.
+const minRSAKeyBits = 2048
+
+// Well-known placeholder values that must never reach production.
+var defaultSecrets = []string{
+	"",
+	"your-secret-key",
+	"your-secret-key-change-in-production",
+	"changeme",
+	"secret",
+	"password",
+	"postgres",
+}
+
+// ValidationError lists every problem found in a configuration, so they
+// can all be fixed in one go instead of one restart at a time.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d configuration problem(s):", len(e.Problems))
+	for _, problem := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(problem)
+	}
+	return b.String()
+}
+
+// Validate checks the configuration for settings the server cannot run
+// with. When ENVIRONMENT=production it also rejects insecure defaults:
+// placeholder secrets, weak keys, unencrypted database connections and
+// wildcard CORS. It returns a *ValidationError listing every problem.
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	switch c.JWT.Algorithm {
+	case "RS256", "EdDSA":
+	default:
+		add("JWT_ALGORITHM must be RS256 or EdDSA, got %q", c.JWT.Algorithm)
+	}
+	if c.JWT.Issuer == "" {
+		add("JWT_ISSUER must not be empty")
+	}
+	if len(c.JWT.Audience) == 0 {
+		add("JWT_AUDIENCE must not be empty")
+	}
+	// A retired key has to verify every token it signed until they expire.
+	if c.JWT.OverlapHours < c.JWT.ExpiryHours {
+		add("JWT_KEY_OVERLAP_HOURS (%d) must be at least JWT_EXPIRY_HOURS (%d)", c.JWT.OverlapHours, c.JWT.ExpiryHours)
+	}
+
+	switch c.Audit.FailureMode {
+	case AuditFailureFail, AuditFailureRetry:
+	default:
+		add("AUDIT_FAILURE_MODE must be %s or %s, got %q", AuditFailureFail, AuditFailureRetry, c.Audit.FailureMode)
+	}
+	if c.Audit.RetentionDays < 0 {
+		add("AUDIT_RETENTION_DAYS must not be negative")
+	}
+	if c.Audit.RetentionDays > 0 && (c.Audit.ArchiveDir == "" || c.Audit.ArchiveIntervalHours <= 0) {
+		add("AUDIT_RETENTION_DAYS requires AUDIT_ARCHIVE_DIR and a positive AUDIT_ARCHIVE_INTERVAL_HOURS")
+	}
+	if len(c.Audit.Sinks) > 0 && (c.Audit.SinkBatchSize <= 0 || c.Audit.SinkIntervalSeconds <= 0 || c.Audit.SinkMaxBackoffSeconds <= 0) {
+		add("AUDIT_SINK_BATCH_SIZE, AUDIT_SINK_INTERVAL_SECONDS and AUDIT_SINK_MAX_BACKOFF_SECONDS must be positive")
+	}
+	for _, sink := range c.Audit.Sinks {
+		switch sink {
+		case "syslog":
+			if c.Audit.Syslog.Network != "tcp" && c.Audit.Syslog.Network != "udp" {
+				add("AUDIT_SYSLOG_NETWORK must be tcp or udp, got %q", c.Audit.Syslog.Network)
+			}
+			if c.Audit.Syslog.Address == "" {
+				add("AUDIT_SYSLOG_ADDRESS is required for the syslog sink")
+			}
+			if c.Audit.Syslog.Facility < 0 || c.Audit.Syslog.Facility > 23 {
+				add("AUDIT_SYSLOG_FACILITY must be between 0 and 23, got %d", c.Audit.Syslog.Facility)
+			}
+			// RFC 5426 receivers accept at least 2048 bytes, and the header
+			// of a summarized message must fit.
+			if c.Audit.Syslog.Network == "udp" && (c.Audit.Syslog.MaxMessageSize < 2048 || c.Audit.Syslog.MaxMessageSize > 65507) {
+				add("AUDIT_SYSLOG_MAX_MESSAGE_SIZE must be between 2048 and 65507, got %d", c.Audit.Syslog.MaxMessageSize)
+			}
+		case "webhook":
+			if c.Audit.Webhook.URL == "" {
+				add("AUDIT_WEBHOOK_URL is required for the webhook sink")
+			}
+		case "file":
+			if c.Audit.File.Path == "" || c.Audit.File.MaxSizeMB <= 0 {
+				add("AUDIT_FILE_PATH and a positive AUDIT_FILE_MAX_SIZE_MB are required for the file sink")
+			}
+		default:
+			add("AUDIT_SINKS may contain syslog, webhook and file, got %q", sink)
+		}
+	}
+
+	switch c.Artifacts.Store {
+	case "local":
+		if c.Artifacts.LocalDir == "" {
+			add("ARTIFACT_DIR is required for the local artifact store")
+		}
+	default:
+		add("ARTIFACT_STORE must be local, got %q", c.Artifacts.Store)
+	}
+	if c.Artifacts.MaxSizeMB <= 0 {
+		add("ARTIFACT_MAX_SIZE_MB must be positive")
+	}
+
+	if c.Deploy.HealthWindowSeconds < 0 {
+		add("DEPLOY_HEALTH_WINDOW_SECONDS must not be negative")
+	}
+	if c.Deploy.HealthWindowSeconds > 0 {
+		if c.Deploy.HealthIntervalSeconds <= 0 {
+			add("DEPLOY_HEALTH_INTERVAL_SECONDS must be positive")
+		}
+		if c.Deploy.HealthProbeTimeoutSeconds <= 0 {
+			add("DEPLOY_HEALTH_PROBE_TIMEOUT_SECONDS must be positive")
+		}
+		for _, network := range c.Deploy.HealthProbeAllowedNetworks {
+			if _, err := netip.ParsePrefix(network); err != nil {
+				add("DEPLOY_HEALTH_PROBE_ALLOWED_NETWORKS has an invalid network %q", network)
+			}
+		}
+		if c.Deploy.MaxProbeFailures <= 0 {
+			add("DEPLOY_MAX_PROBE_FAILURES must be positive")
+		}
+		if c.Deploy.MinRunSuccessPercent < 0 || c.Deploy.MinRunSuccessPercent > 100 {
+			add("DEPLOY_MIN_RUN_SUCCESS_PERCENT must be between 0 and 100")
+		}
+		if c.Deploy.RolloutStepSeconds <= 0 {
+			add("DEPLOY_ROLLOUT_STEP_SECONDS must be positive")
+		}
+		if c.Deploy.CanaryMinRuns <= 0 {
+			add("DEPLOY_CANARY_MIN_RUNS must be positive")
+		}
+		if c.Deploy.CanaryMaxSuccessDropPercent < 0 || c.Deploy.CanaryMaxSuccessDropPercent > 100 {
+			add("DEPLOY_CANARY_MAX_SUCCESS_DROP_PERCENT must be between 0 and 100")
+		}
+		if c.Deploy.CanaryMaxDurationIncreasePercent < 0 {
+			add("DEPLOY_CANARY_MAX_DURATION_INCREASE_PERCENT must not be negative")
+		}
+	}
+
+	if c.RateLimit.Enabled {
+		switch c.RateLimit.Store {
+		case "memory", "redis":
+		default:
+			add("RATE_LIMIT_STORE must be memory or redis, got %q", c.RateLimit.Store)
+		}
+		rules := map[string]RateLimitRule{
+			"AUTH":      c.RateLimit.Auth,
+			"READ":      c.RateLimit.Read,
+			"WRITE":     c.RateLimit.Write,
+			"LIFECYCLE": c.RateLimit.Lifecycle,
+		}
+		for _, name := range []string{"AUTH", "READ", "WRITE", "LIFECYCLE"} {
+			if rule := rules[name]; rule.Requests <= 0 || rule.WindowSeconds <= 0 {
+				add("RATE_LIMIT_%s_REQUESTS and RATE_LIMIT_%s_WINDOW_SECONDS must be positive", name, name)
+			}
+		}
+	}
+
+	if c.Server.IsProduction() {
+		if isDefaultSecret(c.JWT.SecretKey) {
+			add("JWT_SECRET is unset or a placeholder value")
+		} else if len(c.JWT.SecretKey) < minSecretLength {
+			add("JWT_SECRET must be at least %d characters, got %d", minSecretLength, len(c.JWT.SecretKey))
+		}
+		if c.JWT.Algorithm == "RS256" && c.JWT.RSAKeyBits < minRSAKeyBits {
+			add("JWT_RSA_KEY_BITS must be at least %d, got %d", minRSAKeyBits, c.JWT.RSAKeyBits)
+		}
+
+		if isDefaultSecret(c.DB.Password) {
+			add("DB_PASSWORD is unset or a placeholder value")
+		}
+		switch c.DB.SSLMode {
+		case "disable", "allow", "prefer":
+			add("DB_SSLMODE=%s does not guarantee an encrypted connection; use require, verify-ca or verify-full", c.DB.SSLMode)
+		}
+
+		if len(c.Server.CORSOrigins) == 0 {
+			add("CORS_ALLOWED_ORIGINS must list the dashboard origin")
+		} else if slices.Contains(c.Server.CORSOrigins, "*") {
+			add("CORS_ALLOWED_ORIGINS must not contain the wildcard \"*\"")
+		}
+
+		if c.Notifier.Driver == "smtp" && c.Notifier.SMTPUsername != "" && isDefaultSecret(c.Notifier.SMTPPassword) {
+			add("SMTP_PASSWORD is unset or a placeholder value")
+		}
+		if c.OIDC.Enabled && isDefaultSecret(c.OIDC.ClientSecret) {
+			add("OIDC_CLIENT_SECRET is unset or a placeholder value")
+		}
+		if slices.Contains(c.Auth.Providers, "ldap") {
+			if c.LDAP.InsecureSkipVerify {
+				add("LDAP_INSECURE_SKIP_VERIFY must not be enabled")
+			}
+			if strings.HasPrefix(c.LDAP.URL, "ldap://") && !c.LDAP.StartTLS {
+				add("LDAP_URL uses plain ldap:// without LDAP_START_TLS")
+			}
+		}
+		if slices.Contains(c.Audit.Sinks, "webhook") && !strings.HasPrefix(c.Audit.Webhook.URL, "https://") {
+			add("AUDIT_WEBHOOK_URL must use https://")
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func isDefaultSecret(value string) bool {
+	return slices.Contains(defaultSecrets, strings.ToLower(strings.TrimSpace(value)))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/FRFebi/bot-management-backend/internal/models"
+	"gorm.io/gorm"
 )
 
 func Migrate() error {
@@ -12,15 +13,261 @@ func Migrate() error {
 	}
 
 	err := DB.AutoMigrate(
+		&models.Organization{},
+		&models.OrganizationMember{},
 		&models.User{},
 		&models.Bot{},
 		&models.Schedule{},
 		&models.Run{},
 		&models.AuditLog{},
+		&models.Group{},
+		&models.BotACL{},
+		&models.Invite{},
+		&models.PasswordResetToken{},
+		&models.MFARecoveryCode{},
+		&models.OIDCLoginState{},
+		&models.LoginThrottle{},
+		&models.SigningKey{},
+		&models.AuditCheckpoint{},
+		&models.AuditOutbox{},
+		&models.AuditArchive{},
+		&models.LegalHold{},
+		&models.AuditSinkCursor{},
+		&models.BotConfigRevision{},
+		&models.Deployment{},
+		&models.Artifact{},
+		&models.TrustedKey{},
+		&models.BotReplica{},
+		&models.BotEnvironment{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	if err := restrictAuditUserDeletes(); err != nil {
+		return fmt.Errorf("failed to migrate audit log constraints: %w", err)
+	}
+
+	if err := backfillOrganizations(); err != nil {
+		return fmt.Errorf("failed to backfill organizations: %w", err)
+	}
+
+	if err := backfillAuditChain(); err != nil {
+		return fmt.Errorf("failed to backfill audit chain: %w", err)
+	}
+
+	if err := backfillBotConfigRevisions(); err != nil {
+		return fmt.Errorf("failed to backfill bot config revisions: %w", err)
+	}
+
+	if err := backfillDeployments(); err != nil {
+		return fmt.Errorf("failed to backfill deployments: %w", err)
+	}
+
+	if err := backfillBotReplicas(); err != nil {
+		return fmt.Errorf("failed to backfill bot replicas: %w", err)
+	}
+
+	if err := backfillBotEnvironments(); err != nil {
+		return fmt.Errorf("failed to backfill bot environments: %w", err)
+	}
+
+	// Audit searches filter by the bot an entry is about, which lives in
+	// its details.
+	if err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_audit_logs_bot_id ON audit_logs ((details->>'bot_id'))").Error; err != nil {
+		return fmt.Errorf("failed to index audit logs: %w", err)
+	}
+
 	return nil
+}
+
+// restrictAuditUserDeletes replaces the ON DELETE SET NULL foreign key from
+// audit entries to their user, created by earlier versions, with the
+// restricting one of the model. Nulling user_id would change hashed
+// entries and break the audit chain. AutoMigrate only creates missing
+// constraints, so an existing one is swapped here.
+func restrictAuditUserDeletes() error {
+	var rule string
+	if err := DB.Raw(`
+		SELECT rc.delete_rule
+		FROM information_schema.referential_constraints rc
+		JOIN information_schema.key_column_usage k ON k.constraint_name = rc.constraint_name
+		WHERE k.table_name = 'audit_logs' AND k.column_name = 'user_id'`).
+		Scan(&rule).Error; err != nil {
+		return err
+	}
+	if rule != "SET NULL" {
+		return nil
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().DropConstraint(&models.AuditLog{}, "User"); err != nil {
+			return err
+		}
+		return tx.Migrator().CreateConstraint(&models.AuditLog{}, "User")
+	})
+}
+
+// backfillOrganizations moves rows created before organizations existed into
+// the default organization and gives every user without a membership one
+// there, using the role stored on the user. Audit entries already in the
+// hash chain are left alone: their organization is hashed, and 0 is a
+// legitimate value for entries about users without one.
+func backfillOrganizations() error {
+	org, err := DefaultOrganization()
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []string{"bots", "schedules", "runs", "audit_logs", "groups"} {
+		query := DB.Table(table).Where("organization_id IS NULL OR organization_id = 0")
+		if table == "audit_logs" {
+			query = query.Where("sequence IS NULL")
+		}
+		if err := query.Update("organization_id", org.ID).Error; err != nil {
+			return fmt.Errorf("failed to backfill %s: %w", table, err)
+		}
+	}
+
+	return DB.Exec(`
+		INSERT INTO organization_members (organization_id, user_id, role, created_at, updated_at)
+		SELECT ?, users.id, users.role, NOW(), NOW()
+		FROM users
+		WHERE users.deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM organization_members m WHERE m.user_id = users.id)`,
+		org.ID,
+	).Error
+}
+
+// AuditChainLockID is the advisory lock serializing appends to the audit
+// hash chain.
+const AuditChainLockID = 0x61756474
+
+// backfillAuditChain links audit entries written before the hash chain
+// existed into it, in insertion order.
+func backfillAuditChain() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", AuditChainLockID).Error; err != nil {
+			return err
+		}
+
+		var last models.AuditLog
+		if err := tx.Select("sequence", "hash").
+			Where("sequence IS NOT NULL").
+			Order("sequence DESC").Limit(1).
+			Find(&last).Error; err != nil {
+			return err
+		}
+
+		for {
+			var entries []models.AuditLog
+			if err := tx.Select("id", "organization_id", "user_id", "action", "details", "created_at").
+				Where("sequence IS NULL").
+				Order("id ASC").Limit(500).
+				Find(&entries).Error; err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				return nil
+			}
+
+			for _, entry := range entries {
+				entry.Sequence = last.Sequence + 1
+				entry.PrevHash = last.Hash
+				entry.Hash = entry.ComputeHash()
+
+				if err := tx.Model(&models.AuditLog{}).Where("id = ?", entry.ID).Updates(map[string]interface{}{
+					"sequence":  entry.Sequence,
+					"prev_hash": entry.PrevHash,
+					"hash":      entry.Hash,
+				}).Error; err != nil {
+					return err
+				}
+				last = entry
+			}
+		}
+	})
+}
+
+// backfillBotConfigRevisions records the config of bots created before
+// config history existed as their first revision.
+func backfillBotConfigRevisions() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			INSERT INTO bot_config_revisions (bot_id, revision, config, message, author_id, created_at)
+			SELECT id, 1, config, 'Configuration before revision history', NULL, updated_at
+			FROM bots
+			WHERE config_revision = 0`).Error; err != nil {
+			return err
+		}
+
+		return tx.Exec("UPDATE bots SET config_revision = 1 WHERE config_revision = 0").Error
+	})
+}
+
+// backfillDeployments records the running version of bots deployed before
+// release history existed, so there is a release to roll back to.
+func backfillDeployments() error {
+	return DB.Exec(`
+		INSERT INTO deployments (organization_id, bot_id, version, config_revision, outcome, message, started_at, finished_at, created_at)
+		SELECT organization_id, id, version, config_revision, ?, 'Release before deployment history', updated_at, updated_at, updated_at
+		FROM bots
+		WHERE deleted_at IS NULL AND version <> ''
+		AND NOT EXISTS (SELECT 1 FROM deployments d WHERE d.bot_id = bots.id)`,
+		models.DeploymentSucceeded,
+	).Error
+}
+
+// backfillBotReplicas gives bots created before replicas existed their
+// single replica, running the bot's latest release.
+func backfillBotReplicas() error {
+	return DB.Exec(`
+		INSERT INTO bot_replicas (bot_id, number, deployment_id, version, artifact_digest, updated_at)
+		SELECT id, 0,
+			(SELECT MAX(d.id) FROM deployments d WHERE d.bot_id = bots.id AND d.environment = ? AND d.outcome = ?),
+			version, artifact_digest, NOW()
+		FROM bots
+		WHERE NOT EXISTS (SELECT 1 FROM bot_replicas r WHERE r.bot_id = bots.id)`,
+		models.EnvironmentProduction, models.DeploymentSucceeded,
+	).Error
+}
+
+// backfillBotEnvironments gives every bot its environments. Releases from
+// before environments existed are production releases; development and
+// staging start empty.
+func backfillBotEnvironments() error {
+	return DB.Exec(`
+		INSERT INTO bot_environments (bot_id, name, version, artifact_digest, config_revision, deployment_id, updated_at)
+		SELECT bots.id, envs.name,
+			CASE WHEN envs.name = ? THEN bots.version ELSE '' END,
+			CASE WHEN envs.name = ? THEN bots.artifact_digest ELSE '' END,
+			CASE WHEN envs.name = ? THEN bots.config_revision ELSE 0 END,
+			CASE WHEN envs.name = ? THEN
+				(SELECT MAX(d.id) FROM deployments d WHERE d.bot_id = bots.id AND d.environment = ? AND d.outcome = ?)
+			END,
+			NOW()
+		FROM bots CROSS JOIN (VALUES (?), (?), (?)) AS envs(name)
+		WHERE NOT EXISTS (SELECT 1 FROM bot_environments e WHERE e.bot_id = bots.id AND e.name = envs.name)`,
+		models.EnvironmentProduction, models.EnvironmentProduction, models.EnvironmentProduction,
+		models.EnvironmentProduction, models.EnvironmentProduction, models.DeploymentSucceeded,
+		models.EnvironmentDevelopment, models.EnvironmentStaging, models.EnvironmentProduction,
+	).Error
+}
+
+// DefaultOrganization returns the default organization, creating it if it
+// does not exist yet.
+func DefaultOrganization() (*models.Organization, error) {
+	if DB == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	org := models.Organization{
+		Name: "Default",
+		Slug: models.DefaultOrganizationSlug,
+	}
+	if err := DB.Where("slug = ?", org.Slug).FirstOrCreate(&org).Error; err != nil {
+		return nil, fmt.Errorf("failed to load default organization: %w", err)
+	}
+
+	return &org, nil
 }
\ No newline at end of file
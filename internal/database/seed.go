@@ -2,12 +2,20 @@ package database
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/FRFebi/bot-management-backend/internal/models"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/datatypes"
 )
 
+// Credentials of the accounts created by Seed. They are public, so a
+// production database must never contain them.
+var seedCredentials = map[string]string{
+	"admin@example.com":  "admin123",
+	"viewer@example.com": "viewer123",
+}
+
 func Seed() error {
 	if DB == nil {
 		return fmt.Errorf("database connection is nil")
@@ -22,7 +30,12 @@ func Seed() error {
 		return nil
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
+	org, err := DefaultOrganization()
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(seedCredentials["admin@example.com"]), bcrypt.DefaultCost)
 	if err != nil {
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
@@ -32,13 +45,17 @@ func Seed() error {
 		Email:        "admin@example.com",
 		PasswordHash: string(hashedPassword),
 		Role:         "admin",
+		IsSuperAdmin: true,
+		Memberships: []models.OrganizationMember{
+			{OrganizationID: org.ID, Role: "admin"},
+		},
 	}
 
 	if err := DB.Create(adminUser).Error; err != nil {
 		return fmt.Errorf("failed to create admin user: %w", err)
 	}
 
-	viewerPassword, err := bcrypt.GenerateFromPassword([]byte("viewer123"), bcrypt.DefaultCost)
+	viewerPassword, err := bcrypt.GenerateFromPassword([]byte(seedCredentials["viewer@example.com"]), bcrypt.DefaultCost)
 	if err != nil {
 		return fmt.Errorf("failed to hash viewer password: %w", err)
 	}
@@ -48,6 +65,9 @@ func Seed() error {
 		Email:        "viewer@example.com",
 		PasswordHash: string(viewerPassword),
 		Role:         "viewer",
+		Memberships: []models.OrganizationMember{
+			{OrganizationID: org.ID, Role: "viewer"},
+		},
 	}
 
 	if err := DB.Create(viewerUser).Error; err != nil {
@@ -55,9 +75,10 @@ func Seed() error {
 	}
 
 	sampleBot := &models.Bot{
-		Name:        "Sample Scraper Bot",
-		Description: "A sample web scraping bot for demonstration",
-		Version:     "1.0.0",
+		OrganizationID: org.ID,
+		Name:           "Sample Scraper Bot",
+		Description:    "A sample web scraping bot for demonstration",
+		Version:        "1.0.0",
 		Config: datatypes.JSON([]byte(`{
 			"target_url": "https://example.com",
 			"timeout": 30,
@@ -71,6 +92,7 @@ func Seed() error {
 	}
 
 	sampleSchedule := &models.Schedule{
+		OrganizationID: org.ID,
 		BotID:          sampleBot.ID,
 		CronExpression: "0 0 * * *",
 		IsActive:       true,
@@ -81,4 +103,30 @@ func Seed() error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// SeededCredentialsInUse returns the emails of active accounts that still
+// log in with the password Seed gave them.
+func SeededCredentialsInUse() ([]string, error) {
+	if DB == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var emails []string
+	for email, password := range seedCredentials {
+		var user models.User
+		err := DB.Where("email = ? AND is_active = ?", email, true).Limit(1).Find(&user).Error
+		if err != nil {
+			return nil, err
+		}
+		if user.ID == 0 || user.PasswordHash == "" {
+			continue
+		}
+		if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil {
+			emails = append(emails, email)
+		}
+	}
+
+	sort.Strings(emails)
+	return emails, nil
+}
@@ -0,0 +1,538 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/audit"
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/FRFebi/bot-management-backend/internal/notifier"
+	"github.com/FRFebi/bot-management-backend/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// notifyTimeout bounds delivery of a gate outcome to the deployer.
+const notifyTimeout = 30 * time.Second
+
+// Gate watches deployments left in progress by DeployBot. Each interval it
+// probes the bot's health check URL and counts the runs finished since the
+// deploy. A deployment that fails either check is marked failed and the
+// bot is returned to its previous successful release; one that stays
+// healthy until its window ends is marked succeeded. Either way the
+// deployer is notified.
+//
+// The gate also drives rolling and canary deployments: it moves the next
+// batch of a rolling deployment once the current one has run healthily for
+// a step, and compares a canary's runs with those of the replicas still on
+// the old version before promoting it to every replica.
+type Gate struct {
+	enabled          bool
+	window           time.Duration
+	interval         time.Duration
+	step             time.Duration
+	maxProbeFailures int
+	minSuccessRate   int
+	canaryMinRuns    int64
+	canaryMaxDrop    int
+	canaryMaxSlower  int
+	client           *http.Client
+	notifier         notifier.Notifier
+	log              *logger.Logger
+}
+
+// runStats summarizes finished runs.
+type runStats struct {
+	Succeeded  int64
+	Total      int64
+	AvgSeconds float64
+}
+
+// verdict is what a check decided, for notifying the deployer once the
+// transaction that recorded it has committed.
+type verdict struct {
+	deployment models.Deployment
+	bot        models.Bot
+	rollback   *models.Deployment
+}
+
+func NewGate(cfg config.DeployConfig, targets *ProbeTargets, notify notifier.Notifier) *Gate {
+	timeout := time.Duration(cfg.HealthProbeTimeoutSeconds) * time.Second
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: targets.control,
+	}
+
+	return &Gate{
+		enabled:          cfg.HealthWindowSeconds > 0,
+		window:           time.Duration(cfg.HealthWindowSeconds) * time.Second,
+		interval:         time.Duration(cfg.HealthIntervalSeconds) * time.Second,
+		step:             time.Duration(cfg.RolloutStepSeconds) * time.Second,
+		maxProbeFailures: cfg.MaxProbeFailures,
+		minSuccessRate:   cfg.MinRunSuccessPercent,
+		canaryMinRuns:    int64(cfg.CanaryMinRuns),
+		canaryMaxDrop:    cfg.CanaryMaxSuccessDropPercent,
+		canaryMaxSlower:  cfg.CanaryMaxDurationIncreasePercent,
+		client: &http.Client{
+			Timeout: timeout,
+			// No proxy: it would connect on the probe's behalf, past the
+			// destination check.
+			Transport: &http.Transport{
+				DialContext:       dialer.DialContext,
+				DisableKeepAlives: true,
+			},
+			// A redirect is an answer; a health check that moved should
+			// be fixed rather than followed.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		notifier: notify,
+		log:      logger.New(),
+	}
+}
+
+// Start checks deployments under the gate every interval. Nothing runs
+// when the gate is disabled.
+func (g *Gate) Start() {
+	if !g.enabled {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(g.interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := g.Run(); err != nil {
+				g.log.Errorf("Failed to run deployment health checks: %v", err)
+			}
+		}
+	}()
+}
+
+// Run checks every deployment under the gate once.
+func (g *Gate) Run() error {
+	var ids []uint
+	if err := database.DB.Model(&models.Deployment{}).
+		Where("outcome = ?", models.DeploymentInProgress).
+		Order("id").
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := g.check(id); err != nil {
+			g.log.Errorf("Failed to check deployment %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func (g *Gate) check(id uint) error {
+	var deployment models.Deployment
+	if err := database.DB.First(&deployment, id).Error; err != nil {
+		return err
+	}
+
+	var bot models.Bot
+	err := database.DB.First(&bot, deployment.BotID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	deleted := err != nil
+
+	// Probe before taking the row lock; a slow probe must not hold it.
+	var probeErr error
+	if !deleted {
+		probeErr = g.probe(bot.HealthCheckURL)
+	}
+
+	var result *verdict
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Another replica may be checking the same deployment, or it may
+		// have been superseded since it was read.
+		var locked models.Deployment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id = ? AND outcome = ?", id, models.DeploymentInProgress).
+			Limit(1).
+			Find(&locked).Error; err != nil {
+			return err
+		}
+		if locked.ID == 0 {
+			return nil
+		}
+
+		if deleted {
+			return finish(tx, &locked, models.DeploymentFailed, "Bot was deleted")
+		}
+
+		failures := 0
+		if probeErr != nil {
+			failures = locked.ProbeFailures + 1
+		}
+		locked.ProbeFailures = failures
+
+		var reason string
+		var candidate runStats
+		if failures >= g.maxProbeFailures {
+			reason = fmt.Sprintf("Health probe failed %d times in a row: %v", failures, probeErr)
+		} else {
+			var err error
+			if reason, candidate, err = g.judgeRuns(tx, &locked); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		if reason == "" && locked.Strategy == models.StrategyCanary && !now.Before(*locked.HealthCheckUntil) &&
+			candidate.Total < g.canaryMinRuns {
+			reason = fmt.Sprintf("Only %d canary runs finished; %d are needed to compare with the old version", candidate.Total, g.canaryMinRuns)
+		}
+
+		switch {
+		case reason != "":
+			rollback, err := g.fail(tx, &locked, reason)
+			if err != nil {
+				return err
+			}
+			result = &verdict{deployment: locked, bot: bot, rollback: rollback}
+			return nil
+		case locked.Strategy == models.StrategyRolling && locked.UpdatedReplicas < bot.Replicas:
+			return g.advance(tx, &locked, &bot)
+		case now.Before(*locked.HealthCheckUntil):
+			return tx.Model(&locked).Update("probe_failures", failures).Error
+		default:
+			// Every replica runs the release from here on; for a canary
+			// that compared well this is its promotion.
+			if err := assignReplicas(tx, &locked, bot.Replicas); err != nil {
+				return err
+			}
+			if err := finish(tx, &locked, models.DeploymentSucceeded, ""); err != nil {
+				return err
+			}
+			if err := writeGateAudit(tx, &locked, &bot, nil, nil, nil); err != nil {
+				return err
+			}
+			result = &verdict{deployment: locked, bot: bot}
+			return nil
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if result != nil {
+		g.notify(result)
+	}
+	return nil
+}
+
+// probe calls url and expects a 2xx answer. Bots without a health check
+// URL are judged by their runs alone. The error ends up in the outcome
+// reason and the deployer's notification, so it only says how the probe
+// failed; the details are logged.
+func (g *Gate) probe(url string) error {
+	if url == "" {
+		return nil
+	}
+
+	resp, err := g.client.Get(url)
+	if err != nil {
+		g.log.Infof("Health probe of %s failed: %v", url, err)
+
+		var netErr net.Error
+		switch {
+		case errors.Is(err, ErrProbeDestination):
+			return ErrProbeDestination
+		case errors.As(err, &netErr) && netErr.Timeout():
+			return errors.New("timed out")
+		default:
+			return errors.New("request failed")
+		}
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// judgeRuns checks the runs of deployment finished so far and returns why
+// it fails, or "" if it does not, along with its run statistics. Every
+// deployment must meet the minimum success rate. A canary with enough runs
+// must also hold up against the runs of the release it replaces over the
+// same period, when that release has enough runs to compare with.
+func (g *Gate) judgeRuns(tx *gorm.DB, deployment *models.Deployment) (string, runStats, error) {
+	// Runs of an all at once deployment are all of the bot's runs since
+	// the deploy, whether or not the runner tagged them; replicas run
+	// different releases during rolling and canary deployments, so only
+	// tagged runs count there.
+	query := tx.Where("deployment_id = ?", deployment.ID)
+	if deployment.Strategy == models.StrategyAllAtOnce {
+		query = tx.Where("deployment_id = ? OR (deployment_id IS NULL AND started_at >= ?)", deployment.ID, deployment.StartedAt)
+	}
+	candidate, err := finishedRuns(tx, deployment.BotID, query)
+	if err != nil {
+		return "", candidate, err
+	}
+
+	if candidate.Total > 0 && candidate.Succeeded*100 < candidate.Total*int64(g.minSuccessRate) {
+		return fmt.Sprintf("%d of %d runs succeeded, below the required %d%%",
+			candidate.Succeeded, candidate.Total, g.minSuccessRate), candidate, nil
+	}
+
+	if deployment.Strategy != models.StrategyCanary || deployment.BaselineID == nil || candidate.Total < g.canaryMinRuns {
+		return "", candidate, nil
+	}
+
+	baseline, err := finishedRuns(tx, deployment.BotID,
+		tx.Where("deployment_id = ? AND started_at >= ?", *deployment.BaselineID, deployment.StartedAt))
+	if err != nil || baseline.Total < g.canaryMinRuns {
+		return "", candidate, err
+	}
+
+	candidateRate := float64(candidate.Succeeded) * 100 / float64(candidate.Total)
+	baselineRate := float64(baseline.Succeeded) * 100 / float64(baseline.Total)
+	if candidateRate < baselineRate-float64(g.canaryMaxDrop) {
+		return fmt.Sprintf("Canary succeeded in %.1f%% of runs against %.1f%% for the old version", candidateRate, baselineRate), candidate, nil
+	}
+	if baseline.AvgSeconds > 0 && candidate.AvgSeconds > baseline.AvgSeconds*(1+float64(g.canaryMaxSlower)/100) {
+		return fmt.Sprintf("Canary runs took %.1fs on average against %.1fs for the old version", candidate.AvgSeconds, baseline.AvgSeconds), candidate, nil
+	}
+	return "", candidate, nil
+}
+
+// finishedRuns summarizes the bot's finished runs matching filter.
+func finishedRuns(tx *gorm.DB, botID uint, filter *gorm.DB) (runStats, error) {
+	var stats runStats
+	err := tx.Model(&models.Run{}).
+		Select("COUNT(*) FILTER (WHERE success) AS succeeded, COUNT(*) AS total, "+
+			"COALESCE(AVG(EXTRACT(EPOCH FROM finished_at - started_at)), 0) AS avg_seconds").
+		Where("bot_id = ? AND finished_at IS NOT NULL", botID).
+		Where(filter).
+		Scan(&stats).Error
+	return stats, err
+}
+
+// advance moves the next batch of a rolling deployment once the current
+// one has run for a step. When every replica is updated the health window
+// starts over, so the full rollout is watched for all of it.
+func (g *Gate) advance(tx *gorm.DB, deployment *models.Deployment, bot *models.Bot) error {
+	stepStarted := deployment.StartedAt
+	if deployment.StepStartedAt != nil {
+		stepStarted = *deployment.StepStartedAt
+	}
+	if time.Now().Before(stepStarted.Add(g.step)) {
+		return tx.Model(deployment).Update("probe_failures", deployment.ProbeFailures).Error
+	}
+
+	count := min(deployment.UpdatedReplicas+max(deployment.BatchSize, 1), bot.Replicas)
+	if err := assignReplicas(tx, deployment, count); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	updates := map[string]interface{}{
+		"step_started_at": now,
+		"probe_failures":  deployment.ProbeFailures,
+	}
+	if count == bot.Replicas {
+		updates["health_check_until"] = now.Add(g.window)
+	}
+	return tx.Model(deployment).Updates(updates).Error
+}
+
+// fail marks deployment failed and returns the bot to the last successful
+// release before it, recorded as a rollback deployment. That release's
+// artifact is checked as a manual rollback would be, so a verified-only
+// bot is only rolled back to an artifact still signed by a trusted key.
+// Without such a release the bot is stopped instead and the returned
+// deployment is nil.
+func (g *Gate) fail(tx *gorm.DB, deployment *models.Deployment, reason string) (*models.Deployment, error) {
+	// Lock the bot so that changes made meanwhile by handlers, which lock
+	// it too, are neither lost nor overwritten.
+	var bot models.Bot
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&bot, deployment.BotID).Error; err != nil {
+		return nil, err
+	}
+	before := bot
+
+	var previous models.Deployment
+	err := tx.Where("bot_id = ? AND environment = ? AND outcome = ? AND id < ?",
+		bot.ID, models.EnvironmentProduction, models.DeploymentSucceeded, deployment.ID).
+		Order("id DESC").
+		First(&previous).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	found := err == nil
+
+	var signer *models.TrustedKey
+	if found {
+		signer, err = releaseSigner(tx, &bot, &previous)
+		switch {
+		case err == nil:
+		case !errors.Is(err, ErrArtifactUnsigned) && !errors.Is(err, ErrSignatureUntrusted):
+			return nil, err
+		case bot.VerifiedOnly:
+			reason = fmt.Sprintf("%s. Release %s (deployment %d) was not restored: %v",
+				reason, previous.Version, previous.ID, err)
+			found = false
+		}
+	}
+
+	if err := finish(tx, deployment, models.DeploymentFailed, reason); err != nil {
+		return nil, err
+	}
+
+	if !found {
+		bot.Status = "stopped"
+		if err := tx.Model(&bot).Update("status", bot.Status).Error; err != nil {
+			return nil, err
+		}
+		return nil, writeGateAudit(tx, deployment, &bot, &before, nil, &reason)
+	}
+
+	message := fmt.Sprintf("Automatic rollback: deployment %d failed its health checks", deployment.ID)
+	if err := Restore(tx, &bot, &previous, message, nil); err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&bot).Updates(map[string]interface{}{
+		"version":         bot.Version,
+		"artifact_digest": bot.ArtifactDigest,
+		"config":          bot.Config,
+		"config_revision": bot.ConfigRevision,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	opts := Options{
+		RollbackOfID: &previous.ID,
+		Message:      message,
+	}
+	if signer != nil {
+		opts.SigningKeyID = &signer.ID
+	}
+	rollback, err := Record(tx, &bot, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return rollback, writeGateAudit(tx, deployment, &bot, &before, rollback, &reason)
+}
+
+// finish records the outcome of deployment.
+func finish(tx *gorm.DB, deployment *models.Deployment, outcome, reason string) error {
+	now := time.Now().UTC()
+	deployment.Outcome = outcome
+	deployment.OutcomeReason = reason
+	deployment.FinishedAt = &now
+
+	return tx.Model(deployment).Updates(map[string]interface{}{
+		"outcome":        outcome,
+		"outcome_reason": reason,
+		"finished_at":    now,
+		"probe_failures": deployment.ProbeFailures,
+	}).Error
+}
+
+// writeGateAudit records the gate's decision on deployment. It has no
+// caller, so the entry has no user; a failure carries the reason and, when
+// the bot was changed, the difference from before.
+func writeGateAudit(tx *gorm.DB, deployment *models.Deployment, bot, before *models.Bot, rollback *models.Deployment, reason *string) error {
+	details := map[string]interface{}{
+		"bot_id":        bot.ID,
+		"bot_name":      bot.Name,
+		"deployment_id": deployment.ID,
+		"version":       deployment.Version,
+		"artifact":      deployment.ArtifactDigest,
+		"outcome":       deployment.Outcome,
+		"strategy":      deployment.Strategy,
+	}
+	if reason != nil {
+		details["reason"] = *reason
+	}
+	if rollback != nil {
+		details["rollback_deployment_id"] = rollback.ID
+		details["rollback_of_id"] = rollback.RollbackOfID
+	}
+	data, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	entry := models.AuditLog{
+		OrganizationID: bot.OrganizationID,
+		Action:         "bot.deploy.health_gate",
+		Details:        data,
+		Outcome:        models.AuditOutcomeSuccess,
+	}
+	if deployment.Outcome != models.DeploymentSucceeded {
+		entry.Outcome = models.AuditOutcomeFailure
+	}
+	if before != nil {
+		entry.Changes = audit.Diff(*before, *bot, "created_at", "updated_at")
+	}
+	return audit.Write(tx, &entry)
+}
+
+// notify tells the deployer how their deployment ended.
+func (g *Gate) notify(result *verdict) {
+	if result.deployment.DeployerID == nil {
+		return
+	}
+
+	var deployer models.User
+	if err := database.DB.First(&deployer, *result.deployment.DeployerID).Error; err != nil {
+		g.log.Errorf("Failed to load deployer of deployment %d: %v", result.deployment.ID, err)
+		return
+	}
+
+	d := result.deployment
+	var msg notifier.Message
+	switch {
+	case d.Outcome == models.DeploymentSucceeded:
+		msg = notifier.Message{
+			To:      deployer.Email,
+			Subject: fmt.Sprintf("Deployment of %s %s succeeded", result.bot.Name, d.Version),
+			Body: fmt.Sprintf(
+				"Hello %s,\n\nDeployment %d of %s version %s passed its health checks and is now the current release.\n",
+				deployer.Name, d.ID, result.bot.Name, d.Version,
+			),
+		}
+	case result.rollback != nil:
+		msg = notifier.Message{
+			To:      deployer.Email,
+			Subject: fmt.Sprintf("Deployment of %s %s failed and was rolled back", result.bot.Name, d.Version),
+			Body: fmt.Sprintf(
+				"Hello %s,\n\nDeployment %d of %s version %s failed its health checks:\n\n%s\n\nThe bot was rolled back to version %s (deployment %d).\n",
+				deployer.Name, d.ID, result.bot.Name, d.Version, d.OutcomeReason, result.rollback.Version, *result.rollback.RollbackOfID,
+			),
+		}
+	default:
+		msg = notifier.Message{
+			To:      deployer.Email,
+			Subject: fmt.Sprintf("Deployment of %s %s failed", result.bot.Name, d.Version),
+			Body: fmt.Sprintf(
+				"Hello %s,\n\nDeployment %d of %s version %s failed its health checks:\n\n%s\n\nThere was no earlier release the bot could be rolled back to, so it was stopped.\n",
+				deployer.Name, d.ID, result.bot.Name, d.Version, d.OutcomeReason,
+			),
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	if err := g.notifier.Send(ctx, msg); err != nil {
+		g.log.Errorf("Failed to notify deployer of deployment %d: %v", d.ID, err)
+	}
+}
@@ -0,0 +1,102 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrProbeDestination is returned for health checks aimed at an address
+// the probe may not call.
+var ErrProbeDestination = errors.New("health check address is not allowed")
+
+// blockedNetworks are not private, loopback or link-local but still do not
+// reach the public internet: "this network", carrier-grade NAT and the
+// IPv4 benchmarking range.
+var blockedNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+}
+
+// ProbeTargets decides which addresses the health probe may call. Health
+// check URLs are set by bot admins but called from inside the deployment,
+// so only public addresses are allowed, unless the operator lists the
+// networks of their bots. Loopback, private and link-local addresses,
+// which include cloud metadata services, are refused otherwise.
+type ProbeTargets struct {
+	allowed []netip.Prefix
+}
+
+// NewProbeTargets allows the public internet and the given networks in
+// CIDR notation.
+func NewProbeTargets(networks []string) (*ProbeTargets, error) {
+	t := &ProbeTargets{}
+	for _, network := range networks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", network, err)
+		}
+		t.allowed = append(t.allowed, prefix.Masked())
+	}
+	return t, nil
+}
+
+// Allowed reports whether the probe may connect to addr.
+func (t *ProbeTargets) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range t.allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range blockedNetworks {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL checks that value is an absolute http or https URL whose host,
+// when it is an address or localhost, may be probed. Other host names are
+// checked when the probe connects, since they may resolve differently by
+// then.
+func (t *ProbeTargets) CheckURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("health check URL must be an absolute http or https URL")
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrProbeDestination
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !t.Allowed(addr) {
+		return ErrProbeDestination
+	}
+	return nil
+}
+
+// control refuses connections to addresses that may not be probed. It
+// runs after name resolution for every address dialed, so a host name
+// cannot be pointed at an internal address.
+func (t *ProbeTargets) control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !t.Allowed(addr) {
+		return ErrProbeDestination
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package deploy
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestProbeTargetsAllowed(t *testing.T) {
+	targets, err := NewProbeTargets([]string{"10.20.0.0/16", "fd00:1::1/64"})
+	if err != nil {
+		t.Fatalf("new probe targets: %v", err)
+	}
+
+	tests := []struct {
+		addr    string
+		allowed bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1::1", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.1", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"100.64.0.1", false},
+		{"198.18.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:93.184.216.34", true},
+		{"10.20.3.4", true},
+		{"::ffff:10.20.3.4", true},
+		{"10.21.0.1", false},
+		{"fd00:1::abcd", true},
+		{"fd00:2::1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := targets.Allowed(netip.MustParseAddr(tt.addr)); got != tt.allowed {
+				t.Errorf("Allowed(%s): expected %v, got %v", tt.addr, tt.allowed, got)
+			}
+		})
+	}
+}
+
+func TestNewProbeTargetsInvalidNetwork(t *testing.T) {
+	if _, err := NewProbeTargets([]string{"10.0.0.0"}); err == nil {
+		t.Error("expected a network without prefix length to be rejected")
+	}
+}
+
+func TestProbeTargetsCheckURL(t *testing.T) {
+	targets, err := NewProbeTargets([]string{"10.20.0.0/16"})
+	if err != nil {
+		t.Fatalf("new probe targets: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		valid       bool
+		destination bool
+	}{
+		{"public host", "https://bot.example.com/health", true, false},
+		{"public address", "http://93.184.216.34:8080/health", true, false},
+		{"allowed network", "http://10.20.1.1/health", true, false},
+		{"loopback", "http://127.0.0.1/health", false, true},
+		{"IPv6 loopback", "http://[::1]:8080/health", false, true},
+		{"metadata service", "http://169.254.169.254/latest/meta-data", false, true},
+		{"private address", "http://192.168.0.10/health", false, true},
+		{"localhost", "http://localhost:8080/health", false, true},
+		{"localhost with trailing dot", "http://LOCALHOST./health", false, true},
+		{"localhost subdomain", "http://bot.localhost/health", false, true},
+		{"other scheme", "ftp://bot.example.com/health", false, false},
+		{"relative", "/health", false, false},
+		{"no host", "http:///health", false, false},
+		{"unparsable", "http://[::1/health", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := targets.CheckURL(tt.url)
+			if tt.valid {
+				if err != nil {
+					t.Errorf("expected %s to be accepted, got %v", tt.url, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected %s to be rejected", tt.url)
+			}
+			if got := errors.Is(err, ErrProbeDestination); got != tt.destination {
+				t.Errorf("expected ErrProbeDestination %v for %s, got %v", tt.destination, tt.url, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+// Package deploy records bot releases and watches new ones through the
+// post-deploy health gate.
+package deploy
+
+import (
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Options describes a release being recorded.
+type Options struct {
+	DeployerID   *uint
+	RollbackOfID *uint
+	// PromotedFromID is the deployment in the previous environment whose
+	// release this one copies.
+	PromotedFromID *uint
+	// SigningKeyID is the trusted key that approved the artifact.
+	SigningKeyID *uint
+	Message      string
+	// HealthWindow, when positive, leaves the deployment in progress for
+	// the health gate to decide; otherwise it is recorded as succeeded.
+	HealthWindow time.Duration
+	// Strategy is one of the models.Strategy constants; rolling and
+	// canary deployments need a health window. BatchSize and
+	// CanaryPercent configure them.
+	Strategy      string
+	BatchSize     int
+	CanaryPercent int
+}
+
+// Record records the production release of bot's current artifact,
+// version and config revision. Releases take effect when the bot is saved
+// in the same transaction. Any deployment of bot still under the health gate is
+// superseded by this one. All at once deployments move every replica to
+// the release; rolling and canary ones start with their first batch or
+// the canary replicas, and the gate moves the rest.
+func Record(tx *gorm.DB, bot *models.Bot, opts Options) (*models.Deployment, error) {
+	strategy := opts.Strategy
+	if strategy == "" {
+		strategy = models.StrategyAllAtOnce
+	}
+
+	var baseline models.Deployment
+	if err := tx.Where("bot_id = ? AND environment = ? AND outcome = ?",
+		bot.ID, models.EnvironmentProduction, models.DeploymentSucceeded).
+		Order("id DESC").
+		Limit(1).
+		Find(&baseline).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	deployment := models.Deployment{
+		OrganizationID: bot.OrganizationID,
+		BotID:          bot.ID,
+		Environment:    models.EnvironmentProduction,
+		Version:        bot.Version,
+		ArtifactDigest: bot.ArtifactDigest,
+		SigningKeyID:   opts.SigningKeyID,
+		ConfigRevision: bot.ConfigRevision,
+		DeployerID:     opts.DeployerID,
+		RollbackOfID:   opts.RollbackOfID,
+		PromotedFromID: opts.PromotedFromID,
+		Outcome:        models.DeploymentSucceeded,
+		Message:        opts.Message,
+		Strategy:       strategy,
+		BatchSize:      opts.BatchSize,
+		CanaryPercent:  opts.CanaryPercent,
+		StartedAt:      now,
+		FinishedAt:     &now,
+	}
+	if baseline.ID != 0 {
+		deployment.BaselineID = &baseline.ID
+	}
+	if opts.HealthWindow > 0 {
+		until := now.Add(opts.HealthWindow)
+		deployment.Outcome = models.DeploymentInProgress
+		deployment.FinishedAt = nil
+		deployment.HealthCheckUntil = &until
+		deployment.StepStartedAt = &now
+	}
+
+	if err := tx.Model(&models.Deployment{}).
+		Where("bot_id = ? AND environment = ? AND outcome = ?", bot.ID, models.EnvironmentProduction, models.DeploymentInProgress).
+		Updates(map[string]interface{}{
+			"outcome":        models.DeploymentSuperseded,
+			"outcome_reason": "Superseded by a later deployment",
+			"finished_at":    now,
+		}).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Create(&deployment).Error; err != nil {
+		return nil, err
+	}
+
+	count := bot.Replicas
+	switch strategy {
+	case models.StrategyRolling:
+		count = min(opts.BatchSize, bot.Replicas)
+	case models.StrategyCanary:
+		count = canaryReplicas(bot.Replicas, opts.CanaryPercent)
+	}
+	if err := assignReplicas(tx, &deployment, count); err != nil {
+		return nil, err
+	}
+	if err := setEnvironment(tx, &deployment); err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
+
+// Release is what a deployment puts into an environment.
+type Release struct {
+	Version        string
+	ArtifactDigest string
+	ConfigRevision int
+}
+
+// RecordEnvironment records release as the current release of bot in env,
+// development or staging. These environments have no replicas or health
+// gate, so the deployment succeeds right away.
+func RecordEnvironment(tx *gorm.DB, bot *models.Bot, env string, release Release, opts Options) (*models.Deployment, error) {
+	now := time.Now().UTC()
+	deployment := models.Deployment{
+		OrganizationID: bot.OrganizationID,
+		BotID:          bot.ID,
+		Environment:    env,
+		Version:        release.Version,
+		ArtifactDigest: release.ArtifactDigest,
+		SigningKeyID:   opts.SigningKeyID,
+		ConfigRevision: release.ConfigRevision,
+		DeployerID:     opts.DeployerID,
+		RollbackOfID:   opts.RollbackOfID,
+		PromotedFromID: opts.PromotedFromID,
+		Outcome:        models.DeploymentSucceeded,
+		Message:        opts.Message,
+		Strategy:       models.StrategyAllAtOnce,
+		StartedAt:      now,
+		FinishedAt:     &now,
+	}
+	if err := tx.Create(&deployment).Error; err != nil {
+		return nil, err
+	}
+	if err := setEnvironment(tx, &deployment); err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
+
+// EnsureEnvironments creates the environments bot does not have yet. The
+// production environment starts with the bot's current release.
+func EnsureEnvironments(tx *gorm.DB, bot *models.Bot) error {
+	environments := make([]models.BotEnvironment, 0, len(models.Environments))
+	for _, name := range models.Environments {
+		env := models.BotEnvironment{BotID: bot.ID, Name: name}
+		if name == models.EnvironmentProduction {
+			env.Version = bot.Version
+			env.ArtifactDigest = bot.ArtifactDigest
+			env.ConfigRevision = bot.ConfigRevision
+		}
+		environments = append(environments, env)
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&environments).Error
+}
+
+// setEnvironment makes deployment the current release of its environment.
+func setEnvironment(tx *gorm.DB, deployment *models.Deployment) error {
+	return tx.Model(&models.BotEnvironment{}).
+		Where("bot_id = ? AND name = ?", deployment.BotID, deployment.Environment).
+		Updates(map[string]interface{}{
+			"version":         deployment.Version,
+			"artifact_digest": deployment.ArtifactDigest,
+			"config_revision": deployment.ConfigRevision,
+			"deployment_id":   deployment.ID,
+		}).Error
+}
+
+// NewConfigRevision records bot.Config as the bot's next revision and sets
+// bot.ConfigRevision to it; the caller saves bot in the same transaction.
+func NewConfigRevision(tx *gorm.DB, bot *models.Bot, message string, authorID *uint, rolledBackFrom *int) (*models.BotConfigRevision, error) {
+	revision, err := CreateConfigRevision(tx, bot, bot.Config, message, authorID, rolledBackFrom)
+	if err != nil {
+		return nil, err
+	}
+	bot.ConfigRevision = revision.Revision
+	return revision, nil
+}
+
+// CreateConfigRevision records config as the bot's next revision without
+// making it the production config. Revisions are numbered per bot across
+// environments, after the latest one rather than the bot's. The bot row
+// stays locked until the transaction ends, so concurrent changes get
+// consecutive numbers.
+func CreateConfigRevision(tx *gorm.DB, bot *models.Bot, config datatypes.JSON, message string, authorID *uint, rolledBackFrom *int) (*models.BotConfigRevision, error) {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		First(&models.Bot{}, bot.ID).Error; err != nil {
+		return nil, err
+	}
+
+	var latest int
+	if err := tx.Model(&models.BotConfigRevision{}).
+		Where("bot_id = ?", bot.ID).
+		Select("COALESCE(MAX(revision), 0)").
+		Scan(&latest).Error; err != nil {
+		return nil, err
+	}
+
+	revision := models.BotConfigRevision{
+		BotID:          bot.ID,
+		Revision:       latest + 1,
+		Config:         config,
+		Message:        message,
+		AuthorID:       authorID,
+		RolledBackFrom: rolledBackFrom,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// CurrentRevision returns the config revision env runs: the revision of
+// its current release, or the production revision when nothing has been
+// deployed to it.
+func CurrentRevision(tx *gorm.DB, bot *models.Bot, env string) (*models.BotConfigRevision, error) {
+	var environment models.BotEnvironment
+	if err := tx.Where("bot_id = ? AND name = ?", bot.ID, env).
+		Limit(1).
+		Find(&environment).Error; err != nil {
+		return nil, err
+	}
+
+	number := environment.ConfigRevision
+	if number == 0 {
+		number = bot.ConfigRevision
+	}
+
+	var revision models.BotConfigRevision
+	if err := tx.Where("bot_id = ? AND revision = ?", bot.ID, number).
+		First(&revision).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// RecordConfigChange records revision as a development release, keeping
+// development's version and artifact, or production's when nothing has
+// been deployed to development. Config changes reach staging and
+// production only by promotion.
+func RecordConfigChange(tx *gorm.DB, bot *models.Bot, revision int, opts Options) (*models.Deployment, error) {
+	var environment models.BotEnvironment
+	if err := tx.Where("bot_id = ? AND name = ?", bot.ID, models.EnvironmentDevelopment).
+		First(&environment).Error; err != nil {
+		return nil, err
+	}
+
+	release := Release{
+		Version:        environment.Version,
+		ArtifactDigest: environment.ArtifactDigest,
+		ConfigRevision: revision,
+	}
+	if release.Version == "" {
+		release.Version = bot.Version
+		release.ArtifactDigest = bot.ArtifactDigest
+	}
+	return RecordEnvironment(tx, bot, models.EnvironmentDevelopment, release, opts)
+}
+
+// Restore points bot at the release target: its artifact, version and,
+// when it differs, its config, which is restored as a new config revision
+// authored by authorID. The caller saves bot in the same transaction.
+func Restore(tx *gorm.DB, bot *models.Bot, target *models.Deployment, message string, authorID *uint) error {
+	if target.ConfigRevision != bot.ConfigRevision {
+		var revision models.BotConfigRevision
+		if err := tx.Where("bot_id = ? AND revision = ?", bot.ID, target.ConfigRevision).
+			First(&revision).Error; err != nil {
+			return err
+		}
+		bot.Config = revision.Config
+		if _, err := NewConfigRevision(tx, bot, message, authorID, &target.ConfigRevision); err != nil {
+			return err
+		}
+	}
+	bot.Version = target.Version
+	bot.ArtifactDigest = target.ArtifactDigest
+	return nil
+}
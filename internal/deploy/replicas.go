@@ -0,0 +1,66 @@
+package deploy
+
+import (
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// SyncReplicas makes bot's replica rows match bot.Replicas. Replicas
+// beyond the count are removed; new ones run the release of the bot's
+// first replica, or the bot's current release if it has none yet.
+func SyncReplicas(tx *gorm.DB, bot *models.Bot) error {
+	if err := tx.Where("bot_id = ? AND number >= ?", bot.ID, bot.Replicas).
+		Delete(&models.BotReplica{}).Error; err != nil {
+		return err
+	}
+
+	var replicas []models.BotReplica
+	if err := tx.Where("bot_id = ?", bot.ID).Order("number").Find(&replicas).Error; err != nil {
+		return err
+	}
+	if len(replicas) >= bot.Replicas {
+		return nil
+	}
+
+	template := models.BotReplica{Version: bot.Version, ArtifactDigest: bot.ArtifactDigest}
+	if len(replicas) > 0 {
+		template = replicas[0]
+	}
+
+	missing := make([]models.BotReplica, 0, bot.Replicas-len(replicas))
+	for number := len(replicas); number < bot.Replicas; number++ {
+		missing = append(missing, models.BotReplica{
+			BotID:          bot.ID,
+			Number:         number,
+			DeploymentID:   template.DeploymentID,
+			Version:        template.Version,
+			ArtifactDigest: template.ArtifactDigest,
+		})
+	}
+	return tx.Create(&missing).Error
+}
+
+// assignReplicas moves the first count replicas of the deployment's bot
+// to its release and records how many run it.
+func assignReplicas(tx *gorm.DB, deployment *models.Deployment, count int) error {
+	if err := tx.Model(&models.BotReplica{}).
+		Where("bot_id = ? AND number < ?", deployment.BotID, count).
+		Updates(map[string]interface{}{
+			"deployment_id":   deployment.ID,
+			"version":         deployment.Version,
+			"artifact_digest": deployment.ArtifactDigest,
+		}).Error; err != nil {
+		return err
+	}
+
+	deployment.UpdatedReplicas = count
+	return tx.Model(deployment).Update("updated_replicas", count).Error
+}
+
+// canaryReplicas is the number of replicas out of replicas that run a
+// canary of percent: at least one, and at least one left on the old
+// version to compare with.
+func canaryReplicas(replicas, percent int) int {
+	count := (replicas*percent + 99) / 100
+	return max(1, min(count, replicas-1))
+}
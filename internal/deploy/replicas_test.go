@@ -0,0 +1,28 @@
+package deploy
+
+import "testing"
+
+func TestCanaryReplicas(t *testing.T) {
+	tests := []struct {
+		name     string
+		replicas int
+		percent  int
+		want     int
+	}{
+		{"rounds up", 10, 15, 2},
+		{"exact", 10, 20, 2},
+		{"at least one", 10, 1, 1},
+		{"zero percent", 10, 0, 1},
+		{"leaves one stable replica", 10, 100, 9},
+		{"two replicas", 2, 50, 1},
+		{"many replicas", 200, 5, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canaryReplicas(tt.replicas, tt.percent); got != tt.want {
+				t.Errorf("canaryReplicas(%d, %d): expected %d, got %d", tt.replicas, tt.percent, tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package deploy
+
+import (
+	"errors"
+
+	"github.com/FRFebi/bot-management-backend/internal/artifacts"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrArtifactUnsigned   = errors.New("artifact is not signed")
+	ErrSignatureUntrusted = errors.New("artifact signature does not validate against any trusted key")
+)
+
+// Signer returns the active trusted key of the bot's organization whose
+// signature artifact carries.
+func Signer(tx *gorm.DB, bot *models.Bot, artifact *models.Artifact) (*models.TrustedKey, error) {
+	if artifact.Signature == "" {
+		return nil, ErrArtifactUnsigned
+	}
+	signature, err := artifacts.ParseSignature(artifact.Signature)
+	if err != nil {
+		return nil, ErrSignatureUntrusted
+	}
+
+	var keys []models.TrustedKey
+	if err := tx.Where("organization_id = ? AND revoked_at IS NULL", bot.OrganizationID).
+		Find(&keys).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range keys {
+		publicKey, err := artifacts.ParsePublicKey(keys[i].PublicKey)
+		if err != nil {
+			continue
+		}
+		if artifacts.VerifySignature(publicKey, artifact.Digest, signature) {
+			return &keys[i], nil
+		}
+	}
+	return nil, ErrSignatureUntrusted
+}
+
+// releaseSigner returns the trusted key that signed the artifact of
+// release, looked up among the bot's uploads. Releases from before
+// artifacts were uploaded have none and count as unsigned.
+func releaseSigner(tx *gorm.DB, bot *models.Bot, release *models.Deployment) (*models.TrustedKey, error) {
+	artifact := models.Artifact{Digest: release.ArtifactDigest}
+	if release.ArtifactDigest != "" {
+		if err := tx.Where("bot_id = ? AND digest = ?", bot.ID, release.ArtifactDigest).
+			First(&artifact).Error; err != nil {
+			return nil, err
+		}
+	}
+	return Signer(tx, bot, &artifact)
+}
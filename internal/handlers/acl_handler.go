@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type ACLHandler struct{}
+
+func NewACLHandler() *ACLHandler {
+	return &ACLHandler{}
+}
+
+type CreateACLRequest struct {
+	UserID     *uint                `json:"user_id"`
+	GroupID    *uint                `json:"group_id"`
+	Permission models.BotPermission `json:"permission"`
+}
+
+type TransferOwnershipRequest struct {
+	UserID uint `json:"user_id"`
+}
+
+func (h *ACLHandler) GetBotACL(c *fiber.Ctx) error {
+	bot, ok := h.managedBot(c)
+	if !ok {
+		return nil
+	}
+
+	var entries []models.BotACL
+	if err := database.DB.Preload("User").Preload("Group").
+		Where("bot_id = ?", bot.ID).
+		Order("created_at ASC").
+		Find(&entries).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch access list",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"owner_id": bot.OwnerID,
+		"entries":  entries,
+	})
+}
+
+func (h *ACLHandler) CreateBotACL(c *fiber.Ctx) error {
+	bot, ok := h.managedBot(c)
+	if !ok {
+		return nil
+	}
+
+	var req CreateACLRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if (req.UserID == nil) == (req.GroupID == nil) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Exactly one of user_id or group_id is required",
+		})
+	}
+
+	if !req.Permission.Valid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Permission must be one of read, operate, deploy, manage",
+		})
+	}
+
+	if req.UserID != nil {
+		if !isOrgMember(c, *req.UserID) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "User not found",
+			})
+		}
+	} else {
+		if err := database.DB.Scopes(inOrganization(c)).First(&models.Group{}, *req.GroupID).Error; err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Group not found",
+			})
+		}
+	}
+
+	// Granting a permission to a principal that already has an entry
+	// replaces the existing entry rather than stacking a second one.
+	query := database.DB.Where("bot_id = ?", bot.ID)
+	if req.UserID != nil {
+		query = query.Where("user_id = ?", *req.UserID)
+	} else {
+		query = query.Where("group_id = ?", *req.GroupID)
+	}
+
+	var entry models.BotACL
+	if err := query.First(&entry).Error; err != nil {
+		entry = models.BotACL{
+			BotID:   bot.ID,
+			UserID:  req.UserID,
+			GroupID: req.GroupID,
+		}
+	}
+	entry.Permission = req.Permission
+
+	c.Status(fiber.StatusCreated)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&entry).Error; err != nil {
+			return err
+		}
+		return logAudit(c, tx, "bot.acl.grant", fiber.Map{
+			"bot_id":     bot.ID,
+			"bot_name":   bot.Name,
+			"user_id":    req.UserID,
+			"group_id":   req.GroupID,
+			"permission": req.Permission,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save access entry",
+		})
+	}
+
+	return c.JSON(entry)
+}
+
+func (h *ACLHandler) DeleteBotACL(c *fiber.Ctx) error {
+	bot, ok := h.managedBot(c)
+	if !ok {
+		return nil
+	}
+
+	entryID, err := strconv.ParseUint(c.Params("entryId"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid access entry ID",
+		})
+	}
+
+	var entry models.BotACL
+	if err := database.DB.Where("bot_id = ?", bot.ID).First(&entry, entryID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Access entry not found",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&entry).Error; err != nil {
+			return err
+		}
+		return logAudit(c, tx, "bot.acl.revoke", fiber.Map{
+			"bot_id":     bot.ID,
+			"bot_name":   bot.Name,
+			"user_id":    entry.UserID,
+			"group_id":   entry.GroupID,
+			"permission": entry.Permission,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete access entry",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Access entry deleted successfully",
+	})
+}
+
+func (h *ACLHandler) TransferOwnership(c *fiber.Ctx) error {
+	bot, ok := h.managedBot(c)
+	if !ok {
+		return nil
+	}
+
+	var req TransferOwnershipRequest
+	if err := c.BodyParser(&req); err != nil || req.UserID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "user_id is required",
+		})
+	}
+
+	if !isOrgMember(c, req.UserID) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	previousOwnerID := bot.OwnerID
+	bot.OwnerID = &req.UserID
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(bot).Update("owner_id", req.UserID).Error; err != nil {
+			return err
+		}
+		return logAudit(c, tx, "bot.owner.transfer", fiber.Map{
+			"bot_id":         bot.ID,
+			"bot_name":       bot.Name,
+			"previous_owner": previousOwnerID,
+			"new_owner":      req.UserID,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to transfer ownership",
+		})
+	}
+
+	return c.JSON(bot)
+}
+
+// managedBot loads the bot named in the route and ensures the caller may
+// manage it. When it returns false the error response has already been
+// written.
+func (h *ACLHandler) managedBot(c *fiber.Ctx) (*models.Bot, bool) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid bot ID",
+		})
+		return nil, false
+	}
+
+	var bot models.Bot
+	if err := database.DB.Scopes(inOrganization(c)).First(&bot, id).Error; err != nil {
+		c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Bot not found",
+		})
+		return nil, false
+	}
+
+	if !canAccessBot(c, &bot, models.BotPermissionManage) {
+		c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Insufficient permissions for this bot",
+		})
+		return nil, false
+	}
+
+	return &bot, true
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/FRFebi/bot-management-backend/internal/artifacts"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// multipartOverhead is how much larger than the artifact size limit an
+// upload's body may be, for the multipart framing and the other fields.
+const multipartOverhead = 1 << 20
+
+// ArtifactHandler accepts the scripts, binaries and tarballs bots run and
+// serves them back. Content is kept in a content-addressed store, so the
+// same bytes uploaded twice, even for different bots, are stored once.
+type ArtifactHandler struct {
+	store   artifacts.Store
+	maxSize int64
+}
+
+func NewArtifactHandler(store artifacts.Store, maxSize int64) *ArtifactHandler {
+	return &ArtifactHandler{
+		store:   store,
+		maxSize: maxSize,
+	}
+}
+
+func (h *ArtifactHandler) GetArtifacts(c *fiber.Ctx) error {
+	bot, ok := loadBot(c, models.BotPermissionRead, "bot.artifacts.read")
+	if !ok {
+		return nil
+	}
+
+	var list []models.Artifact
+	if err := database.DB.Preload("UploadedBy").
+		Where("bot_id = ?", bot.ID).
+		Order("id DESC").
+		Find(&list).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch artifacts",
+		})
+	}
+
+	return c.JSON(list)
+}
+
+// UploadArtifact stores the multipart "file" field as an artifact of the
+// bot. The kind is detected from the content unless given in the "kind"
+// field. An optional "signature" field carries the base64 detached ed25519
+// signature of the artifact's digest. Uploading content the bot already
+// has returns the existing artifact with 200 instead of 201, taking on
+// the new signature if one is given.
+func (h *ArtifactHandler) UploadArtifact(c *fiber.Ctx) error {
+	// The body is streamed past the global limit, so check its declared
+	// size before the form is read. Allow for the multipart framing.
+	switch length := c.Request().Header.ContentLength(); {
+	case length < 0:
+		c.Context().SetConnectionClose()
+		return c.Status(fiber.StatusLengthRequired).JSON(fiber.Map{
+			"error": "Content-Length is required",
+		})
+	case int64(length) > h.maxSize+multipartOverhead:
+		c.Context().SetConnectionClose()
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": "File exceeds the artifact size limit",
+		})
+	}
+
+	bot, ok := loadBot(c, models.BotPermissionDeploy, "bot.artifact.upload")
+	if !ok {
+		return nil
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "File is required",
+		})
+	}
+	if header.Size == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "File is empty",
+		})
+	}
+	if header.Size > h.maxSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": "File exceeds the artifact size limit",
+		})
+	}
+
+	signature := strings.TrimSpace(c.FormValue("signature"))
+	if signature != "" {
+		if _, err := artifacts.ParseSignature(signature); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to read file",
+		})
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to read file",
+		})
+	}
+
+	kind := c.FormValue("kind")
+	if kind == "" {
+		kind = artifacts.DetectKind(head[:n])
+	}
+	if !artifacts.ValidKind(kind) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Could not determine artifact kind; kind must be script, binary or tarball",
+		})
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to store artifact",
+		})
+	}
+	digest, size, err := artifacts.Digest(file)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to store artifact",
+		})
+	}
+
+	var existing models.Artifact
+	err = database.DB.Where("bot_id = ? AND digest = ?", bot.ID, digest).First(&existing).Error
+	if err == nil {
+		return h.resign(c, bot, &existing, signature)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to store artifact",
+		})
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to store artifact",
+		})
+	}
+	if err := h.store.Put(c.UserContext(), digest, file); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to store artifact",
+		})
+	}
+
+	userID := c.Locals("userID").(uint)
+	artifact := models.Artifact{
+		OrganizationID: bot.OrganizationID,
+		BotID:          bot.ID,
+		Digest:         digest,
+		Size:           size,
+		Filename:       filepath.Base(header.Filename),
+		Kind:           kind,
+		Signature:      signature,
+		UploadedByID:   &userID,
+	}
+
+	c.Status(fiber.StatusCreated)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&artifact).Error; err != nil {
+			return err
+		}
+		return logAudit(c, tx, "bot.artifact.upload", fiber.Map{
+			"bot_id":      bot.ID,
+			"bot_name":    bot.Name,
+			"artifact_id": artifact.ID,
+			"digest":      digest,
+			"size":        size,
+			"kind":        kind,
+			"signed":      signature != "",
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to store artifact",
+		})
+	}
+
+	return c.JSON(artifact)
+}
+
+// resign replaces the signature of an artifact uploaded again. The content
+// is unchanged, so without a new signature there is nothing to do.
+func (h *ArtifactHandler) resign(c *fiber.Ctx, bot *models.Bot, artifact *models.Artifact, signature string) error {
+	if signature == "" || signature == artifact.Signature {
+		return c.JSON(artifact)
+	}
+
+	artifact.Signature = signature
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(artifact).Update("signature", signature).Error; err != nil {
+			return err
+		}
+		return logAudit(c, tx, "bot.artifact.sign", fiber.Map{
+			"bot_id":      bot.ID,
+			"bot_name":    bot.Name,
+			"artifact_id": artifact.ID,
+			"digest":      artifact.Digest,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to store artifact",
+		})
+	}
+
+	return c.JSON(artifact)
+}
+
+// DownloadArtifact streams the content of the bot's artifact with the
+// digest in the route.
+func (h *ArtifactHandler) DownloadArtifact(c *fiber.Ctx) error {
+	bot, ok := loadBot(c, models.BotPermissionDeploy, "bot.artifact.download")
+	if !ok {
+		return nil
+	}
+
+	artifact, ok := botArtifact(c, bot, c.Params("digest"))
+	if !ok {
+		return nil
+	}
+
+	content, err := h.store.Open(c.UserContext(), artifact.Digest)
+	if errors.Is(err, artifacts.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Artifact content not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to read artifact",
+		})
+	}
+
+	c.Attachment(artifact.Filename)
+	c.Set(fiber.HeaderContentType, fiber.MIMEOctetStream)
+	return c.SendStream(content, int(artifact.Size))
+}
+
+// botArtifact loads bot's artifact with digest. When it returns false the
+// error response has already been written.
+func botArtifact(c *fiber.Ctx, bot *models.Bot, digest string) (*models.Artifact, bool) {
+	if !artifacts.ValidDigest(digest) {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid artifact digest",
+		})
+		return nil, false
+	}
+
+	var artifact models.Artifact
+	if err := database.DB.Where("bot_id = ? AND digest = ?", bot.ID, digest).
+		First(&artifact).Error; err != nil {
+		c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Artifact not found",
+		})
+		return nil, false
+	}
+
+	return &artifact, true
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"github.com/FRFebi/bot-management-backend/internal/audit"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/FRFebi/bot-management-backend/pkg/logger"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// auditIgnoredFields are bookkeeping fields left out of audit diffs.
+var auditIgnoredFields = []string{"created_at", "updated_at"}
+
+// logAudit records a successful action by the authenticated caller in db,
+// the transaction of the change it records, so both commit or neither
+// does. The response status set so far is recorded as the outcome, so
+// handlers that answer with something other than 200 set it before
+// logging. An error means the change must be rolled back and the request
+// failed.
+func logAudit(c *fiber.Ctx, db *gorm.DB, action string, details fiber.Map) error {
+	return logAuditChange(c, db, action, details, nil, nil)
+}
+
+// logAuditChange records a successful action that changed a resource,
+// with the difference between its before and after versions. Either may
+// be nil for a resource that was created or deleted.
+func logAuditChange(c *fiber.Ctx, db *gorm.DB, action string, details fiber.Map, before, after interface{}) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return nil
+	}
+
+	return writeAudit(c, db, models.AuditLog{
+		OrganizationID: currentOrgID(c),
+		UserID:         &userID,
+		Action:         action,
+		Details:        auditDetails(details),
+		Changes:        audit.Diff(before, after, auditIgnoredFields...),
+		StatusCode:     c.Response().StatusCode(),
+	})
+}
+
+// logAuditFailure records an action by the authenticated caller that was
+// refused or failed with status. Nothing is committed alongside it, and
+// the request fails either way, so a write error is only logged.
+func logAuditFailure(c *fiber.Ctx, action string, status int, details fiber.Map) {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return
+	}
+
+	writeAudit(c, database.DB, models.AuditLog{
+		OrganizationID: currentOrgID(c),
+		UserID:         &userID,
+		Action:         action,
+		Details:        auditDetails(details),
+		StatusCode:     status,
+	})
+}
+
+// recordAudit writes an audit entry in db for requests that have no
+// authenticated caller in the context, such as logins and password resets.
+func recordAudit(c *fiber.Ctx, db *gorm.DB, orgID uint, userID *uint, action string, status int, details fiber.Map) error {
+	return writeAudit(c, db, models.AuditLog{
+		OrganizationID: orgID,
+		UserID:         userID,
+		Action:         action,
+		Details:        auditDetails(details),
+		StatusCode:     status,
+	})
+}
+
+// writeAudit adds the request context to entry and appends it to the log.
+func writeAudit(c *fiber.Ctx, db *gorm.DB, entry models.AuditLog) error {
+	addRequestContext(c, &entry)
+	return writeAuditEntry(db, entry)
+}
+
+// addRequestContext fills in the outcome and request details of entry. The
+// values are copied, since Fiber reuses their memory once the request has
+// been answered and entries can be written after that.
+func addRequestContext(c *fiber.Ctx, entry *models.AuditLog) {
+	entry.Outcome = auditOutcome(entry.StatusCode)
+	entry.IPAddress = utils.CopyString(c.IP())
+	entry.UserAgent = utils.CopyString(truncate(c.Get(fiber.HeaderUserAgent), 512))
+	if requestID, ok := c.Locals("requestid").(string); ok {
+		entry.RequestID = utils.CopyString(truncate(requestID, 64))
+	}
+}
+
+func writeAuditEntry(db *gorm.DB, entry models.AuditLog) error {
+	if err := audit.Write(db, &entry); err != nil {
+		logger.New().Errorf("Failed to write audit entry %s: %v", entry.Action, err)
+		return err
+	}
+	return nil
+}
+
+func auditDetails(details fiber.Map) datatypes.JSON {
+	detailsJSON, _ := datatypes.NewJSONType(details).MarshalJSON()
+	return datatypes.JSON(detailsJSON)
+}
+
+func auditOutcome(status int) string {
+	switch {
+	case status == fiber.StatusUnauthorized || status == fiber.StatusForbidden:
+		return models.AuditOutcomeDenied
+	case status >= 400:
+		return models.AuditOutcomeFailure
+	default:
+		return models.AuditOutcomeSuccess
+	}
+}
+
+func truncate(value string, max int) string {
+	if len(value) > max {
+		return value[:max]
+	}
+	return value
+}
+
+// primaryOrgID returns the organization a user joined first, used to file
+// account-level audit entries that happen outside an organization context.
+func primaryOrgID(userID uint) uint {
+	var membership models.OrganizationMember
+	database.DB.Where("user_id = ?", userID).Order("created_at ASC").First(&membership)
+	return membership.OrganizationID
+}
+
+func toUintPtr(val uint) *uint {
+	return &val
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+)
+
+const auditExportBatchSize = 1000
+
+// auditCSVHeader names the columns of a CSV export. The hash columns let
+// auditors check the exported entries against the chain; text cells that
+// spreadsheets would run as formulas are prefixed with a quote, which must
+// be dropped before recomputing a hash.
+var auditCSVHeader = []string{
+	"sequence", "created_at", "organization_id", "user_id", "action",
+	"outcome", "status_code", "ip_address", "user_agent", "request_id",
+	"details", "changes", "prev_hash", "hash",
+}
+
+// eachAuditBatch calls fn with successive batches of the entries matching
+// search, in chain order, flushing w after each so the client receives
+// them as they are read.
+func eachAuditBatch(w *bufio.Writer, search *auditSearch, fn func([]models.AuditLog) error) error {
+	var after int64
+	for {
+		var entries []models.AuditLog
+		if err := database.DB.Scopes(search.scope).
+			Where("sequence > ?", after).
+			Order("sequence ASC").
+			Limit(auditExportBatchSize).
+			Find(&entries).Error; err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		if err := fn(entries); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		after = entries[len(entries)-1].Sequence
+	}
+}
+
+// csvCell escapes a text cell that a spreadsheet would otherwise evaluate
+// as a formula.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func exportAuditCSV(w *bufio.Writer, search *auditSearch) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(auditCSVHeader); err != nil {
+		return err
+	}
+
+	return eachAuditBatch(w, search, func(entries []models.AuditLog) error {
+		for _, entry := range entries {
+			userID := ""
+			if entry.UserID != nil {
+				userID = strconv.FormatUint(uint64(*entry.UserID), 10)
+			}
+			statusCode := ""
+			if entry.StatusCode != 0 {
+				statusCode = strconv.Itoa(entry.StatusCode)
+			}
+
+			if err := out.Write([]string{
+				strconv.FormatInt(entry.Sequence, 10),
+				entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+				strconv.FormatUint(uint64(entry.OrganizationID), 10),
+				userID,
+				csvCell(entry.Action),
+				entry.Outcome,
+				statusCode,
+				csvCell(entry.IPAddress),
+				csvCell(entry.UserAgent),
+				csvCell(entry.RequestID),
+				csvCell(string(entry.Details)),
+				csvCell(string(entry.Changes)),
+				entry.PrevHash,
+				entry.Hash,
+			}); err != nil {
+				return err
+			}
+		}
+		out.Flush()
+		return out.Error()
+	})
+}
+
+func exportAuditNDJSON(w *bufio.Writer, search *auditSearch) error {
+	out := json.NewEncoder(w)
+
+	return eachAuditBatch(w, search, func(entries []models.AuditLog) error {
+		for _, entry := range entries {
+			if err := out.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
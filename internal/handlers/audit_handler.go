@@ -1,45 +1,134 @@
 package handlers
 
 import (
+	"bufio"
+	"fmt"
 	"strconv"
+	"time"
 
+	"github.com/FRFebi/bot-management-backend/internal/audit"
 	"github.com/FRFebi/bot-management-backend/internal/database"
 	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/FRFebi/bot-management-backend/pkg/logger"
 	"github.com/gofiber/fiber/v2"
 )
 
-type AuditHandler struct{}
+type AuditHandler struct {
+	checkpoints *audit.Checkpointer
+}
 
-func NewAuditHandler() *AuditHandler {
-	return &AuditHandler{}
+func NewAuditHandler(checkpoints *audit.Checkpointer) *AuditHandler {
+	return &AuditHandler{checkpoints: checkpoints}
 }
 
+// GetAuditLogs searches the organization's audit log, newest first. Pages
+// are at most maxAuditPageSize entries; the next_cursor of a response is
+// passed back as ?cursor to fetch the following page. total counts every
+// entry matching the filters.
 func (h *AuditHandler) GetAuditLogs(c *fiber.Ctx) error {
-	var audits []models.AuditLog
-
-	query := database.DB.Preload("User").Order("created_at DESC")
+	search, ok := parseAuditSearch(c)
+	if !ok {
+		return nil
+	}
 
-	// Optional filter by user ID
-	if userID := c.Query("user_id"); userID != "" {
-		query = query.Where("user_id = ?", userID)
+	limit, err := strconv.Atoi(c.Query("limit", strconv.Itoa(defaultAuditPageSize)))
+	if err != nil || limit < 1 || limit > maxAuditPageSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("limit must be between 1 and %d", maxAuditPageSize),
+		})
 	}
 
-	// Optional filter by action
-	if action := c.Query("action"); action != "" {
-		query = query.Where("action = ?", action)
+	var total int64
+	if err := database.DB.Model(&models.AuditLog{}).Scopes(search.scope).Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch audit logs",
+		})
 	}
 
-	// Pagination
-	limit, _ := strconv.Atoi(c.Query("limit", "50"))
-	offset, _ := strconv.Atoi(c.Query("offset", "0"))
+	query := database.DB.Scopes(search.scope).Preload("User").Order("sequence DESC")
+	if cursor := c.Query("cursor"); cursor != "" {
+		before, ok := decodeAuditCursor(cursor)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid cursor",
+			})
+		}
+		query = query.Where("sequence < ?", before)
+	}
 
-	if err := query.Limit(limit).Offset(offset).Find(&audits).Error; err != nil {
+	// Fetch one extra entry to learn whether another page follows.
+	var entries []models.AuditLog
+	if err := query.Limit(limit + 1).Find(&entries).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch audit logs",
 		})
 	}
 
-	return c.JSON(audits)
+	var nextCursor *string
+	if len(entries) > limit {
+		entries = entries[:limit]
+		cursor := encodeAuditCursor(entries[limit-1].Sequence)
+		nextCursor = &cursor
+	}
+
+	return c.JSON(fiber.Map{
+		"entries":     entries,
+		"total":       total,
+		"next_cursor": nextCursor,
+	})
+}
+
+// ExportAuditLogs streams every entry matching the search filters, oldest
+// first, as CSV (?format=csv, the default) or newline-delimited JSON
+// (?format=ndjson). Entries are read in batches, so exports of any size
+// use constant memory.
+func (h *AuditHandler) ExportAuditLogs(c *fiber.Ctx) error {
+	search, ok := parseAuditSearch(c)
+	if !ok {
+		return nil
+	}
+
+	format := c.Query("format", "csv")
+	var contentType string
+	switch format {
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+	case "ndjson":
+		contentType = "application/x-ndjson"
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be csv or ndjson",
+		})
+	}
+
+	details := search.details()
+	details["format"] = format
+	if err := logAudit(c, database.DB, "audit.export", details); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record audit log",
+		})
+	}
+
+	filename := fmt.Sprintf("audit-logs-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+
+	log := logger.New()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		var err error
+		if format == "csv" {
+			err = exportAuditCSV(w, search)
+		} else {
+			err = exportAuditNDJSON(w, search)
+		}
+		if err != nil {
+			// Headers are long gone; all that is left is to cut the
+			// stream short and leave a trace.
+			log.Errorf("Audit log export failed: %v", err)
+		}
+	})
+
+	return nil
 }
 
 func (h *AuditHandler) GetAuditLog(c *fiber.Ctx) error {
@@ -50,12 +139,113 @@ func (h *AuditHandler) GetAuditLog(c *fiber.Ctx) error {
 		})
 	}
 
-	var audit models.AuditLog
-	if err := database.DB.Preload("User").First(&audit, id).Error; err != nil {
+	var entry models.AuditLog
+	if err := database.DB.Scopes(inOrganization(c)).Preload("User").First(&entry, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Audit log not found",
 		})
 	}
 
-	return c.JSON(audit)
+	return c.JSON(entry)
+}
+
+// VerifyAuditChain checks the whole audit hash chain, across all
+// organizations, and reports the first broken link.
+func (h *AuditHandler) VerifyAuditChain(c *fiber.Ctx) error {
+	report, err := audit.Verify(database.DB, h.checkpoints)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to verify audit log",
+		})
+	}
+
+	if err := logAudit(c, database.DB, "audit.verify", fiber.Map{
+		"valid":         report.Valid,
+		"last_sequence": report.LastSequence,
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record audit log",
+		})
+	}
+
+	return c.JSON(report)
+}
+
+// GetCheckpoints exports the signed checkpoints, optionally only those
+// after the sequence given in ?after, for archiving outside this system.
+func (h *AuditHandler) GetCheckpoints(c *fiber.Ctx) error {
+	after, _ := strconv.ParseInt(c.Query("after", "0"), 10, 64)
+
+	var checkpoints []models.AuditCheckpoint
+	if err := database.DB.Where("sequence > ?", after).Order("sequence ASC").Find(&checkpoints).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch audit checkpoints",
+		})
+	}
+
+	return c.JSON(checkpoints)
+}
+
+// CreateCheckpoint signs a checkpoint for the current head of the chain
+// without waiting for the next periodic one.
+func (h *AuditHandler) CreateCheckpoint(c *fiber.Ctx) error {
+	checkpoint, err := h.checkpoints.Create()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create audit checkpoint",
+		})
+	}
+	if checkpoint == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Audit log is empty",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(checkpoint)
+}
+
+// GetArchives lists the archive files holding entries past the retention
+// period.
+func (h *AuditHandler) GetArchives(c *fiber.Ctx) error {
+	var archives []models.AuditArchive
+	if err := database.DB.Order("first_sequence ASC").Find(&archives).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch audit archives",
+		})
+	}
+
+	return c.JSON(archives)
+}
+
+// GetSinks reports how far each external audit sink has been delivered,
+// with the number of entries it is behind the head of the chain.
+func (h *AuditHandler) GetSinks(c *fiber.Ctx) error {
+	var cursors []models.AuditSinkCursor
+	if err := database.DB.Order("sink ASC").Find(&cursors).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch audit sinks",
+		})
+	}
+
+	var head models.AuditLog
+	if err := database.DB.Select("sequence").Order("sequence DESC").Limit(1).Find(&head).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch audit sinks",
+		})
+	}
+
+	sinks := make([]fiber.Map, 0, len(cursors))
+	for _, cursor := range cursors {
+		sinks = append(sinks, fiber.Map{
+			"sink":            cursor.Sink,
+			"last_sequence":   cursor.LastSequence,
+			"pending":         max(head.Sequence-cursor.LastSequence, 0),
+			"failures":        cursor.Failures,
+			"last_error":      cursor.LastError,
+			"next_attempt_at": cursor.NextAttemptAt,
+			"delivered_at":    cursor.DeliveredAt,
+		})
+	}
+
+	return c.JSON(sinks)
 }
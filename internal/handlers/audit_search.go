@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Page sizes for audit log searches.
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+// auditSearch holds the audit log filters given in the query string. It is
+// parsed up front so exports can keep using it after the handler returns.
+type auditSearch struct {
+	orgID     uint
+	userID    *uint64
+	botID     *uint64
+	action    string
+	prefix    bool
+	outcome   string
+	text      string
+	from      *time.Time
+	to        *time.Time
+	requestID string
+}
+
+// parseAuditSearch reads the filters shared by the audit log list and
+// export:
+//
+//	from, to    RFC 3339 times, from inclusive and to exclusive
+//	user_id     the acting user
+//	action      an exact action, or a prefix ending in * such as bot.*
+//	bot_id      the bot an entry is about, read from its details
+//	outcome     success, denied or failure
+//	request_id  the request that produced the entries
+//	q           free text matched against action, details, changes and IP
+//
+// When it returns false the error response has already been written.
+func parseAuditSearch(c *fiber.Ctx) (*auditSearch, bool) {
+	search := &auditSearch{
+		orgID:     currentOrgID(c),
+		outcome:   c.Query("outcome"),
+		text:      strings.TrimSpace(c.Query("q")),
+		requestID: c.Query("request_id"),
+	}
+
+	if action := c.Query("action"); strings.HasSuffix(action, "*") {
+		search.action = strings.TrimSuffix(action, "*")
+		search.prefix = true
+	} else {
+		search.action = action
+	}
+
+	for _, param := range []struct {
+		name string
+		dst  **uint64
+	}{
+		{"user_id", &search.userID},
+		{"bot_id", &search.botID},
+	} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid " + param.name,
+			})
+			return nil, false
+		}
+		*param.dst = &id
+	}
+
+	for _, param := range []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"from", &search.from},
+		{"to", &search.to},
+	} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": param.name + " must be an RFC 3339 time",
+			})
+			return nil, false
+		}
+		*param.dst = &t
+	}
+
+	return search, true
+}
+
+// scope applies the filters to a query on audit_logs.
+func (s *auditSearch) scope(db *gorm.DB) *gorm.DB {
+	db = db.Where("organization_id = ?", s.orgID)
+
+	if s.userID != nil {
+		db = db.Where("user_id = ?", *s.userID)
+	}
+	if s.botID != nil {
+		db = db.Where("details->>'bot_id' = ?", strconv.FormatUint(*s.botID, 10))
+	}
+	if s.action != "" {
+		if s.prefix {
+			db = db.Where("action LIKE ? ESCAPE '\\'", escapeLike(s.action)+"%")
+		} else {
+			db = db.Where("action = ?", s.action)
+		}
+	}
+	if s.outcome != "" {
+		db = db.Where("outcome = ?", s.outcome)
+	}
+	if s.requestID != "" {
+		db = db.Where("request_id = ?", s.requestID)
+	}
+	if s.from != nil {
+		db = db.Where("created_at >= ?", *s.from)
+	}
+	if s.to != nil {
+		db = db.Where("created_at < ?", *s.to)
+	}
+	if s.text != "" {
+		pattern := "%" + escapeLike(s.text) + "%"
+		db = db.Where(`(action ILIKE ? ESCAPE '\' OR details::text ILIKE ? ESCAPE '\'
+			OR changes::text ILIKE ? ESCAPE '\' OR ip_address ILIKE ? ESCAPE '\')`,
+			pattern, pattern, pattern, pattern)
+	}
+
+	return db
+}
+
+// details lists the filters in use, for the audit entry of an export.
+func (s *auditSearch) details() fiber.Map {
+	details := fiber.Map{}
+	if s.userID != nil {
+		details["user_id"] = *s.userID
+	}
+	if s.botID != nil {
+		details["bot_id"] = *s.botID
+	}
+	if s.action != "" {
+		action := s.action
+		if s.prefix {
+			action += "*"
+		}
+		details["action"] = action
+	}
+	if s.outcome != "" {
+		details["outcome"] = s.outcome
+	}
+	if s.requestID != "" {
+		details["request_id"] = s.requestID
+	}
+	if s.from != nil {
+		details["from"] = s.from.UTC()
+	}
+	if s.to != nil {
+		details["to"] = s.to.UTC()
+	}
+	if s.text != "" {
+		details["q"] = s.text
+	}
+	return details
+}
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// encodeAuditCursor and decodeAuditCursor turn the sequence of the last
+// entry on a page into an opaque cursor for the next one.
+func encodeAuditCursor(sequence int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(sequence, 10)))
+}
+
+func decodeAuditCursor(cursor string) (int64, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, false
+	}
+	sequence, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || sequence <= 0 {
+		return 0, false
+	}
+	return sequence, true
+}
@@ -1,27 +1,55 @@
 package handlers
 
 import (
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/authn"
 	"github.com/FRFebi/bot-management-backend/internal/config"
 	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/loginguard"
 	"github.com/FRFebi/bot-management-backend/internal/models"
 	"github.com/FRFebi/bot-management-backend/pkg/auth"
+	"github.com/FRFebi/bot-management-backend/pkg/logger"
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
-	jwtManager *auth.JWTManager
+	jwtManager       *auth.JWTManager
+	authenticator    authn.Authenticator
+	guard            *loginguard.Guard
+	log              *logger.Logger
+	registration     config.RegistrationConfig
+	passwordMinLen   int
+	mfaEnforcedRoles []string
+	mfaChallengeTTL  time.Duration
 }
 
-func NewAuthHandler(cfg *config.Config) *AuthHandler {
+func NewAuthHandler(cfg *config.Config, jwtManager *auth.JWTManager, authenticator authn.Authenticator, guard *loginguard.Guard) *AuthHandler {
 	return &AuthHandler{
-		jwtManager: auth.NewJWTManager(cfg.JWT.SecretKey, cfg.JWT.ExpiryHours),
+		jwtManager:       jwtManager,
+		authenticator:    authenticator,
+		guard:            guard,
+		log:              logger.New(),
+		registration:     cfg.Registration,
+		passwordMinLen:   cfg.Password.MinLength,
+		mfaEnforcedRoles: cfg.MFA.EnforcedRoles,
+		mfaChallengeTTL:  time.Duration(cfg.MFA.ChallengeMinutes) * time.Minute,
 	}
 }
 
+// LoginRequest carries the login name in Email. Directory backends such as
+// LDAP also accept a username there.
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email          string `json:"email"`
+	Password       string `json:"password"`
+	OrganizationID uint   `json:"organization_id,omitempty"`
 }
 
 type LoginResponse struct {
@@ -29,11 +57,21 @@ type LoginResponse struct {
 	User  models.User `json:"user"`
 }
 
+type MFAChallengeResponse struct {
+	MFARequired           bool   `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired bool   `json:"mfa_enrollment_required,omitempty"`
+	MFAToken              string `json:"mfa_token"`
+}
+
+type SwitchOrganizationRequest struct {
+	OrganizationID uint `json:"organization_id"`
+}
+
 type RegisterRequest struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Role     string `json:"role"`
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+	InviteToken string `json:"invite_token,omitempty"`
 }
 
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
@@ -50,33 +88,232 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		})
 	}
 
-	var user models.User
-	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid credentials",
+	ip := c.IP()
+	account := accountThrottleKey(req.Email)
+
+	decision, err := h.guard.Check(account, ip)
+	if err != nil {
+		h.log.Errorf("Failed to check login throttle: %v", err)
+	} else if !decision.Allowed {
+		return throttledResponse(c, decision)
+	}
+
+	user, err := h.authenticator.Authenticate(c.Context(), req.Email, req.Password)
+	if errors.Is(err, authn.ErrAccountConflict) {
+		auditLogin(c, nil, "auth.login.failure", fiber.StatusConflict, fiber.Map{
+			"login":  req.Email,
+			"reason": "account_conflict",
+		})
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "An account with this email already exists and cannot be linked to the directory",
 		})
 	}
+	if err != nil {
+		if !errors.Is(err, authn.ErrInvalidCredentials) {
+			h.log.Errorf("Login failed: %v", err)
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "Authentication service unavailable",
+			})
+		}
+
+		failure, err := h.guard.RecordFailure(account, ip)
+		if err != nil {
+			h.log.Errorf("Failed to record login failure: %v", err)
+		}
+
+		auditLogin(c, nil, "auth.login.failure", fiber.StatusUnauthorized, fiber.Map{
+			"login":    req.Email,
+			"reason":   "invalid_credentials",
+			"failures": failure.AccountFailures,
+		})
+		if failure.Locked {
+			auditLogin(c, nil, "auth.login.lockout", fiber.StatusUnauthorized, fiber.Map{
+				"login": req.Email,
+			})
+		}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Invalid credentials",
 		})
 	}
 
-	token, err := h.jwtManager.GenerateToken(user.ID, user.Email, user.Role)
+	// A login that did not name the account by its email, such as a
+	// directory uid, was not checked against the account's lockout yet.
+	if key := loginguard.AccountKey(user.ID); key != account {
+		if decision, err := h.guard.Check(key, ""); err != nil {
+			h.log.Errorf("Failed to check login throttle: %v", err)
+		} else if !decision.Allowed {
+			return throttledResponse(c, decision)
+		}
+	}
+
+	return h.finishLogin(c, user, req.Email, req.OrganizationID, nil)
+}
+
+// accountThrottleKey returns the login guard key of the user whose email is
+// login, or "" if there is none, in which case only the client IP is
+// throttled.
+func accountThrottleKey(login string) string {
+	var user models.User
+	if err := database.DB.Select("id").Where("LOWER(email) = LOWER(?)", login).First(&user).Error; err != nil {
+		return ""
+	}
+	return loginguard.AccountKey(user.ID)
+}
+
+// finishLogin continues a login, by password or single sign-on, once the
+// user's primary credentials have been verified: it refuses deactivated
+// accounts and hands over to completeLogin. Details are added to the login
+// audit entries.
+func (h *AuthHandler) finishLogin(c *fiber.Ctx, user *models.User, login string, orgID uint, details fiber.Map) error {
+	if !user.IsActive {
+		auditLogin(c, user, "auth.login.failure", fiber.StatusForbidden, fiber.Map{
+			"login":  login,
+			"reason": "deactivated",
+		})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Account is deactivated",
+		})
+	}
+
+	if details == nil {
+		details = fiber.Map{}
+	}
+	details["login"] = login
+	details["provider"] = user.AuthProvider
+
+	return h.completeLogin(c, user, login, orgID, details)
+}
+
+// throttledResponse rejects a login attempt made too soon after previous
+// failures.
+func throttledResponse(c *fiber.Ctx, decision loginguard.Decision) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds()))))
+
+	message := "Too many failed login attempts, please wait before retrying"
+	if decision.Locked {
+		message = "Too many failed login attempts, login is temporarily locked"
+	}
+
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":       message,
+		"retry_after": int(math.Ceil(decision.RetryAfter.Seconds())),
+	})
+}
+
+// auditLogin records an authentication event. Failed attempts for unknown
+// accounts have no user and are filed under the default organization.
+func auditLogin(c *fiber.Ctx, user *models.User, action string, status int, details fiber.Map) error {
+	if user != nil {
+		return recordAudit(c, database.DB, primaryOrgID(user.ID), &user.ID, action, status, details)
+	}
+
+	org, err := database.DefaultOrganization()
+	if err != nil {
+		return err
+	}
+	return recordAudit(c, database.DB, org.ID, nil, action, status, details)
+}
+
+// completeLogin finishes a login once the user's primary credentials have
+// been verified. Users with MFA get a challenge token to redeem at
+// /auth/mfa/verify, and users whose role requires MFA but who have not
+// enrolled get a token that is only good for enrollment; the login only
+// succeeds once that step is done. Everyone else gets a session.
+func (h *AuthHandler) completeLogin(c *fiber.Ctx, user *models.User, login string, orgID uint, details fiber.Map) error {
+	identity, err := resolveIdentity(user, orgID)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	purpose := ""
+	if user.MFAEnabled {
+		purpose = auth.PurposeMFAChallenge
+	} else if h.mfaEnforced(identity) {
+		purpose = auth.PurposeMFAEnrollment
+	}
+
+	if purpose == "" {
+		if err := recordLoginSuccess(c, h.guard, user, login, details); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to record audit log",
+			})
+		}
+		return sessionResponse(c, h.jwtManager, user, identity)
+	}
+
+	token, err := h.jwtManager.GeneratePurposeToken(identity, purpose, h.mfaChallengeTTL)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
+	}
+
+	details["step"] = purpose
+	if err := auditLogin(c, user, "auth.login.mfa_pending", fiber.StatusOK, details); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record audit log",
+		})
+	}
+
+	return c.JSON(MFAChallengeResponse{
+		MFARequired:           purpose == auth.PurposeMFAChallenge,
+		MFAEnrollmentRequired: purpose == auth.PurposeMFAEnrollment,
+		MFAToken:              token,
+	})
+}
+
+// recordLoginSuccess audits a login that is issued a session and clears
+// the failed attempts of the account. The IP's count is left to expire, so
+// logging into one account does not lift the throttling of guesses
+// against others.
+func recordLoginSuccess(c *fiber.Ctx, guard *loginguard.Guard, user *models.User, login string, details fiber.Map) error {
+	if err := guard.RecordSuccess(loginguard.AccountKey(user.ID)); err != nil {
+		logger.New().Errorf("Failed to reset login throttle: %v", err)
+	}
+
+	if details == nil {
+		details = fiber.Map{}
+	}
+	details["login"] = login
+	return auditLogin(c, user, "auth.login.success", fiber.StatusOK, details)
+}
+
+func (h *AuthHandler) mfaEnforced(identity auth.Identity) bool {
+	return slices.Contains(h.mfaEnforcedRoles, identity.Role) ||
+		(identity.SuperAdmin && slices.Contains(h.mfaEnforcedRoles, "superadmin"))
+}
+
+// sessionResponse issues an access token for identity and writes the login
+// response.
+func sessionResponse(c *fiber.Ctx, jwtManager *auth.JWTManager, user *models.User, identity auth.Identity) error {
+	token, err := jwtManager.GenerateToken(identity)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate token",
 		})
 	}
 
+	database.DB.Preload("Memberships.Organization").First(user, user.ID)
+
 	return c.JSON(LoginResponse{
 		Token: token,
-		User:  user,
+		User:  *user,
 	})
 }
 
+// Register handles public self-registration. What it accepts depends on
+// the configured registration mode; the role always comes from an invite
+// or defaults to viewer, never from the request.
 func (h *AuthHandler) Register(c *fiber.Ctx) error {
+	if h.registration.Mode == config.RegistrationDisabled {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Registration is disabled",
+		})
+	}
+
 	var req RegisterRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -90,38 +327,149 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.Role != "admin" && req.Role != "viewer" {
-		req.Role = "viewer"
+	if err := validatePassword(req.Password, h.passwordMinLen); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var invite *models.Invite
+	if req.InviteToken != "" {
+		var found models.Invite
+		if err := database.DB.Where("token_hash = ?", auth.HashOpaqueToken(req.InviteToken)).First(&found).Error; err != nil ||
+			!found.Usable(time.Now().UTC()) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Invitation is invalid or has expired",
+			})
+		}
+
+		if found.Email != "" && !strings.EqualFold(found.Email, req.Email) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Invitation was issued for a different email address",
+			})
+		}
+		invite = &found
+	} else if h.registration.Mode != config.RegistrationOpen {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "An invitation is required to register",
+		})
+	} else if !h.emailDomainAllowed(req.Email) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Registration is not open to this email domain",
+		})
+	}
+
+	role := "viewer"
+	var orgID uint
+	if invite != nil {
+		role = invite.Role
+		orgID = invite.OrganizationID
+	} else {
+		org, err := database.DefaultOrganization()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to create user",
+			})
+		}
+		orgID = org.ID
 	}
 
 	var existingUser models.User
-	if err := database.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+	if err := database.DB.Unscoped().Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
 			"error": "Email already exists",
 		})
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	user, err := newUser(req.Name, req.Email, req.Password, role, orgID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to hash password",
 		})
 	}
 
-	user := models.User{
-		Name:         req.Name,
-		Email:        req.Email,
-		PasswordHash: string(hashedPassword),
-		Role:         req.Role,
-	}
+	c.Status(fiber.StatusCreated)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		details := fiber.Map{
+			"email": user.Email,
+			"role":  role,
+		}
+		if invite == nil {
+			return recordAudit(c, tx, orgID, &user.ID, "auth.register", fiber.StatusCreated, details)
+		}
+		details["invite_id"] = invite.ID
 
-	if err := database.DB.Create(&user).Error; err != nil {
+		// Claim the invite conditionally so two concurrent registrations
+		// cannot both use it.
+		result := tx.Model(&models.Invite{}).
+			Where("id = ? AND used_at IS NULL", invite.ID).
+			Updates(map[string]interface{}{
+				"used_at":    time.Now().UTC(),
+				"used_by_id": user.ID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInviteUsed
+		}
+		return recordAudit(c, tx, orgID, &user.ID, "auth.register", fiber.StatusCreated, details)
+	})
+	if errors.Is(err, errInviteUsed) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Invitation is invalid or has expired",
+		})
+	}
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create user",
 		})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(user)
+	return c.JSON(user)
+}
+
+var errInviteUsed = errors.New("invite already used")
+
+func (h *AuthHandler) emailDomainAllowed(email string) bool {
+	if len(h.registration.AllowedDomains) == 0 {
+		return true
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+
+	domain := email[at+1:]
+	for _, allowed := range h.registration.AllowedDomains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// newUser builds a user with a hashed password and a membership in orgID.
+func newUser(name, email, password, role string, orgID uint) (*models.User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.User{
+		Name:         name,
+		Email:        email,
+		PasswordHash: string(hashedPassword),
+		Role:         role,
+		Memberships: []models.OrganizationMember{
+			{OrganizationID: orgID, Role: role},
+		},
+	}, nil
 }
 
 func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
@@ -136,19 +484,62 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 		token = token[7:]
 	}
 
-	newToken, err := h.jwtManager.RefreshToken(token)
+	claims, err := h.jwtManager.ValidateToken(token)
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Invalid token",
 		})
 	}
 
+	if claims.Purpose != "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid token",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, claims.UserID).Error; err != nil || !user.IsActive ||
+		claims.TokenVersion != user.TokenVersion {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid token",
+		})
+	}
+
+	// Role and membership may have changed since the token was issued, so
+	// they are resolved again rather than copied from the old token.
+	identity, err := resolveIdentity(&user, claims.OrganizationID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid token",
+		})
+	}
+
+	newToken, err := h.jwtManager.GenerateToken(identity)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
+	}
+
+	if err := recordAudit(c, database.DB, identity.OrganizationID, &user.ID, "auth.token.refresh", fiber.StatusOK, nil); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record audit log",
+		})
+	}
+
 	return c.JSON(fiber.Map{
 		"token": newToken,
 	})
 }
 
+// Logout is stateless, tokens simply expire; it is audited when the caller
+// presents a valid token.
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	token := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+	if claims, err := h.jwtManager.ValidateToken(token); err == nil && claims.Purpose == "" {
+		recordAudit(c, database.DB, claims.OrganizationID, &claims.UserID, "auth.logout", fiber.StatusOK, nil)
+	}
+
 	return c.JSON(fiber.Map{
 		"message": "Logged out successfully",
 	})
@@ -158,11 +549,119 @@ func (h *AuthHandler) Me(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 
 	var user models.User
-	if err := database.DB.First(&user, userID).Error; err != nil {
+	if err := database.DB.Preload("Memberships.Organization").First(&user, userID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "User not found",
 		})
 	}
 
 	return c.JSON(user)
-}
\ No newline at end of file
+}
+
+// SwitchOrganization issues a new token scoped to another organization the
+// caller belongs to.
+func (h *AuthHandler) SwitchOrganization(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req SwitchOrganizationRequest
+	if err := c.BodyParser(&req); err != nil || req.OrganizationID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "organization_id is required",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	identity, err := resolveIdentity(&user, req.OrganizationID)
+	if err != nil {
+		logAuditFailure(c, "auth.org.switch", fiber.StatusForbidden, fiber.Map{
+			"organization_id": req.OrganizationID,
+		})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if !user.MFAEnabled && h.mfaEnforced(identity) {
+		logAuditFailure(c, "auth.org.switch", fiber.StatusForbidden, fiber.Map{
+			"organization_id": req.OrganizationID,
+			"reason":          "mfa_required",
+		})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Multi-factor authentication is required in this organization",
+		})
+	}
+
+	token, err := h.jwtManager.GenerateToken(identity)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
+	}
+
+	if err := logAudit(c, database.DB, "auth.org.switch", fiber.Map{
+		"from_organization_id": currentOrgID(c),
+		"organization_id":      identity.OrganizationID,
+		"role":                 identity.Role,
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record audit log",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"token":           token,
+		"organization_id": identity.OrganizationID,
+		"role":            identity.Role,
+	})
+}
+
+// resolveIdentity picks the organization a token for user is scoped to and
+// the user's role there. An orgID of zero selects the user's oldest
+// membership. Super-admins may enter any organization and always act as
+// admins in it.
+func resolveIdentity(user *models.User, orgID uint) (auth.Identity, error) {
+	identity := auth.Identity{
+		UserID:       user.ID,
+		Email:        user.Email,
+		SuperAdmin:   user.IsSuperAdmin,
+		TokenVersion: user.TokenVersion,
+	}
+
+	query := database.DB.Where("user_id = ?", user.ID)
+	if orgID != 0 {
+		query = query.Where("organization_id = ?", orgID)
+	}
+
+	var membership models.OrganizationMember
+	err := query.Order("created_at ASC").First(&membership).Error
+	switch {
+	case err == nil:
+		identity.OrganizationID = membership.OrganizationID
+		identity.Role = membership.Role
+	case !user.IsSuperAdmin:
+		return auth.Identity{}, errors.New("User is not a member of this organization")
+	case orgID != 0:
+		if err := database.DB.First(&models.Organization{}, orgID).Error; err != nil {
+			return auth.Identity{}, errors.New("Organization not found")
+		}
+		identity.OrganizationID = orgID
+	default:
+		org, err := database.DefaultOrganization()
+		if err != nil {
+			return auth.Identity{}, err
+		}
+		identity.OrganizationID = org.ID
+	}
+
+	if user.IsSuperAdmin {
+		identity.Role = "admin"
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// botPermission returns the caller's effective permission on bot, or an
+// empty permission when the caller has no access. Bots without an owner,
+// because they predate access control or their owner left, are managed by
+// the organization's admins; other members need an access list entry.
+func botPermission(c *fiber.Ctx, bot *models.Bot) models.BotPermission {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return ""
+	}
+
+	if bot.OwnerID == nil && isOrgAdmin(c) {
+		return models.BotPermissionManage
+	}
+	if bot.OwnerID != nil && *bot.OwnerID == userID {
+		return models.BotPermissionManage
+	}
+
+	var entries []models.BotACL
+	if err := database.DB.
+		Where("bot_id = ?", bot.ID).
+		Where("user_id = ? OR group_id IN (?)", userID, userGroupIDs(userID)).
+		Find(&entries).Error; err != nil {
+		return ""
+	}
+
+	var granted models.BotPermission
+	for _, entry := range entries {
+		if entry.Permission.Includes(granted) {
+			granted = entry.Permission
+		}
+	}
+
+	return granted
+}
+
+func canAccessBot(c *fiber.Ctx, bot *models.Bot, required models.BotPermission) bool {
+	granted := botPermission(c, bot)
+	return granted != "" && granted.Includes(required)
+}
+
+// loadBot loads the bot named in the route and ensures the caller holds
+// required on it. Callers without read access get a 404, as if the bot did
+// not exist; other refusals are audited under action. When it returns
+// false the error response has already been written.
+func loadBot(c *fiber.Ctx, required models.BotPermission, action string) (*models.Bot, bool) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid bot ID",
+		})
+		return nil, false
+	}
+
+	var bot models.Bot
+	if err := database.DB.Scopes(inOrganization(c)).First(&bot, id).Error; err != nil {
+		c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Bot not found",
+		})
+		return nil, false
+	}
+
+	granted := botPermission(c, &bot)
+	if granted == "" || !granted.Includes(models.BotPermissionRead) {
+		c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Bot not found",
+		})
+		return nil, false
+	}
+
+	if !granted.Includes(required) {
+		logAuditFailure(c, action, fiber.StatusForbidden, fiber.Map{
+			"bot_id":   bot.ID,
+			"bot_name": bot.Name,
+		})
+		c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Insufficient permissions for this bot",
+		})
+		return nil, false
+	}
+
+	return &bot, true
+}
+
+// lockBot reloads bot inside tx and locks its row until the transaction
+// ends. Handlers change the bot from the locked copy, so that changes made
+// since it was loaded, such as an automatic rollback by the health gate,
+// are not reverted when it is saved.
+func lockBot(tx *gorm.DB, bot *models.Bot) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(bot, bot.ID).Error
+}
+
+// isOrgAdmin reports whether the caller is an admin of their current
+// organization; super-admins act as one.
+func isOrgAdmin(c *fiber.Ctx) bool {
+	role, _ := c.Locals("role").(string)
+	return role == "admin"
+}
+
+// visibleBots restricts a bot query to the bots the caller may read.
+func visibleBots(c *fiber.Ctx, query *gorm.DB) *gorm.DB {
+	userID, _ := c.Locals("userID").(uint)
+	aclBotIDs := database.DB.Model(&models.BotACL{}).
+		Select("bot_id").
+		Where("user_id = ? OR group_id IN (?)", userID, userGroupIDs(userID))
+
+	if isOrgAdmin(c) {
+		return query.Where("bots.owner_id IS NULL OR bots.owner_id = ? OR bots.id IN (?)", userID, aclBotIDs)
+	}
+	return query.Where("bots.owner_id = ? OR bots.id IN (?)", userID, aclBotIDs)
+}
+
+func userGroupIDs(userID uint) *gorm.DB {
+	return database.DB.Table("group_members").Select("group_id").Where("user_id = ?", userID)
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/deploy"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/FRFebi/bot-management-backend/pkg/jsonpatch"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// BotConfigHandler serves the configuration history of bots. Every config
+// change is kept as an immutable revision; rolling back copies an earlier
+// revision into a new one rather than rewriting history. New revisions are
+// released to development and reach production only by promotion.
+type BotConfigHandler struct{}
+
+func NewBotConfigHandler() *BotConfigHandler {
+	return &BotConfigHandler{}
+}
+
+var errRevisionCurrent = errors.New("revision is already the development config")
+
+type RollbackConfigRequest struct {
+	Revision int    `json:"revision"`
+	Message  string `json:"message"`
+}
+
+func (h *BotConfigHandler) GetRevisions(c *fiber.Ctx) error {
+	bot, ok := loadBot(c, models.BotPermissionRead, "bot.config.read")
+	if !ok {
+		return nil
+	}
+
+	var revisions []models.BotConfigRevision
+	if err := database.DB.Preload("Author").
+		Where("bot_id = ?", bot.ID).
+		Order("revision DESC").
+		Find(&revisions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch config revisions",
+		})
+	}
+
+	return c.JSON(revisions)
+}
+
+func (h *BotConfigHandler) GetRevision(c *fiber.Ctx) error {
+	bot, ok := loadBot(c, models.BotPermissionRead, "bot.config.read")
+	if !ok {
+		return nil
+	}
+
+	revision, ok := h.revision(c, bot, c.Params("revision"))
+	if !ok {
+		return nil
+	}
+
+	return c.JSON(revision)
+}
+
+// DiffRevisions returns the JSON Patch (RFC 6902) that turns the config of
+// revision ?from into that of revision ?to. to defaults to the production
+// revision.
+func (h *BotConfigHandler) DiffRevisions(c *fiber.Ctx) error {
+	bot, ok := loadBot(c, models.BotPermissionRead, "bot.config.read")
+	if !ok {
+		return nil
+	}
+
+	from, ok := h.revision(c, bot, c.Query("from"))
+	if !ok {
+		return nil
+	}
+	to, ok := h.revision(c, bot, c.Query("to", strconv.Itoa(bot.ConfigRevision)))
+	if !ok {
+		return nil
+	}
+
+	patch, err := jsonpatch.Diff(from.Config, to.Config)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to diff config revisions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"from":  from.Revision,
+		"to":    to.Revision,
+		"patch": patch,
+	})
+}
+
+// RollbackConfig restores the config of an earlier revision as a new
+// revision released to development, from where it is promoted like any
+// other config change.
+func (h *BotConfigHandler) RollbackConfig(c *fiber.Ctx) error {
+	bot, ok := loadBot(c, models.BotPermissionManage, "bot.config.rollback")
+	if !ok {
+		return nil
+	}
+
+	var req RollbackConfigRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	target, ok := h.revision(c, bot, strconv.Itoa(req.Revision))
+	if !ok {
+		return nil
+	}
+
+	message := req.Message
+	if message == "" {
+		message = "Roll back to revision " + strconv.Itoa(target.Revision)
+	}
+
+	userID := c.Locals("userID").(uint)
+	var revision *models.BotConfigRevision
+	var deployment *models.Deployment
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockBot(tx, bot); err != nil {
+			return err
+		}
+		current, err := deploy.CurrentRevision(tx, bot, models.EnvironmentDevelopment)
+		if err != nil {
+			return err
+		}
+		if target.Revision == current.Revision {
+			return errRevisionCurrent
+		}
+
+		revision, err = deploy.CreateConfigRevision(tx, bot, target.Config, message, &userID, &target.Revision)
+		if err != nil {
+			return err
+		}
+		deployment, err = deploy.RecordConfigChange(tx, bot, revision.Revision, deploy.Options{
+			DeployerID: &userID,
+			Message:    message,
+		})
+		if err != nil {
+			return err
+		}
+		return logAudit(c, tx, "bot.config.rollback", fiber.Map{
+			"bot_id":        bot.ID,
+			"bot_name":      bot.Name,
+			"environment":   models.EnvironmentDevelopment,
+			"from_revision": current.Revision,
+			"to_revision":   target.Revision,
+			"revision":      revision.Revision,
+			"deployment_id": deployment.ID,
+		})
+	})
+	if errors.Is(err, errRevisionCurrent) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Revision is already the development config",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to roll back config",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"bot":        bot,
+		"revision":   revision,
+		"deployment": deployment,
+	})
+}
+
+// revision loads revision number value of bot. When it returns false the
+// error response has already been written.
+func (h *BotConfigHandler) revision(c *fiber.Ctx, bot *models.Bot, value string) (*models.BotConfigRevision, bool) {
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid revision",
+		})
+		return nil, false
+	}
+
+	var revision models.BotConfigRevision
+	if err := database.DB.Preload("Author").
+		Where("bot_id = ? AND revision = ?", bot.ID, number).
+		First(&revision).Error; err != nil {
+		c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Revision not found",
+		})
+		return nil, false
+	}
+
+	return &revision, true
+}
+
+// configReleaseMessage describes the development release of a config
+// revision, defaulting to naming the revision.
+func configReleaseMessage(message string, revision int) string {
+	if message != "" {
+		return message
+	}
+	return "Config revision " + strconv.Itoa(revision)
+}
+
+// configChanged reports whether two configs differ as JSON, ignoring
+// formatting and member order.
+func configChanged(before, after []byte) bool {
+	patch, err := jsonpatch.Diff(before, after)
+	return err != nil || len(patch) > 0
+}
@@ -1,18 +1,34 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
 
 	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/deploy"
 	"github.com/FRFebi/bot-management-backend/internal/models"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
-type BotHandler struct{}
+// maxReplicas bounds the number of instances a bot may run.
+const maxReplicas = 100
 
-func NewBotHandler() *BotHandler {
-	return &BotHandler{}
+var (
+	errBotRunning = errors.New("bot is already running")
+	errBotStopped = errors.New("bot is already stopped")
+)
+
+// BotHandler manages bots. Health check URLs are checked against the
+// destinations the health probe may call.
+type BotHandler struct {
+	probeTargets *deploy.ProbeTargets
+}
+
+func NewBotHandler(probeTargets *deploy.ProbeTargets) *BotHandler {
+	return &BotHandler{probeTargets: probeTargets}
 }
 
 type CreateBotRequest struct {
@@ -20,18 +36,36 @@ type CreateBotRequest struct {
 	Description string         `json:"description"`
 	Version     string         `json:"version"`
 	Config      datatypes.JSON `json:"config"`
+	// VerifiedOnly restricts deploys to artifacts signed by a trusted key.
+	VerifiedOnly bool `json:"verified_only"`
+	// HealthCheckURL is probed by the health gate after each deploy.
+	HealthCheckURL string `json:"health_check_url"`
+	// Replicas is the number of instances of the bot; it defaults to 1.
+	Replicas int `json:"replicas"`
+	// Message describes the initial config revision.
+	Message string `json:"message"`
 }
 
+// UpdateBotRequest changes a bot's settings. Its version and artifact only
+// change through deploys, promotions and rollbacks.
 type UpdateBotRequest struct {
-	Name        *string         `json:"name,omitempty"`
-	Description *string         `json:"description,omitempty"`
-	Version     *string         `json:"version,omitempty"`
-	Config      *datatypes.JSON `json:"config,omitempty"`
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	// Config is recorded as a new revision released to development; it
+	// reaches production only by promotion.
+	Config *datatypes.JSON `json:"config,omitempty"`
+	// VerifiedOnly restricts deploys to artifacts signed by a trusted key.
+	VerifiedOnly *bool `json:"verified_only,omitempty"`
+	// HealthCheckURL is probed by the health gate after each deploy.
+	HealthCheckURL *string `json:"health_check_url,omitempty"`
+	Replicas       *int    `json:"replicas,omitempty"`
+	// Message describes the config revision created when Config changes.
+	Message string `json:"message,omitempty"`
 }
 
 func (h *BotHandler) GetBots(c *fiber.Ctx) error {
 	var bots []models.Bot
-	if err := database.DB.Find(&bots).Error; err != nil {
+	if err := visibleBots(c, database.DB.Scopes(inOrganization(c))).Find(&bots).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch bots",
 		})
@@ -49,7 +83,13 @@ func (h *BotHandler) GetBot(c *fiber.Ctx) error {
 	}
 
 	var bot models.Bot
-	if err := database.DB.First(&bot, id).Error; err != nil {
+	if err := database.DB.Scopes(inOrganization(c)).First(&bot, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Bot not found",
+		})
+	}
+
+	if !canAccessBot(c, &bot, models.BotPermissionRead) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Bot not found",
 		})
@@ -72,27 +112,70 @@ func (h *BotHandler) CreateBot(c *fiber.Ctx) error {
 		})
 	}
 
-	bot := models.Bot{
-		Name:        req.Name,
-		Description: req.Description,
-		Version:     req.Version,
-		Config:      req.Config,
-		Status:      "stopped",
+	if problem := h.healthCheckURLProblem(req.HealthCheckURL); problem != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": problem,
+		})
 	}
 
-	if err := database.DB.Create(&bot).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create bot",
+	if req.Replicas == 0 {
+		req.Replicas = 1
+	}
+	if req.Replicas < 1 || req.Replicas > maxReplicas {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Replicas must be between 1 and %d", maxReplicas),
 		})
 	}
 
-	// Log audit
-	h.logAudit(c, "bot.create", fiber.Map{
-		"bot_id":   bot.ID,
-		"bot_name": bot.Name,
+	userID := c.Locals("userID").(uint)
+
+	bot := models.Bot{
+		OrganizationID: currentOrgID(c),
+		Name:           req.Name,
+		Description:    req.Description,
+		Version:        req.Version,
+		Config:         req.Config,
+		VerifiedOnly:   req.VerifiedOnly,
+		HealthCheckURL: req.HealthCheckURL,
+		Replicas:       req.Replicas,
+		Status:         "stopped",
+		OwnerID:        &userID,
+	}
+
+	message := req.Message
+	if message == "" {
+		message = "Initial configuration"
+	}
+
+	c.Status(fiber.StatusCreated)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&bot).Error; err != nil {
+			return err
+		}
+		if _, err := deploy.NewConfigRevision(tx, &bot, message, &userID, nil); err != nil {
+			return err
+		}
+		if err := tx.Model(&bot).Update("config_revision", bot.ConfigRevision).Error; err != nil {
+			return err
+		}
+		if err := deploy.SyncReplicas(tx, &bot); err != nil {
+			return err
+		}
+		if err := deploy.EnsureEnvironments(tx, &bot); err != nil {
+			return err
+		}
+		return logAuditChange(c, tx, "bot.create", fiber.Map{
+			"bot_id":   bot.ID,
+			"bot_name": bot.Name,
+		}, nil, bot)
 	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create bot",
+		})
+	}
 
-	return c.Status(fiber.StatusCreated).JSON(bot)
+	return c.JSON(bot)
 }
 
 func (h *BotHandler) UpdateBot(c *fiber.Ctx) error {
@@ -104,12 +187,22 @@ func (h *BotHandler) UpdateBot(c *fiber.Ctx) error {
 	}
 
 	var bot models.Bot
-	if err := database.DB.First(&bot, id).Error; err != nil {
+	if err := database.DB.Scopes(inOrganization(c)).First(&bot, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Bot not found",
 		})
 	}
 
+	if !canAccessBot(c, &bot, models.BotPermissionManage) {
+		logAuditFailure(c, "bot.update", fiber.StatusForbidden, fiber.Map{
+			"bot_id":   bot.ID,
+			"bot_name": bot.Name,
+		})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Insufficient permissions for this bot",
+		})
+	}
+
 	var req UpdateBotRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -117,31 +210,86 @@ func (h *BotHandler) UpdateBot(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.Name != nil {
-		bot.Name = *req.Name
-	}
-	if req.Description != nil {
-		bot.Description = *req.Description
-	}
-	if req.Version != nil {
-		bot.Version = *req.Version
+	if req.HealthCheckURL != nil {
+		if problem := h.healthCheckURLProblem(*req.HealthCheckURL); problem != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": problem,
+			})
+		}
 	}
-	if req.Config != nil {
-		bot.Config = *req.Config
+	if req.Replicas != nil && (*req.Replicas < 1 || *req.Replicas > maxReplicas) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Replicas must be between 1 and %d", maxReplicas),
+		})
 	}
 
-	if err := database.DB.Save(&bot).Error; err != nil {
+	userID := c.Locals("userID").(uint)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockBot(tx, &bot); err != nil {
+			return err
+		}
+		before := bot
+
+		if req.Name != nil {
+			bot.Name = *req.Name
+		}
+		if req.Description != nil {
+			bot.Description = *req.Description
+		}
+		if req.VerifiedOnly != nil {
+			bot.VerifiedOnly = *req.VerifiedOnly
+		}
+		if req.HealthCheckURL != nil {
+			bot.HealthCheckURL = *req.HealthCheckURL
+		}
+		if req.Replicas != nil {
+			bot.Replicas = *req.Replicas
+		}
+
+		details := fiber.Map{
+			"bot_id":   bot.ID,
+			"bot_name": bot.Name,
+		}
+		if req.Config != nil {
+			current, err := deploy.CurrentRevision(tx, &bot, models.EnvironmentDevelopment)
+			if err != nil {
+				return err
+			}
+			if configChanged(current.Config, *req.Config) {
+				revision, err := deploy.CreateConfigRevision(tx, &bot, *req.Config, req.Message, &userID, nil)
+				if err != nil {
+					return err
+				}
+				deployment, err := deploy.RecordConfigChange(tx, &bot, revision.Revision, deploy.Options{
+					DeployerID: &userID,
+					Message:    configReleaseMessage(req.Message, revision.Revision),
+				})
+				if err != nil {
+					return err
+				}
+				details["config_revision"] = revision.Revision
+				details["previous_config_revision"] = current.Revision
+				details["environment"] = models.EnvironmentDevelopment
+				details["deployment_id"] = deployment.ID
+			}
+		}
+
+		if err := tx.Save(&bot).Error; err != nil {
+			return err
+		}
+		if bot.Replicas != before.Replicas {
+			if err := deploy.SyncReplicas(tx, &bot); err != nil {
+				return err
+			}
+		}
+		return logAuditChange(c, tx, "bot.update", details, before, bot)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update bot",
 		})
 	}
 
-	// Log audit
-	h.logAudit(c, "bot.update", fiber.Map{
-		"bot_id":   bot.ID,
-		"bot_name": bot.Name,
-	})
-
 	return c.JSON(bot)
 }
 
@@ -154,23 +302,36 @@ func (h *BotHandler) DeleteBot(c *fiber.Ctx) error {
 	}
 
 	var bot models.Bot
-	if err := database.DB.First(&bot, id).Error; err != nil {
+	if err := database.DB.Scopes(inOrganization(c)).First(&bot, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Bot not found",
 		})
 	}
 
-	if err := database.DB.Delete(&bot).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete bot",
+	if !canAccessBot(c, &bot, models.BotPermissionManage) {
+		logAuditFailure(c, "bot.delete", fiber.StatusForbidden, fiber.Map{
+			"bot_id":   bot.ID,
+			"bot_name": bot.Name,
+		})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Insufficient permissions for this bot",
 		})
 	}
 
-	// Log audit
-	h.logAudit(c, "bot.delete", fiber.Map{
-		"bot_id":   bot.ID,
-		"bot_name": bot.Name,
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&bot).Error; err != nil {
+			return err
+		}
+		return logAuditChange(c, tx, "bot.delete", fiber.Map{
+			"bot_id":   bot.ID,
+			"bot_name": bot.Name,
+		}, bot, nil)
 	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete bot",
+		})
+	}
 
 	return c.JSON(fiber.Map{
 		"message": "Bot deleted successfully",
@@ -186,31 +347,51 @@ func (h *BotHandler) StartBot(c *fiber.Ctx) error {
 	}
 
 	var bot models.Bot
-	if err := database.DB.First(&bot, id).Error; err != nil {
+	if err := database.DB.Scopes(inOrganization(c)).First(&bot, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Bot not found",
 		})
 	}
 
-	if bot.Status == "running" {
+	if !canAccessBot(c, &bot, models.BotPermissionOperate) {
+		logAuditFailure(c, "bot.start", fiber.StatusForbidden, fiber.Map{
+			"bot_id":   bot.ID,
+			"bot_name": bot.Name,
+		})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Insufficient permissions for this bot",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockBot(tx, &bot); err != nil {
+			return err
+		}
+		if bot.Status == "running" {
+			return errBotRunning
+		}
+		before := bot
+
+		bot.Status = "running"
+		if err := tx.Save(&bot).Error; err != nil {
+			return err
+		}
+		return logAuditChange(c, tx, "bot.start", fiber.Map{
+			"bot_id":   bot.ID,
+			"bot_name": bot.Name,
+		}, before, bot)
+	})
+	if errors.Is(err, errBotRunning) {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Bot is already running",
 		})
 	}
-
-	bot.Status = "running"
-	if err := database.DB.Save(&bot).Error; err != nil {
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to start bot",
 		})
 	}
 
-	// Log audit
-	h.logAudit(c, "bot.start", fiber.Map{
-		"bot_id":   bot.ID,
-		"bot_name": bot.Name,
-	})
-
 	return c.JSON(fiber.Map{
 		"message": "Bot started successfully",
 		"bot":     bot,
@@ -226,31 +407,51 @@ func (h *BotHandler) StopBot(c *fiber.Ctx) error {
 	}
 
 	var bot models.Bot
-	if err := database.DB.First(&bot, id).Error; err != nil {
+	if err := database.DB.Scopes(inOrganization(c)).First(&bot, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Bot not found",
 		})
 	}
 
-	if bot.Status == "stopped" {
+	if !canAccessBot(c, &bot, models.BotPermissionOperate) {
+		logAuditFailure(c, "bot.stop", fiber.StatusForbidden, fiber.Map{
+			"bot_id":   bot.ID,
+			"bot_name": bot.Name,
+		})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Insufficient permissions for this bot",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockBot(tx, &bot); err != nil {
+			return err
+		}
+		if bot.Status == "stopped" {
+			return errBotStopped
+		}
+		before := bot
+
+		bot.Status = "stopped"
+		if err := tx.Save(&bot).Error; err != nil {
+			return err
+		}
+		return logAuditChange(c, tx, "bot.stop", fiber.Map{
+			"bot_id":   bot.ID,
+			"bot_name": bot.Name,
+		}, before, bot)
+	})
+	if errors.Is(err, errBotStopped) {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Bot is already stopped",
 		})
 	}
-
-	bot.Status = "stopped"
-	if err := database.DB.Save(&bot).Error; err != nil {
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to stop bot",
 		})
 	}
 
-	// Log audit
-	h.logAudit(c, "bot.stop", fiber.Map{
-		"bot_id":   bot.ID,
-		"bot_name": bot.Name,
-	})
-
 	return c.JSON(fiber.Map{
 		"message": "Bot stopped successfully",
 		"bot":     bot,
@@ -266,24 +467,42 @@ func (h *BotHandler) RestartBot(c *fiber.Ctx) error {
 	}
 
 	var bot models.Bot
-	if err := database.DB.First(&bot, id).Error; err != nil {
+	if err := database.DB.Scopes(inOrganization(c)).First(&bot, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Bot not found",
 		})
 	}
 
-	bot.Status = "running"
-	if err := database.DB.Save(&bot).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to restart bot",
+	if !canAccessBot(c, &bot, models.BotPermissionOperate) {
+		logAuditFailure(c, "bot.restart", fiber.StatusForbidden, fiber.Map{
+			"bot_id":   bot.ID,
+			"bot_name": bot.Name,
+		})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Insufficient permissions for this bot",
 		})
 	}
 
-	// Log audit
-	h.logAudit(c, "bot.restart", fiber.Map{
-		"bot_id":   bot.ID,
-		"bot_name": bot.Name,
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockBot(tx, &bot); err != nil {
+			return err
+		}
+		before := bot
+
+		bot.Status = "running"
+		if err := tx.Save(&bot).Error; err != nil {
+			return err
+		}
+		return logAuditChange(c, tx, "bot.restart", fiber.Map{
+			"bot_id":   bot.ID,
+			"bot_name": bot.Name,
+		}, before, bot)
 	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to restart bot",
+		})
+	}
 
 	return c.JSON(fiber.Map{
 		"message": "Bot restarted successfully",
@@ -291,6 +510,9 @@ func (h *BotHandler) RestartBot(c *fiber.Ctx) error {
 	})
 }
 
+// DeployBot deploys an uploaded artifact to development or staging.
+// Production only runs releases promoted from staging; see
+// EnvironmentHandler.Promote.
 func (h *BotHandler) DeployBot(c *fiber.Ctx) error {
 	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
@@ -300,14 +522,30 @@ func (h *BotHandler) DeployBot(c *fiber.Ctx) error {
 	}
 
 	var bot models.Bot
-	if err := database.DB.First(&bot, id).Error; err != nil {
+	if err := database.DB.Scopes(inOrganization(c)).First(&bot, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Bot not found",
 		})
 	}
 
+	if !canAccessBot(c, &bot, models.BotPermissionDeploy) {
+		logAuditFailure(c, "bot.deploy", fiber.StatusForbidden, fiber.Map{
+			"bot_id":   bot.ID,
+			"bot_name": bot.Name,
+		})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Insufficient permissions for this bot",
+		})
+	}
+
 	var req struct {
+		// Artifact is the digest of an uploaded artifact of the bot.
+		Artifact string `json:"artifact"`
+		// Version labels the release; it defaults to the short digest.
 		Version string `json:"version"`
+		Message string `json:"message"`
+		// Environment is development (the default) or staging.
+		Environment string `json:"environment"`
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -315,29 +553,92 @@ func (h *BotHandler) DeployBot(c *fiber.Ctx) error {
 		})
 	}
 
+	if req.Environment == "" {
+		req.Environment = models.EnvironmentDevelopment
+	}
+	if req.Environment == models.EnvironmentProduction {
+		logAuditFailure(c, "bot.deploy", fiber.StatusConflict, fiber.Map{
+			"bot_id":      bot.ID,
+			"bot_name":    bot.Name,
+			"environment": req.Environment,
+			"reason":      "production deploys must be promoted from staging",
+		})
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Production deploys must be promoted from staging",
+		})
+	}
+	if !models.ValidEnvironment(req.Environment) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Environment must be development or staging",
+		})
+	}
+
+	if req.Artifact == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Artifact digest is required",
+		})
+	}
+	artifact, ok := botArtifact(c, &bot, req.Artifact)
+	if !ok {
+		return nil
+	}
+	signer, ok := verifyArtifact(c, &bot, artifact, "bot.deploy")
+	if !ok {
+		return nil
+	}
+
 	if req.Version == "" {
+		req.Version = shortDigest(artifact.Digest)
+	}
+	if len(req.Version) > 50 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Version is required",
+			"error": "Version must be at most 50 characters",
 		})
 	}
 
-	bot.Version = req.Version
-	if err := database.DB.Save(&bot).Error; err != nil {
+	userID := c.Locals("userID").(uint)
+
+	var deployment *models.Deployment
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// A new artifact keeps the config the environment runs.
+		revision, err := deploy.CurrentRevision(tx, &bot, req.Environment)
+		if err != nil {
+			return err
+		}
+		deployment, err = deploy.RecordEnvironment(tx, &bot, req.Environment, deploy.Release{
+			Version:        req.Version,
+			ArtifactDigest: artifact.Digest,
+			ConfigRevision: revision.Revision,
+		}, deploy.Options{
+			DeployerID:   &userID,
+			SigningKeyID: signerID(signer),
+			Message:      req.Message,
+		})
+		if err != nil {
+			return err
+		}
+
+		details := fiber.Map{
+			"bot_id":        bot.ID,
+			"bot_name":      bot.Name,
+			"environment":   req.Environment,
+			"version":       req.Version,
+			"artifact":      artifact.Digest,
+			"deployment_id": deployment.ID,
+		}
+		addSignerDetails(details, signer)
+		return logAudit(c, tx, "bot.deploy", details)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to deploy bot",
 		})
 	}
 
-	// Log audit
-	h.logAudit(c, "bot.deploy", fiber.Map{
-		"bot_id":   bot.ID,
-		"bot_name": bot.Name,
-		"version":  req.Version,
-	})
-
 	return c.JSON(fiber.Map{
-		"message": "Bot deployed successfully",
-		"bot":     bot,
+		"message":    "Bot deployed to " + req.Environment + " successfully",
+		"bot":        bot,
+		"deployment": deployment,
 	})
 }
 
@@ -350,7 +651,13 @@ func (h *BotHandler) GetBotStatus(c *fiber.Ctx) error {
 	}
 
 	var bot models.Bot
-	if err := database.DB.First(&bot, id).Error; err != nil {
+	if err := database.DB.Scopes(inOrganization(c)).First(&bot, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Bot not found",
+		})
+	}
+
+	if !canAccessBot(c, &bot, models.BotPermissionRead) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Bot not found",
 		})
@@ -364,23 +671,22 @@ func (h *BotHandler) GetBotStatus(c *fiber.Ctx) error {
 	})
 }
 
-func (h *BotHandler) logAudit(c *fiber.Ctx, action string, details fiber.Map) {
-	userID := c.Locals("userID")
-	if userID == nil {
-		return
+// healthCheckURLProblem returns why value cannot be a bot's health check
+// URL, or "" if it can. An empty value disables the probe.
+func (h *BotHandler) healthCheckURLProblem(value string) string {
+	if value == "" {
+		return ""
 	}
-
-	detailsJSON, _ := datatypes.NewJSONType(details).MarshalJSON()
-
-	audit := models.AuditLog{
-		UserID:  toUintPtr(userID.(uint)),
-		Action:  action,
-		Details: datatypes.JSON(detailsJSON),
+	if len(value) > 2048 {
+		return "Health check URL must be at most 2048 characters"
 	}
 
-	database.DB.Create(&audit)
+	err := h.probeTargets.CheckURL(value)
+	switch {
+	case errors.Is(err, deploy.ErrProbeDestination):
+		return "Health check URL must point to a public address or an allowed network"
+	case err != nil:
+		return "Health check URL must be an absolute http or https URL"
+	}
+	return ""
 }
-
-func toUintPtr(val uint) *uint {
-	return &val
-}
\ No newline at end of file
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/deploy"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// DeploymentHandler serves the release history of bots and rolls them
+// back to earlier releases.
+type DeploymentHandler struct{}
+
+func NewDeploymentHandler() *DeploymentHandler {
+	return &DeploymentHandler{}
+}
+
+type RollbackRequest struct {
+	// DeploymentID picks the release to return to; by default it is the
+	// last successful release that differs from what is running.
+	DeploymentID *uint  `json:"deployment_id,omitempty"`
+	Message      string `json:"message,omitempty"`
+}
+
+var errNoReleaseToRollBack = errors.New("no earlier release to roll back to")
+
+func (h *DeploymentHandler) GetDeployments(c *fiber.Ctx) error {
+	bot, ok := loadBot(c, models.BotPermissionRead, "bot.deployments.read")
+	if !ok {
+		return nil
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	query := database.DB.Preload("Deployer").Where("bot_id = ?", bot.ID)
+	if env := c.Query("environment"); env != "" {
+		query = query.Where("environment = ?", env)
+	}
+
+	var deployments []models.Deployment
+	if err := query.
+		Order("id DESC").
+		Limit(limit).
+		Find(&deployments).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch deployments",
+		})
+	}
+
+	return c.JSON(deployments)
+}
+
+// GetReplicas lists the bot's replicas and the release each one runs.
+func (h *DeploymentHandler) GetReplicas(c *fiber.Ctx) error {
+	bot, ok := loadBot(c, models.BotPermissionRead, "bot.replicas.read")
+	if !ok {
+		return nil
+	}
+
+	var replicas []models.BotReplica
+	if err := database.DB.Where("bot_id = ?", bot.ID).
+		Order("number").
+		Find(&replicas).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch replicas",
+		})
+	}
+
+	return c.JSON(replicas)
+}
+
+// Rollback redeploys an earlier successful production release: its
+// artifact, version and, when it differs, its config, which is restored as
+// a new config revision. The rollback is itself recorded as a deployment.
+func (h *DeploymentHandler) Rollback(c *fiber.Ctx) error {
+	bot, ok := loadBot(c, models.BotPermissionDeploy, "bot.rollback")
+	if !ok {
+		return nil
+	}
+
+	var req RollbackRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	target, err := rollbackTarget(bot, req.DeploymentID)
+	if errors.Is(err, errNoReleaseToRollBack) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "No earlier successful release to roll back to",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to roll back bot",
+		})
+	}
+
+	signer, ok := h.verifyTarget(c, bot, target)
+	if !ok {
+		return nil
+	}
+
+	message := req.Message
+	if message == "" {
+		message = "Roll back to deployment " + strconv.FormatUint(uint64(target.ID), 10)
+	}
+
+	userID := c.Locals("userID").(uint)
+
+	var deployment *models.Deployment
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockBot(tx, bot); err != nil {
+			return err
+		}
+		before := *bot
+
+		if err := deploy.Restore(tx, bot, target, message, &userID); err != nil {
+			return err
+		}
+		if err := tx.Save(bot).Error; err != nil {
+			return err
+		}
+
+		var err error
+		deployment, err = deploy.Record(tx, bot, deploy.Options{
+			DeployerID:   &userID,
+			RollbackOfID: &target.ID,
+			SigningKeyID: signerID(signer),
+			Message:      message,
+		})
+		if err != nil {
+			return err
+		}
+
+		details := fiber.Map{
+			"bot_id":         bot.ID,
+			"bot_name":       bot.Name,
+			"deployment_id":  deployment.ID,
+			"rollback_of_id": target.ID,
+			"version":        bot.Version,
+			"artifact":       bot.ArtifactDigest,
+		}
+		addSignerDetails(details, signer)
+		return logAuditChange(c, tx, "bot.rollback", details, before, *bot)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to roll back bot",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":    "Bot rolled back successfully",
+		"bot":        bot,
+		"deployment": deployment,
+	})
+}
+
+// verifyTarget checks the artifact of the release being rolled back to as
+// DeployBot would. Releases from before artifacts were uploaded have none;
+// they are refused by verified-only bots. When it returns false the error
+// response has already been written.
+func (h *DeploymentHandler) verifyTarget(c *fiber.Ctx, bot *models.Bot, target *models.Deployment) (*models.TrustedKey, bool) {
+	artifact := &models.Artifact{Digest: target.ArtifactDigest}
+	if target.ArtifactDigest != "" {
+		if err := database.DB.Where("bot_id = ? AND digest = ?", bot.ID, target.ArtifactDigest).
+			First(artifact).Error; err != nil {
+			c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to roll back bot",
+			})
+			return nil, false
+		}
+	}
+	return verifyArtifact(c, bot, artifact, "bot.rollback")
+}
+
+// rollbackTarget returns the release to roll bot back to: deploymentID if
+// given, which must be a successful production release of bot, or else the
+// latest successful production release that differs from what is running.
+func rollbackTarget(bot *models.Bot, deploymentID *uint) (*models.Deployment, error) {
+	query := database.DB.Where("bot_id = ? AND environment = ? AND outcome = ?",
+		bot.ID, models.EnvironmentProduction, models.DeploymentSucceeded)
+	if deploymentID != nil {
+		query = query.Where("id = ?", *deploymentID)
+	} else {
+		query = query.Where("NOT (version = ? AND COALESCE(artifact_digest, '') = ? AND config_revision = ?)",
+			bot.Version, bot.ArtifactDigest, bot.ConfigRevision)
+	}
+
+	var target models.Deployment
+	err := query.Order("id DESC").First(&target).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errNoReleaseToRollBack
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+// signerID returns the ID of signer, or nil for unsigned artifacts.
+func signerID(signer *models.TrustedKey) *uint {
+	if signer == nil {
+		return nil
+	}
+	return &signer.ID
+}
+
+// addSignerDetails records in audit details the trusted key that approved
+// a deployment.
+func addSignerDetails(details fiber.Map, signer *models.TrustedKey) {
+	if signer == nil {
+		return
+	}
+	details["signing_key_id"] = signer.ID
+	details["signing_key_name"] = signer.Name
+	details["signing_key_fingerprint"] = signer.Fingerprint
+}
+
+// shortDigest abbreviates an artifact digest to a version label, such as
+// "sha256:3f9a1c0b2d4e".
+func shortDigest(digest string) string {
+	if len(digest) > len("sha256:")+12 {
+		return digest[:len("sha256:")+12]
+	}
+	return digest
+}
@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/deploy"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/FRFebi/bot-management-backend/pkg/jsonpatch"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// EnvironmentHandler serves a bot's deployment environments and promotes
+// releases through them, from development to staging to production.
+// Promotion copies a tested release, its artifact, version and config
+// revision, into the next environment; production deploys happen only
+// this way, through the replicas and health gate of the bot.
+type EnvironmentHandler struct {
+	// healthWindow is how long production deployments stay under the
+	// health gate; zero records them as succeeded right away.
+	healthWindow time.Duration
+}
+
+func NewEnvironmentHandler(cfg *config.Config) *EnvironmentHandler {
+	return &EnvironmentHandler{
+		healthWindow: time.Duration(cfg.Deploy.HealthWindowSeconds) * time.Second,
+	}
+}
+
+type UpdateEnvironmentRequest struct {
+	// ConfigOverlay is a JSON merge patch applied to the bot's config in
+	// the environment; null clears it.
+	ConfigOverlay datatypes.JSON `json:"config_overlay"`
+}
+
+type PromoteRequest struct {
+	// From is the environment to promote from, development or staging.
+	From string `json:"from"`
+	// DeploymentID picks the release to promote; by default it is the
+	// current release of From.
+	DeploymentID *uint  `json:"deployment_id,omitempty"`
+	Message      string `json:"message"`
+	// Strategy is all_at_once (the default), rolling or canary; it
+	// applies to promotions to production.
+	Strategy string `json:"strategy"`
+	// BatchSize is how many replicas a rolling deployment updates at a
+	// time; it defaults to 1.
+	BatchSize int `json:"batch_size"`
+	// CanaryPercent is the share of replicas a canary runs on; it
+	// defaults to 10.
+	CanaryPercent int `json:"canary_percent"`
+}
+
+// environmentView is an environment with its effective config: the bot's
+// config at the environment's revision with the overlay applied.
+type environmentView struct {
+	models.BotEnvironment
+	Config datatypes.JSON `json:"config"`
+}
+
+var errNothingToPromote = errors.New("nothing to promote")
+
+func (h *EnvironmentHandler) GetEnvironments(c *fiber.Ctx) error {
+	bot, ok := loadBot(c, models.BotPermissionRead, "bot.environments.read")
+	if !ok {
+		return nil
+	}
+
+	var environments []models.BotEnvironment
+	if err := database.DB.Where("bot_id = ?", bot.ID).Find(&environments).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch environments",
+		})
+	}
+
+	views := make([]environmentView, 0, len(environments))
+	for _, name := range models.Environments {
+		for _, env := range environments {
+			if env.Name != name {
+				continue
+			}
+			config, err := effectiveConfig(bot, &env)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to fetch environments",
+				})
+			}
+			views = append(views, environmentView{BotEnvironment: env, Config: config})
+		}
+	}
+
+	return c.JSON(views)
+}
+
+// UpdateEnvironment sets the config overlay of an environment.
+func (h *EnvironmentHandler) UpdateEnvironment(c *fiber.Ctx) error {
+	bot, ok := loadBot(c, models.BotPermissionManage, "bot.environment.update")
+	if !ok {
+		return nil
+	}
+
+	env, ok := h.environment(c, bot, c.Params("env"))
+	if !ok {
+		return nil
+	}
+
+	var req UpdateEnvironmentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	overlay := bytes.TrimSpace(req.ConfigOverlay)
+	if len(overlay) == 0 || bytes.Equal(overlay, []byte("null")) {
+		overlay = nil
+	} else if overlay[0] != '{' {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Config overlay must be a JSON object",
+		})
+	}
+
+	before := *env
+	env.ConfigOverlay = datatypes.JSON(overlay)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(env).Update("config_overlay", env.ConfigOverlay).Error; err != nil {
+			return err
+		}
+		return logAuditChange(c, tx, "bot.environment.update", fiber.Map{
+			"bot_id":      bot.ID,
+			"bot_name":    bot.Name,
+			"environment": env.Name,
+		}, before, *env)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update environment",
+		})
+	}
+
+	config, err := effectiveConfig(bot, env)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update environment",
+		})
+	}
+
+	return c.JSON(environmentView{BotEnvironment: *env, Config: config})
+}
+
+// Promote copies a successful release of one environment into the next.
+// Promotions to production go through the bot's replicas and health gate
+// like any production release, and restore the promoted config revision
+// as a new revision when it differs from the bot's.
+func (h *EnvironmentHandler) Promote(c *fiber.Ctx) error {
+	bot, ok := loadBot(c, models.BotPermissionDeploy, "bot.promote")
+	if !ok {
+		return nil
+	}
+
+	var req PromoteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	target := models.NextEnvironment(req.From)
+	if target == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "From must be development or staging",
+		})
+	}
+
+	source, err := promotionSource(bot, req.From, req.DeploymentID)
+	if errors.Is(err, errNothingToPromote) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "No successful " + req.From + " release to promote",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to promote release",
+		})
+	}
+
+	artifact, ok := botArtifact(c, bot, source.ArtifactDigest)
+	if !ok {
+		return nil
+	}
+	signer, ok := verifyArtifact(c, bot, artifact, "bot.promote")
+	if !ok {
+		return nil
+	}
+
+	message := req.Message
+	if message == "" {
+		message = "Promote deployment " + strconv.FormatUint(uint64(source.ID), 10) + " from " + req.From
+	}
+	userID := c.Locals("userID").(uint)
+	opts := deploy.Options{
+		DeployerID:     &userID,
+		SigningKeyID:   signerID(signer),
+		Message:        message,
+		PromotedFromID: &source.ID,
+	}
+
+	if target != models.EnvironmentProduction {
+		var deployment *models.Deployment
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			deployment, err = deploy.RecordEnvironment(tx, bot, target, deploy.Release{
+				Version:        source.Version,
+				ArtifactDigest: source.ArtifactDigest,
+				ConfigRevision: source.ConfigRevision,
+			}, opts)
+			if err != nil {
+				return err
+			}
+			return logAudit(c, tx, "bot.promote", promotionDetails(bot, source, deployment, signer))
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to promote release",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message":    "Release promoted to " + target + " successfully",
+			"deployment": deployment,
+		})
+	}
+
+	if !parseStrategy(c, bot, h.healthWindow, &req) {
+		return nil
+	}
+	opts.HealthWindow = h.healthWindow
+	opts.Strategy = req.Strategy
+	opts.BatchSize = req.BatchSize
+	opts.CanaryPercent = req.CanaryPercent
+
+	var deployment *models.Deployment
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockBot(tx, bot); err != nil {
+			return err
+		}
+		before := *bot
+
+		bot.Version = source.Version
+		bot.ArtifactDigest = source.ArtifactDigest
+		if h.healthWindow > 0 {
+			// The health gate watches the new version run.
+			bot.Status = "running"
+		}
+		if source.ConfigRevision != bot.ConfigRevision {
+			var revision models.BotConfigRevision
+			if err := tx.Where("bot_id = ? AND revision = ?", bot.ID, source.ConfigRevision).
+				First(&revision).Error; err != nil {
+				return err
+			}
+			bot.Config = revision.Config
+			if _, err := deploy.NewConfigRevision(tx, bot, message, &userID, nil); err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(bot).Error; err != nil {
+			return err
+		}
+
+		var err error
+		deployment, err = deploy.Record(tx, bot, opts)
+		if err != nil {
+			return err
+		}
+
+		details := promotionDetails(bot, source, deployment, signer)
+		details["strategy"] = req.Strategy
+		return logAuditChange(c, tx, "bot.promote", details, before, *bot)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to promote release",
+		})
+	}
+
+	message = "Release promoted to production successfully"
+	if deployment.Outcome == models.DeploymentInProgress {
+		message = "Release promoted to production; health checks run until " + deployment.HealthCheckUntil.Format(time.RFC3339)
+	}
+
+	return c.JSON(fiber.Map{
+		"message":    message,
+		"bot":        bot,
+		"deployment": deployment,
+	})
+}
+
+// environment loads the bot's environment named name. When it returns
+// false the error response has already been written.
+func (h *EnvironmentHandler) environment(c *fiber.Ctx, bot *models.Bot, name string) (*models.BotEnvironment, bool) {
+	if !models.ValidEnvironment(name) {
+		c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Environment not found",
+		})
+		return nil, false
+	}
+
+	var env models.BotEnvironment
+	if err := database.DB.Where("bot_id = ? AND name = ?", bot.ID, name).First(&env).Error; err != nil {
+		c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Environment not found",
+		})
+		return nil, false
+	}
+
+	return &env, true
+}
+
+// promotionSource returns the release of env to promote: deploymentID if
+// given, or else the environment's current release. Either must be a
+// successful deployment of an artifact.
+func promotionSource(bot *models.Bot, env string, deploymentID *uint) (*models.Deployment, error) {
+	if deploymentID == nil {
+		var current models.BotEnvironment
+		err := database.DB.Where("bot_id = ? AND name = ?", bot.ID, env).First(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && current.DeploymentID == nil) {
+			return nil, errNothingToPromote
+		}
+		if err != nil {
+			return nil, err
+		}
+		deploymentID = current.DeploymentID
+	}
+
+	var source models.Deployment
+	err := database.DB.Where("id = ? AND bot_id = ? AND environment = ? AND outcome = ? AND COALESCE(artifact_digest, '') <> ''",
+		*deploymentID, bot.ID, env, models.DeploymentSucceeded).
+		First(&source).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errNothingToPromote
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+func promotionDetails(bot *models.Bot, source, deployment *models.Deployment, signer *models.TrustedKey) fiber.Map {
+	details := fiber.Map{
+		"bot_id":           bot.ID,
+		"bot_name":         bot.Name,
+		"from":             source.Environment,
+		"environment":      deployment.Environment,
+		"version":          deployment.Version,
+		"artifact":         deployment.ArtifactDigest,
+		"config_revision":  deployment.ConfigRevision,
+		"deployment_id":    deployment.ID,
+		"promoted_from_id": source.ID,
+	}
+	addSignerDetails(details, signer)
+	return details
+}
+
+// parseStrategy validates and fills in the defaults of the rollout
+// strategy of a production release. When it returns false the error
+// response has already been written.
+func parseStrategy(c *fiber.Ctx, bot *models.Bot, healthWindow time.Duration, req *PromoteRequest) bool {
+	fail := func(message string) bool {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+		return false
+	}
+
+	switch req.Strategy {
+	case "", models.StrategyAllAtOnce:
+		req.Strategy = models.StrategyAllAtOnce
+		req.BatchSize, req.CanaryPercent = 0, 0
+		return true
+	case models.StrategyRolling, models.StrategyCanary:
+		if healthWindow <= 0 {
+			return fail("Rolling and canary deployments need the deployment health gate to be enabled")
+		}
+		if bot.Replicas < 2 {
+			return fail("Rolling and canary deployments need at least 2 replicas")
+		}
+	default:
+		return fail("Strategy must be all_at_once, rolling or canary")
+	}
+
+	if req.Strategy == models.StrategyRolling {
+		req.CanaryPercent = 0
+		if req.BatchSize == 0 {
+			req.BatchSize = 1
+		}
+		if req.BatchSize < 1 || req.BatchSize > bot.Replicas {
+			return fail("Batch size must be between 1 and the number of replicas")
+		}
+		return true
+	}
+
+	req.BatchSize = 0
+	if req.CanaryPercent == 0 {
+		req.CanaryPercent = 10
+	}
+	if req.CanaryPercent < 1 || req.CanaryPercent > 99 {
+		return fail("Canary percent must be between 1 and 99")
+	}
+	return true
+}
+
+// effectiveConfig returns the config env runs with: the bot's config at
+// the environment's revision, or the bot's current config for production
+// and environments with nothing deployed, with the overlay applied.
+func effectiveConfig(bot *models.Bot, env *models.BotEnvironment) (datatypes.JSON, error) {
+	base := []byte(bot.Config)
+	if env.Name != models.EnvironmentProduction && env.ConfigRevision > 0 && env.ConfigRevision != bot.ConfigRevision {
+		var revision models.BotConfigRevision
+		if err := database.DB.Where("bot_id = ? AND revision = ?", bot.ID, env.ConfigRevision).
+			First(&revision).Error; err != nil {
+			return nil, err
+		}
+		base = revision.Config
+	}
+
+	config, err := jsonpatch.MergePatch(base, env.ConfigOverlay)
+	if err != nil {
+		return nil, err
+	}
+	return datatypes.JSON(config), nil
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type GroupHandler struct{}
+
+func NewGroupHandler() *GroupHandler {
+	return &GroupHandler{}
+}
+
+type CreateGroupRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type GroupMemberRequest struct {
+	UserID uint `json:"user_id"`
+}
+
+func (h *GroupHandler) GetGroups(c *fiber.Ctx) error {
+	var groups []models.Group
+	if err := database.DB.Scopes(inOrganization(c)).Preload("Members").Order("name ASC").Find(&groups).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch groups",
+		})
+	}
+
+	return c.JSON(groups)
+}
+
+func (h *GroupHandler) CreateGroup(c *fiber.Ctx) error {
+	var req CreateGroupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Group name is required",
+		})
+	}
+
+	var existing models.Group
+	if err := database.DB.Scopes(inOrganization(c)).Where("name = ?", req.Name).First(&existing).Error; err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Group already exists",
+		})
+	}
+
+	group := models.Group{
+		OrganizationID: currentOrgID(c),
+		Name:           req.Name,
+		Description:    req.Description,
+	}
+
+	c.Status(fiber.StatusCreated)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+		return logAudit(c, tx, "group.create", fiber.Map{
+			"group_id":   group.ID,
+			"group_name": group.Name,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create group",
+		})
+	}
+
+	return c.JSON(group)
+}
+
+func (h *GroupHandler) DeleteGroup(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid group ID",
+		})
+	}
+
+	var group models.Group
+	if err := database.DB.Scopes(inOrganization(c)).First(&group, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Group not found",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&group).Association("Members").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Where("group_id = ?", group.ID).Delete(&models.BotACL{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&group).Error; err != nil {
+			return err
+		}
+		return logAudit(c, tx, "group.delete", fiber.Map{
+			"group_id":   group.ID,
+			"group_name": group.Name,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete group",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Group deleted successfully",
+	})
+}
+
+func (h *GroupHandler) AddMember(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid group ID",
+		})
+	}
+
+	var group models.Group
+	if err := database.DB.Scopes(inOrganization(c)).First(&group, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Group not found",
+		})
+	}
+
+	var req GroupMemberRequest
+	if err := c.BodyParser(&req); err != nil || req.UserID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "user_id is required",
+		})
+	}
+
+	var user models.User
+	if !isOrgMember(c, req.UserID) || database.DB.First(&user, req.UserID).Error != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	// Group membership grants the group's access to bots, so only callers
+	// who already manage every one of those bots may hand it out.
+	bot, err := unmanagedGroupBot(c, group.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to add group member",
+		})
+	}
+	if bot != nil {
+		logAuditFailure(c, "group.member.add", fiber.StatusForbidden, fiber.Map{
+			"group_id":   group.ID,
+			"group_name": group.Name,
+			"user_id":    user.ID,
+			"bot_id":     bot.ID,
+			"reason":     "caller does not manage a bot the group has access to",
+		})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You must manage every bot this group has access to",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&group).Association("Members").Append(&user); err != nil {
+			return err
+		}
+		return logAudit(c, tx, "group.member.add", fiber.Map{
+			"group_id":   group.ID,
+			"group_name": group.Name,
+			"user_id":    user.ID,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to add group member",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Member added successfully",
+	})
+}
+
+func (h *GroupHandler) RemoveMember(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid group ID",
+		})
+	}
+
+	userID, err := strconv.ParseUint(c.Params("userId"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var group models.Group
+	if err := database.DB.Scopes(inOrganization(c)).First(&group, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Group not found",
+		})
+	}
+
+	user := models.User{ID: uint(userID)}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&group).Association("Members").Delete(&user); err != nil {
+			return err
+		}
+		return logAudit(c, tx, "group.member.remove", fiber.Map{
+			"group_id":   group.ID,
+			"group_name": group.Name,
+			"user_id":    user.ID,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to remove group member",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Member removed successfully",
+	})
+}
+
+// unmanagedGroupBot returns a bot the group has an access list entry on
+// that the caller does not manage, or nil if the caller manages them all.
+// Super-admins manage every bot.
+func unmanagedGroupBot(c *fiber.Ctx, groupID uint) (*models.Bot, error) {
+	if superAdmin, _ := c.Locals("superAdmin").(bool); superAdmin {
+		return nil, nil
+	}
+
+	var bots []models.Bot
+	if err := database.DB.Where("id IN (?)",
+		database.DB.Model(&models.BotACL{}).Select("bot_id").Where("group_id = ?", groupID)).
+		Find(&bots).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range bots {
+		if !canAccessBot(c, &bots[i], models.BotPermissionManage) {
+			return &bots[i], nil
+		}
+	}
+	return nil, nil
+}
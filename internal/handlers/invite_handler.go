@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/FRFebi/bot-management-backend/pkg/auth"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type InviteHandler struct {
+	publicURL   string
+	expiryHours int
+}
+
+func NewInviteHandler(cfg *config.Config) *InviteHandler {
+	return &InviteHandler{
+		publicURL:   strings.TrimRight(cfg.Server.PublicURL, "/"),
+		expiryHours: cfg.Registration.InviteExpiryHours,
+	}
+}
+
+type CreateInviteRequest struct {
+	Email          string `json:"email"`
+	Role           string `json:"role"`
+	ExpiresInHours int    `json:"expires_in_hours,omitempty"`
+}
+
+type CreateInviteResponse struct {
+	Invite models.Invite `json:"invite"`
+	Token  string        `json:"token"`
+	URL    string        `json:"url"`
+}
+
+func (h *InviteHandler) GetInvites(c *fiber.Ctx) error {
+	query := database.DB.Scopes(inOrganization(c)).Order("created_at DESC")
+	if c.Query("pending") == "true" {
+		query = query.Where("used_at IS NULL AND expires_at > ?", time.Now().UTC())
+	}
+
+	var invites []models.Invite
+	if err := query.Find(&invites).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch invites",
+		})
+	}
+
+	return c.JSON(invites)
+}
+
+// CreateInvite issues a single-use invitation into the caller's current
+// organization. The token is only returned here; the database keeps its
+// hash.
+func (h *InviteHandler) CreateInvite(c *fiber.Ctx) error {
+	var req CreateInviteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Role != "admin" && req.Role != "viewer" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Role must be admin or viewer",
+		})
+	}
+
+	expiryHours := h.expiryHours
+	if req.ExpiresInHours > 0 {
+		expiryHours = req.ExpiresInHours
+	}
+
+	token, tokenHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate invite token",
+		})
+	}
+
+	invite := models.Invite{
+		OrganizationID: currentOrgID(c),
+		Email:          strings.TrimSpace(req.Email),
+		Role:           req.Role,
+		TokenHash:      tokenHash,
+		ExpiresAt:      time.Now().UTC().Add(time.Duration(expiryHours) * time.Hour),
+		CreatedByID:    c.Locals("userID").(uint),
+	}
+
+	c.Status(fiber.StatusCreated)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&invite).Error; err != nil {
+			return err
+		}
+		return logAudit(c, tx, "invite.create", fiber.Map{
+			"invite_id":  invite.ID,
+			"email":      invite.Email,
+			"role":       invite.Role,
+			"expires_at": invite.ExpiresAt,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create invite",
+		})
+	}
+
+	return c.JSON(CreateInviteResponse{
+		Invite: invite,
+		Token:  token,
+		URL:    h.publicURL + "/register?invite=" + token,
+	})
+}
+
+func (h *InviteHandler) RevokeInvite(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid invite ID",
+		})
+	}
+
+	var invite models.Invite
+	if err := database.DB.Scopes(inOrganization(c)).First(&invite, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Invite not found",
+		})
+	}
+
+	if invite.UsedAt != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invite has already been used",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&invite).Error; err != nil {
+			return err
+		}
+		return logAudit(c, tx, "invite.revoke", fiber.Map{
+			"invite_id": invite.ID,
+			"email":     invite.Email,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke invite",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Invite revoked successfully",
+	})
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"github.com/FRFebi/bot-management-backend/pkg/auth"
+	"github.com/gofiber/fiber/v2"
+)
+
+type JWKSHandler struct {
+	keys *auth.KeySet
+}
+
+func NewJWKSHandler(keys *auth.KeySet) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// GetJWKS serves the public keys that may have signed a currently valid
+// token, including retired keys still within their overlap period.
+func (h *JWKSHandler) GetJWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(h.keys.JWKS())
+}
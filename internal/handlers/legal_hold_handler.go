@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// LegalHoldHandler manages legal holds, which keep the audit entries about
+// a user or bot online past the retention period. Audit storage spans all
+// organizations, so these routes are for super-admins.
+type LegalHoldHandler struct{}
+
+func NewLegalHoldHandler() *LegalHoldHandler {
+	return &LegalHoldHandler{}
+}
+
+type CreateLegalHoldRequest struct {
+	UserID *uint  `json:"user_id"`
+	BotID  *uint  `json:"bot_id"`
+	Reason string `json:"reason"`
+}
+
+func (h *LegalHoldHandler) GetLegalHolds(c *fiber.Ctx) error {
+	query := database.DB.Order("created_at DESC")
+	if c.Query("active") == "true" {
+		query = query.Where("released_at IS NULL")
+	}
+
+	var holds []models.LegalHold
+	if err := query.Find(&holds).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch legal holds",
+		})
+	}
+
+	return c.JSON(holds)
+}
+
+func (h *LegalHoldHandler) CreateLegalHold(c *fiber.Ctx) error {
+	var req CreateLegalHoldRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if (req.UserID == nil) == (req.BotID == nil) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Exactly one of user_id or bot_id is required",
+		})
+	}
+
+	if req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Reason is required",
+		})
+	}
+
+	hold := models.LegalHold{
+		UserID:      req.UserID,
+		BotID:       req.BotID,
+		Reason:      req.Reason,
+		CreatedByID: c.Locals("userID").(uint),
+	}
+
+	c.Status(fiber.StatusCreated)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&hold).Error; err != nil {
+			return err
+		}
+		return logAudit(c, tx, "audit.hold.create", fiber.Map{
+			"hold_id":      hold.ID,
+			"held_user_id": hold.UserID,
+			"held_bot_id":  hold.BotID,
+			"reason":       hold.Reason,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create legal hold",
+		})
+	}
+
+	return c.JSON(hold)
+}
+
+// ReleaseLegalHold ends a hold. The entries it kept are archived or
+// deleted by the next archive run, unless another hold still covers them.
+func (h *LegalHoldHandler) ReleaseLegalHold(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid legal hold ID",
+		})
+	}
+
+	var hold models.LegalHold
+	if err := database.DB.First(&hold, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Legal hold not found",
+		})
+	}
+
+	if !hold.Active() {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Legal hold is already released",
+		})
+	}
+
+	userID := c.Locals("userID").(uint)
+	now := time.Now().UTC()
+	hold.ReleasedAt = &now
+	hold.ReleasedByID = &userID
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&hold).Updates(map[string]interface{}{
+			"released_at":    now,
+			"released_by_id": userID,
+		}).Error; err != nil {
+			return err
+		}
+		return logAudit(c, tx, "audit.hold.release", fiber.Map{
+			"hold_id":      hold.ID,
+			"held_user_id": hold.UserID,
+			"held_bot_id":  hold.BotID,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to release legal hold",
+		})
+	}
+
+	return c.JSON(hold)
+}
@@ -0,0 +1,491 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/loginguard"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/FRFebi/bot-management-backend/pkg/auth"
+	"github.com/FRFebi/bot-management-backend/pkg/totp"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+type MFAHandler struct {
+	jwtManager *auth.JWTManager
+	guard      *loginguard.Guard
+	issuer     string
+}
+
+func NewMFAHandler(cfg *config.Config, jwtManager *auth.JWTManager, guard *loginguard.Guard) *MFAHandler {
+	return &MFAHandler{
+		jwtManager: jwtManager,
+		guard:      guard,
+		issuer:     cfg.MFA.Issuer,
+	}
+}
+
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+// MFADisableRequest confirms disabling MFA. Local accounts give their
+// password and a TOTP code; accounts from an external provider have no
+// usable local password and give a TOTP or recovery code instead.
+type MFADisableRequest struct {
+	Password     string `json:"password,omitempty"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type MFAEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// Verify redeems a challenge token from Login together with a TOTP or
+// recovery code and returns an access token.
+func (h *MFAHandler) Verify(c *fiber.Ctx) error {
+	var req MFAVerifyRequest
+	if err := c.BodyParser(&req); err != nil || req.MFAToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "mfa_token is required",
+		})
+	}
+
+	claims, err := h.jwtManager.ValidateToken(req.MFAToken)
+	if err != nil || claims.Purpose != auth.PurposeMFAChallenge {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired MFA token",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, claims.UserID).Error; err != nil ||
+		!user.IsActive || !user.MFAEnabled || user.TokenVersion != claims.TokenVersion {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired MFA token",
+		})
+	}
+
+	// Second-factor guesses count towards the same lockout as passwords.
+	if decision, err := h.guard.Check(loginguard.AccountKey(user.ID), c.IP()); err == nil && !decision.Allowed {
+		return throttledResponse(c, decision)
+	}
+
+	method := "totp"
+	var ok bool
+	if req.RecoveryCode != "" {
+		method = "recovery_code"
+		ok = useRecoveryCode(user.ID, req.RecoveryCode)
+	} else {
+		ok = checkTOTP(&user, req.Code)
+	}
+
+	if !ok {
+		failure, _ := h.guard.RecordFailure(loginguard.AccountKey(user.ID), c.IP())
+		recordAudit(c, database.DB, claims.OrganizationID, &user.ID, "auth.mfa.failure", fiber.StatusUnauthorized, fiber.Map{
+			"method":   method,
+			"failures": failure.AccountFailures,
+		})
+		if failure.Locked {
+			recordAudit(c, database.DB, claims.OrganizationID, &user.ID, "auth.login.lockout", fiber.StatusUnauthorized, fiber.Map{
+				"login": user.Email,
+			})
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid verification code",
+		})
+	}
+
+	if method == "recovery_code" {
+		if err := recordAudit(c, database.DB, claims.OrganizationID, &user.ID, "auth.mfa.recovery_code_used", fiber.StatusOK, nil); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to record audit log",
+			})
+		}
+	}
+
+	identity, err := resolveIdentity(&user, claims.OrganizationID)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := recordLoginSuccess(c, h.guard, &user, user.Email, fiber.Map{
+		"provider":   user.AuthProvider,
+		"mfa_method": method,
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record audit log",
+		})
+	}
+
+	return sessionResponse(c, h.jwtManager, &user, identity)
+}
+
+// Enroll starts (or restarts) TOTP enrollment by generating a new secret.
+// MFA is not active until the secret is confirmed with a valid code.
+func (h *MFAHandler) Enroll(c *fiber.Ctx) error {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return nil
+	}
+
+	if user.MFAEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Multi-factor authentication is already enabled",
+		})
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate secret",
+		})
+	}
+
+	if err := database.DB.Model(user).Update("mfa_secret", secret).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start enrollment",
+		})
+	}
+
+	return c.JSON(MFAEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(h.issuer, user.Email, secret),
+	})
+}
+
+// ConfirmEnrollment enables MFA once the user proves their authenticator
+// produces valid codes, and returns a fresh set of recovery codes. When
+// called with an enrollment token from Login it also returns an access
+// token.
+func (h *MFAHandler) ConfirmEnrollment(c *fiber.Ctx) error {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return nil
+	}
+
+	var req MFACodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if user.MFAEnabled || user.MFASecret == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No enrollment in progress",
+		})
+	}
+
+	if !checkTOTP(user, req.Code) {
+		logAuditFailure(c, "user.mfa.enroll", fiber.StatusBadRequest, nil)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid verification code",
+		})
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"mfa_enabled":     true,
+			"mfa_enrolled_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		return logAudit(c, tx, "user.mfa.enroll", nil)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to enable multi-factor authentication",
+		})
+	}
+
+	response := fiber.Map{
+		"message":        "Multi-factor authentication enabled",
+		"recovery_codes": codes,
+	}
+
+	if c.Locals("purpose") == auth.PurposeMFAEnrollment {
+		identity, err := resolveIdentity(user, currentOrgID(c))
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := recordLoginSuccess(c, h.guard, user, user.Email, fiber.Map{
+			"provider":     user.AuthProvider,
+			"mfa_enrolled": true,
+		}); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to record audit log",
+			})
+		}
+
+		token, err := h.jwtManager.GenerateToken(identity)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to generate token",
+			})
+		}
+		response["token"] = token
+	}
+
+	return c.JSON(response)
+}
+
+func (h *MFAHandler) Disable(c *fiber.Ctx) error {
+	user, ok := h.currentUser(c)
+	if !ok || !h.requireAccessToken(c) {
+		return nil
+	}
+
+	var req MFADisableRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if !user.MFAEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Multi-factor authentication is not enabled",
+		})
+	}
+
+	if h.throttled(c, user) {
+		return nil
+	}
+
+	var verified bool
+	if user.AuthProvider == models.AuthProviderLocal {
+		verified = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) == nil &&
+			checkTOTP(user, req.Code)
+	} else if req.RecoveryCode != "" {
+		verified = useRecoveryCode(user.ID, req.RecoveryCode)
+	} else {
+		verified = checkTOTP(user, req.Code)
+	}
+
+	if !verified {
+		h.recordFailure(c, user)
+		logAuditFailure(c, "user.mfa.disable", fiber.StatusUnauthorized, nil)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid password or verification code",
+		})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := disableMFA(tx, user.ID); err != nil {
+			return err
+		}
+		return logAudit(c, tx, "user.mfa.disable", nil)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to disable multi-factor authentication",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Multi-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the caller.
+func (h *MFAHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	user, ok := h.currentUser(c)
+	if !ok || !h.requireAccessToken(c) {
+		return nil
+	}
+
+	var req MFACodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if !user.MFAEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Multi-factor authentication is not enabled",
+		})
+	}
+
+	if h.throttled(c, user) {
+		return nil
+	}
+
+	if !checkTOTP(user, req.Code) {
+		h.recordFailure(c, user)
+		logAuditFailure(c, "user.mfa.recovery_codes", fiber.StatusUnauthorized, nil)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid verification code",
+		})
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		return logAudit(c, tx, "user.mfa.recovery_codes", nil)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate recovery codes",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"recovery_codes": codes,
+	})
+}
+
+// throttled applies the login guard to a second-factor check outside of
+// login, so a stolen session cannot be used to guess codes without limit. It
+// writes the response when the account is locked or the client must wait.
+func (h *MFAHandler) throttled(c *fiber.Ctx, user *models.User) bool {
+	decision, err := h.guard.Check(loginguard.AccountKey(user.ID), c.IP())
+	if err != nil || decision.Allowed {
+		return false
+	}
+	throttledResponse(c, decision)
+	return true
+}
+
+// recordFailure counts a wrong password or code towards the same lockout as
+// failed logins.
+func (h *MFAHandler) recordFailure(c *fiber.Ctx, user *models.User) {
+	failure, _ := h.guard.RecordFailure(loginguard.AccountKey(user.ID), c.IP())
+	if failure.Locked {
+		logAuditFailure(c, "auth.login.lockout", fiber.StatusUnauthorized, fiber.Map{
+			"login": user.Email,
+		})
+	}
+}
+
+func (h *MFAHandler) currentUser(c *fiber.Ctx) (*models.User, bool) {
+	var user models.User
+	if err := database.DB.First(&user, c.Locals("userID").(uint)).Error; err != nil {
+		c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+		return nil, false
+	}
+
+	return &user, true
+}
+
+func (h *MFAHandler) requireAccessToken(c *fiber.Ctx) bool {
+	if c.Locals("purpose") == "" {
+		return true
+	}
+
+	c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"error": "Invalid or expired token",
+	})
+	return false
+}
+
+// checkTOTP validates code against the user's secret and records the
+// matched time step, so the same code cannot be replayed.
+func checkTOTP(user *models.User, code string) bool {
+	if user.MFASecret == "" {
+		return false
+	}
+
+	step, ok := totp.Validate(code, user.MFASecret, time.Now(), 1)
+	if !ok {
+		return false
+	}
+
+	result := database.DB.Model(&models.User{}).
+		Where("id = ? AND mfa_last_used_step < ?", user.ID, step).
+		Update("mfa_last_used_step", step)
+
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+func useRecoveryCode(userID uint, code string) bool {
+	result := database.DB.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, auth.HashOpaqueToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now().UTC())
+
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+func replaceRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	if err := db.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]models.MFARecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		rows = append(rows, models.MFARecoveryCode{
+			UserID:   userID,
+			CodeHash: auth.HashOpaqueToken(raw),
+		})
+	}
+
+	if err := db.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// disableMFA clears the user's TOTP secret and recovery codes.
+func disableMFA(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"mfa_enabled":        false,
+			"mfa_secret":         "",
+			"mfa_enrolled_at":    nil,
+			"mfa_last_used_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error
+	})
+}
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/authn"
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/loginguard"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/FRFebi/bot-management-backend/pkg/auth"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	oidcStateTTL     = 10 * time.Minute
+	oidcRequestLimit = 15 * time.Second
+
+	// oidcStateCookie carries the hash of the state of the login the
+	// browser started.
+	oidcStateCookie = "oidc_state"
+)
+
+var errOIDCNoRole = errors.New("no role is mapped to this account")
+
+// OIDCHandler implements the authorization code flow with PKCE against an
+// OpenID Connect provider. The dashboard starts the flow with Login, the
+// provider redirects the browser back to the dashboard, and the dashboard
+// posts the code and state to Callback. From there the login goes through
+// the same throttling, auditing and MFA steps as a password login.
+//
+// Login also sets a cookie with the hash of the state, and Callback only
+// accepts a state together with its cookie. Without that binding, a code and
+// state obtained by someone else could be posted from a victim's browser and
+// sign the victim in to the attacker's account.
+type OIDCHandler struct {
+	cfg          config.OIDCConfig
+	login        *AuthHandler
+	secureCookie bool
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func NewOIDCHandler(cfg *config.Config, login *AuthHandler) *OIDCHandler {
+	return &OIDCHandler{
+		cfg:          cfg.OIDC,
+		login:        login,
+		secureCookie: cfg.Server.IsProduction(),
+	}
+}
+
+type OIDCCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+type oidcClaims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified *bool  `json:"email_verified"`
+	Name          string `json:"name"`
+	Nonce         string `json:"nonce"`
+}
+
+// Login returns the provider URL the browser should be sent to.
+func (h *OIDCHandler) Login(c *fiber.Ctx) error {
+	oauthConfig, _, err := h.client(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Single sign-on is unavailable",
+		})
+	}
+
+	state, stateHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start single sign-on",
+		})
+	}
+
+	nonce, _, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start single sign-on",
+		})
+	}
+
+	verifier := oauth2.GenerateVerifier()
+
+	loginState := models.OIDCLoginState{
+		StateHash:    stateHash,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().UTC().Add(oidcStateTTL),
+	}
+	if err := database.DB.Create(&loginState).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start single sign-on",
+		})
+	}
+
+	// Expired states are only useful for debugging a failed login; clear
+	// them out opportunistically.
+	database.DB.Where("expires_at < ?", time.Now().UTC()).Delete(&models.OIDCLoginState{})
+
+	h.bindState(c, stateHash)
+
+	return c.JSON(fiber.Map{
+		"authorization_url": oauthConfig.AuthCodeURL(state,
+			oidc.Nonce(nonce),
+			oauth2.S256ChallengeOption(verifier),
+		),
+	})
+}
+
+// Callback exchanges the authorization code, verifies the ID token,
+// provisions or updates the local user and completes the login like a
+// password login would.
+func (h *OIDCHandler) Callback(c *fiber.Ctx) error {
+	var req OIDCCallbackRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" || req.State == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Code and state are required",
+		})
+	}
+
+	oauthConfig, verifier, err := h.client(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Single sign-on is unavailable",
+		})
+	}
+
+	if !h.stateBound(c, req.State) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Login request is invalid or has expired",
+		})
+	}
+
+	// Each state can be redeemed once, so it is deleted as it is read.
+	var loginState models.OIDCLoginState
+	result := database.DB.Clauses(clause.Returning{}).
+		Where("state_hash = ? AND expires_at > ?", auth.HashOpaqueToken(req.State), time.Now().UTC()).
+		Delete(&loginState)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Login request is invalid or has expired",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oidcRequestLimit)
+	defer cancel()
+
+	token, err := oauthConfig.Exchange(ctx, req.Code, oauth2.VerifierOption(loginState.CodeVerifier))
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Failed to exchange authorization code",
+		})
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Provider did not return an ID token",
+		})
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid ID token",
+		})
+	}
+
+	var claims oidcClaims
+	var rawClaims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil || idToken.Claims(&rawClaims) != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid ID token",
+		})
+	}
+
+	if claims.Nonce != loginState.Nonce {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid ID token",
+		})
+	}
+
+	// Accounts are matched by email, so an address the provider has not
+	// verified, or does not say it verified, is not trusted.
+	if claims.Email == "" || claims.EmailVerified == nil || !*claims.EmailVerified {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Provider did not return a verified email address",
+		})
+	}
+
+	role := authn.MapRole(groupsFromClaims(rawClaims[h.cfg.GroupsClaim]), h.cfg.RoleMapping, h.cfg.DefaultRole)
+	if role == "" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": errOIDCNoRole.Error(),
+		})
+	}
+
+	user, err := authn.Provision(ctx, authn.ExternalIdentity{
+		Provider:     models.AuthProviderOIDC,
+		Subject:      claims.Subject,
+		Email:        claims.Email,
+		Name:         claims.Name,
+		Role:         role,
+		Organization: h.cfg.Organization,
+	})
+	if errors.Is(err, authn.ErrAccountConflict) {
+		auditLogin(c, nil, "auth.login.failure", fiber.StatusConflict, fiber.Map{
+			"login":    claims.Email,
+			"provider": models.AuthProviderOIDC,
+			"subject":  claims.Subject,
+			"reason":   "account_conflict",
+		})
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "An account with this email already exists and cannot be linked to single sign-on",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to provision user",
+		})
+	}
+
+	// A locked account stays locked for single sign-on too.
+	decision, err := h.login.guard.Check(loginguard.AccountKey(user.ID), c.IP())
+	if err != nil {
+		h.login.log.Errorf("Failed to check login throttle: %v", err)
+	} else if !decision.Allowed {
+		return throttledResponse(c, decision)
+	}
+
+	return h.login.finishLogin(c, user, claims.Email, 0, fiber.Map{
+		"subject": claims.Subject,
+	})
+}
+
+// bindState sets the cookie tying the login with stateHash to this browser.
+// It is scoped to the OIDC routes and expires with the state.
+func (h *OIDCHandler) bindState(c *fiber.Ctx, stateHash string) {
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    stateHash,
+		Path:     path.Dir(c.Path()),
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		Secure:   h.secureCookie,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+// stateBound reports whether the browser posting state is the one that
+// started its login, and clears the cookie either way.
+func (h *OIDCHandler) stateBound(c *fiber.Ctx, state string) bool {
+	bound := c.Cookies(oidcStateCookie)
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Path:     path.Dir(c.Path()),
+		Expires:  time.Unix(0, 0),
+		Secure:   h.secureCookie,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	return bound != "" &&
+		subtle.ConstantTimeCompare([]byte(bound), []byte(auth.HashOpaqueToken(state))) == 1
+}
+
+// client lazily discovers the provider, so the server can start while the
+// identity provider (or a local mock of it) is still coming up.
+func (h *OIDCHandler) client(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.provider == nil {
+		discoverCtx, cancel := context.WithTimeout(context.Background(), oidcRequestLimit)
+		defer cancel()
+
+		provider, err := oidc.NewProvider(discoverCtx, h.cfg.IssuerURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+		}
+		h.provider = provider
+	}
+
+	oauthConfig := &oauth2.Config{
+		ClientID:     h.cfg.ClientID,
+		ClientSecret: h.cfg.ClientSecret,
+		RedirectURL:  h.cfg.RedirectURL,
+		Endpoint:     h.provider.Endpoint(),
+		Scopes:       h.cfg.Scopes,
+	}
+	verifier := h.provider.Verifier(&oidc.Config{ClientID: h.cfg.ClientID})
+
+	return oauthConfig, verifier, nil
+}
+
+func groupsFromClaims(value interface{}) []string {
+	switch v := value.(type) {
+	case []interface{}:
+		groups := make([]string, 0, len(v))
+		for _, item := range v {
+			if group, ok := item.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+		return groups
+	case string:
+		return strings.Split(v, ",")
+	default:
+		return nil
+	}
+}
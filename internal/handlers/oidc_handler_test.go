@@ -0,0 +1,534 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/authn"
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/loginguard"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/FRFebi/bot-management-backend/pkg/auth"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const mockClientID = "bot-management"
+
+// mockProvider is a minimal OpenID Connect provider. It serves discovery,
+// its signing keys and a token endpoint that only redeems a code for the
+// PKCE verifier matching the challenge the code was issued for.
+type mockProvider struct {
+	server *httptest.Server
+	keys   *auth.KeySet
+
+	mu     sync.Mutex
+	grants map[string]mockGrant
+}
+
+type mockGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := auth.GenerateSigningKey(auth.AlgorithmRS256, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate provider key: %v", err)
+	}
+
+	p := &mockProvider{
+		keys:   auth.NewKeySet([]*auth.SigningKey{key}),
+		grants: make(map[string]mockGrant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{auth.AlgorithmRS256},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, p.keys.JWKS())
+	})
+	mux.HandleFunc("/token", p.token)
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	p.mu.Lock()
+	grant, ok := p.grants[r.PostForm.Get("code")]
+	delete(p.grants, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, _, err := p.keys.Sign(grant.claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+// authorize plays the user signing in at the provider for the request in
+// authURL and returns the authorization code. The ID token carries the
+// nonce of the request and a verified email unless edit changes them.
+func (p *mockProvider) authorize(t *testing.T, authURL *url.URL, email string, groups []string, edit func(jwt.MapClaims)) string {
+	t.Helper()
+
+	query := authURL.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization request does not use PKCE: %s", authURL)
+	}
+	if query.Get("state") == "" || query.Get("nonce") == "" {
+		t.Fatalf("authorization request has no state or nonce: %s", authURL)
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            p.server.URL,
+		"aud":            mockClientID,
+		"sub":            "subject-" + email,
+		"email":          email,
+		"email_verified": true,
+		"name":           "SSO User",
+		"groups":         groups,
+		"nonce":          query.Get("nonce"),
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	}
+	challenge := query.Get("code_challenge")
+	if edit != nil {
+		edit(claims)
+		if override, ok := claims["code_challenge"].(string); ok {
+			challenge = override
+			delete(claims, "code_challenge")
+		}
+	}
+
+	code, _, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		t.Fatalf("failed to generate code: %v", err)
+	}
+
+	p.mu.Lock()
+	p.grants[code] = mockGrant{challenge: challenge, claims: claims}
+	p.mu.Unlock()
+	return code
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// connectTestDB points the database package at TEST_DATABASE_URL and
+// migrates it. Tests needing the database are skipped without it.
+func connectTestDB(t *testing.T) {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	database.DB = db
+
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+}
+
+type oidcTestApp struct {
+	app      *fiber.App
+	provider *mockProvider
+
+	// cookies plays the browser's cookie jar.
+	cookies map[string]string
+}
+
+func newOIDCTestApp(t *testing.T, enforcedRoles []string) *oidcTestApp {
+	t.Helper()
+
+	provider := newMockProvider(t)
+
+	key, err := auth.GenerateSigningKey(auth.AlgorithmEdDSA, 0)
+	if err != nil {
+		t.Fatalf("failed to generate signing key: %v", err)
+	}
+	jwtManager := auth.NewJWTManager(auth.NewKeySet([]*auth.SigningKey{key}), "test", []string{"test"}, 1)
+
+	cfg := &config.Config{
+		Password: config.PasswordConfig{MinLength: 8},
+		MFA: config.MFAConfig{
+			Issuer:           "test",
+			EnforcedRoles:    enforcedRoles,
+			ChallengeMinutes: 5,
+		},
+		OIDC: config.OIDCConfig{
+			Enabled:      true,
+			IssuerURL:    provider.server.URL,
+			ClientID:     mockClientID,
+			ClientSecret: "secret",
+			RedirectURL:  "http://localhost/callback",
+			Scopes:       []string{"openid", "email", "profile", "groups"},
+			GroupsClaim:  "groups",
+			RoleMapping:  map[string]string{"bot-admins": "admin"},
+			DefaultRole:  "viewer",
+			Organization: models.DefaultOrganizationSlug,
+		},
+		Login: config.LoginProtectionConfig{
+			FreeAttempts:       100,
+			AccountMaxFailures: 100,
+			IPMaxFailures:      100,
+			LockoutMinutes:     1,
+			WindowMinutes:      1,
+		},
+	}
+
+	guard := loginguard.New(cfg.Login)
+	authHandler := NewAuthHandler(cfg, jwtManager, authn.Chain{authn.NewLocalAuthenticator()}, guard)
+	oidcHandler := NewOIDCHandler(cfg, authHandler)
+
+	app := fiber.New()
+	app.Get("/oidc/login", oidcHandler.Login)
+	app.Post("/oidc/callback", oidcHandler.Callback)
+
+	return &oidcTestApp{app: app, provider: provider, cookies: make(map[string]string)}
+}
+
+// start begins a login and returns the authorization request the browser
+// would be sent to.
+func (a *oidcTestApp) start(t *testing.T) *url.URL {
+	t.Helper()
+
+	var body struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+	if status := a.do(t, http.MethodGet, "/oidc/login", nil, &body); status != fiber.StatusOK {
+		t.Fatalf("login returned %d", status)
+	}
+
+	authURL, err := url.Parse(body.AuthorizationURL)
+	if err != nil || !strings.HasPrefix(body.AuthorizationURL, a.provider.server.URL+"/authorize") {
+		t.Fatalf("unexpected authorization URL %q", body.AuthorizationURL)
+	}
+	return authURL
+}
+
+func (a *oidcTestApp) callback(t *testing.T, code, state string) (int, map[string]interface{}) {
+	t.Helper()
+
+	var body map[string]interface{}
+	status := a.do(t, http.MethodPost, "/oidc/callback", OIDCCallbackRequest{Code: code, State: state}, &body)
+	return status, body
+}
+
+func (a *oidcTestApp) do(t *testing.T, method, path string, in, out interface{}) int {
+	t.Helper()
+
+	var reader io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = strings.NewReader(string(data))
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range a.cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+	resp, err := a.app.Test(req, 30_000)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Value == "" {
+			delete(a.cookies, cookie.Name)
+		} else {
+			a.cookies[cookie.Name] = cookie.Value
+		}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatalf("%s %s returned invalid JSON: %v", method, path, err)
+	}
+	return resp.StatusCode
+}
+
+func uniqueEmail(prefix string) string {
+	return fmt.Sprintf("%s-%d@example.com", prefix, time.Now().UnixNano())
+}
+
+func TestOIDCCallback(t *testing.T) {
+	connectTestDB(t)
+
+	t.Run("issues a session", func(t *testing.T) {
+		a := newOIDCTestApp(t, nil)
+		authURL := a.start(t)
+		code := a.provider.authorize(t, authURL, uniqueEmail("sso"), nil, nil)
+
+		status, body := a.callback(t, code, authURL.Query().Get("state"))
+		if status != fiber.StatusOK || body["token"] == nil {
+			t.Fatalf("expected a session, got %d %v", status, body)
+		}
+	})
+
+	t.Run("rejects a replayed state", func(t *testing.T) {
+		a := newOIDCTestApp(t, nil)
+		authURL := a.start(t)
+		email := uniqueEmail("replay")
+		state := authURL.Query().Get("state")
+
+		if status, body := a.callback(t, a.provider.authorize(t, authURL, email, nil, nil), state); status != fiber.StatusOK {
+			t.Fatalf("first callback returned %d %v", status, body)
+		}
+		if status, _ := a.callback(t, a.provider.authorize(t, authURL, email, nil, nil), state); status != fiber.StatusBadRequest {
+			t.Fatalf("replayed state returned %d, want 400", status)
+		}
+	})
+
+	t.Run("rejects an unknown state", func(t *testing.T) {
+		a := newOIDCTestApp(t, nil)
+		authURL := a.start(t)
+		code := a.provider.authorize(t, authURL, uniqueEmail("state"), nil, nil)
+
+		if status, _ := a.callback(t, code, "forged-state"); status != fiber.StatusBadRequest {
+			t.Fatalf("unknown state returned %d, want 400", status)
+		}
+	})
+
+	t.Run("rejects a state started in another browser", func(t *testing.T) {
+		a := newOIDCTestApp(t, nil)
+		authURL := a.start(t)
+		code := a.provider.authorize(t, authURL, uniqueEmail("csrf"), nil, nil)
+
+		victim := *a
+		victim.cookies = make(map[string]string)
+		if status, _ := victim.callback(t, code, authURL.Query().Get("state")); status != fiber.StatusBadRequest {
+			t.Fatalf("unbound state returned %d, want 400", status)
+		}
+	})
+
+	t.Run("rejects a code issued for another PKCE challenge", func(t *testing.T) {
+		a := newOIDCTestApp(t, nil)
+		authURL := a.start(t)
+		other := sha256.Sum256([]byte("another verifier"))
+		code := a.provider.authorize(t, authURL, uniqueEmail("pkce"), nil, func(claims jwt.MapClaims) {
+			claims["code_challenge"] = base64.RawURLEncoding.EncodeToString(other[:])
+		})
+
+		if status, _ := a.callback(t, code, authURL.Query().Get("state")); status != fiber.StatusUnauthorized {
+			t.Fatalf("mismatched PKCE returned %d, want 401", status)
+		}
+	})
+
+	t.Run("rejects a mismatched nonce", func(t *testing.T) {
+		a := newOIDCTestApp(t, nil)
+		authURL := a.start(t)
+		code := a.provider.authorize(t, authURL, uniqueEmail("nonce"), nil, func(claims jwt.MapClaims) {
+			claims["nonce"] = "replayed-nonce"
+		})
+
+		if status, _ := a.callback(t, code, authURL.Query().Get("state")); status != fiber.StatusUnauthorized {
+			t.Fatalf("mismatched nonce returned %d, want 401", status)
+		}
+	})
+
+	t.Run("requires a verified email", func(t *testing.T) {
+		a := newOIDCTestApp(t, nil)
+		authURL := a.start(t)
+		code := a.provider.authorize(t, authURL, uniqueEmail("unverified"), nil, func(claims jwt.MapClaims) {
+			delete(claims, "email_verified")
+		})
+
+		if status, _ := a.callback(t, code, authURL.Query().Get("state")); status != fiber.StatusForbidden {
+			t.Fatalf("unverified email returned %d, want 403", status)
+		}
+	})
+
+	t.Run("does not take over a local account", func(t *testing.T) {
+		a := newOIDCTestApp(t, nil)
+		email := uniqueEmail("local")
+		org, err := database.DefaultOrganization()
+		if err != nil {
+			t.Fatal(err)
+		}
+		user, err := newUser("Local User", email, "local-password", "admin", org.ID)
+		if err != nil || database.DB.Create(user).Error != nil {
+			t.Fatalf("failed to create local user: %v", err)
+		}
+
+		authURL := a.start(t)
+		code := a.provider.authorize(t, authURL, email, nil, nil)
+		if status, _ := a.callback(t, code, authURL.Query().Get("state")); status != fiber.StatusConflict {
+			t.Fatalf("local account returned %d, want 409", status)
+		}
+	})
+
+	t.Run("challenges users with MFA", func(t *testing.T) {
+		a := newOIDCTestApp(t, nil)
+		email := uniqueEmail("mfa")
+
+		authURL := a.start(t)
+		if status, body := a.callback(t, a.provider.authorize(t, authURL, email, nil, nil), authURL.Query().Get("state")); status != fiber.StatusOK {
+			t.Fatalf("first login returned %d %v", status, body)
+		}
+		if err := database.DB.Model(&models.User{}).Where("email = ?", email).Updates(map[string]interface{}{
+			"mfa_enabled": true,
+			"mfa_secret":  "JBSWY3DPEHPK3PXP",
+		}).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		authURL = a.start(t)
+		status, body := a.callback(t, a.provider.authorize(t, authURL, email, nil, nil), authURL.Query().Get("state"))
+		if status != fiber.StatusOK || body["mfa_required"] != true || body["mfa_token"] == nil || body["token"] != nil {
+			t.Fatalf("expected an MFA challenge, got %d %v", status, body)
+		}
+	})
+
+	t.Run("requires enrollment for enforced roles", func(t *testing.T) {
+		a := newOIDCTestApp(t, []string{"admin"})
+		authURL := a.start(t)
+		code := a.provider.authorize(t, authURL, uniqueEmail("enforced"), []string{"bot-admins"}, nil)
+
+		status, body := a.callback(t, code, authURL.Query().Get("state"))
+		if status != fiber.StatusOK || body["mfa_enrollment_required"] != true || body["token"] != nil {
+			t.Fatalf("expected an enrollment token, got %d %v", status, body)
+		}
+	})
+}
+
+// TestOIDCStateCookie covers the binding of a login to the browser that
+// started it, which needs neither a provider nor a database.
+func TestOIDCStateCookie(t *testing.T) {
+	h := &OIDCHandler{}
+	state, stateHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Get("/api/v1/auth/oidc/login", func(c *fiber.Ctx) error {
+		h.bindState(c, stateHash)
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Post("/api/v1/auth/oidc/callback", func(c *fiber.Ctx) error {
+		if !h.stateBound(c, c.Query("state")) {
+			return c.SendStatus(fiber.StatusBadRequest)
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/login", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := resp.Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("login set %d cookies, want 1", len(cookies))
+	}
+	set := cookies[0]
+	if set.Name != oidcStateCookie || set.Value != stateHash || !set.HttpOnly ||
+		set.SameSite != http.SameSiteLaxMode || set.Path != "/api/v1/auth/oidc" ||
+		set.MaxAge != int(oidcStateTTL.Seconds()) {
+		t.Fatalf("unexpected state cookie %+v", set)
+	}
+
+	_, otherHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		cookie string
+		state  string
+		want   int
+	}{
+		{"matching cookie", stateHash, state, fiber.StatusOK},
+		{"missing cookie", "", state, fiber.StatusBadRequest},
+		{"cookie of another login", otherHash, state, fiber.StatusBadRequest},
+		{"state instead of its hash", state, state, fiber.StatusBadRequest},
+		{"empty state", stateHash, "", fiber.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/oidc/callback?state="+url.QueryEscape(tt.state), nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: tt.cookie})
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("got %d, want %d", resp.StatusCode, tt.want)
+			}
+
+			cleared := resp.Cookies()
+			if len(cleared) != 1 || cleared[0].Name != oidcStateCookie || cleared[0].Value != "" {
+				t.Fatalf("callback did not clear the state cookie: %+v", cleared)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type OrganizationHandler struct{}
+
+func NewOrganizationHandler() *OrganizationHandler {
+	return &OrganizationHandler{}
+}
+
+type CreateOrganizationRequest struct {
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	AdminUserID *uint  `json:"admin_user_id,omitempty"`
+}
+
+type OrganizationMemberRequest struct {
+	UserID uint   `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// GetOrganizations lists the organizations the caller belongs to, or every
+// organization for super-admins.
+func (h *OrganizationHandler) GetOrganizations(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	query := database.DB.Order("name ASC")
+	if superAdmin, _ := c.Locals("superAdmin").(bool); !superAdmin {
+		query = query.Where("id IN (?)", database.DB.Model(&models.OrganizationMember{}).
+			Select("organization_id").
+			Where("user_id = ?", userID))
+	}
+
+	var orgs []models.Organization
+	if err := query.Find(&orgs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch organizations",
+		})
+	}
+
+	return c.JSON(orgs)
+}
+
+func (h *OrganizationHandler) CreateOrganization(c *fiber.Ctx) error {
+	var req CreateOrganizationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Name == "" || req.Slug == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Name and slug are required",
+		})
+	}
+
+	var existing models.Organization
+	if err := database.DB.Where("slug = ?", req.Slug).First(&existing).Error; err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Organization slug already exists",
+		})
+	}
+
+	if req.AdminUserID != nil {
+		if err := database.DB.First(&models.User{}, *req.AdminUserID).Error; err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Admin user not found",
+			})
+		}
+	}
+
+	org := models.Organization{
+		Name: req.Name,
+		Slug: req.Slug,
+	}
+
+	c.Status(fiber.StatusCreated)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+
+		if req.AdminUserID != nil {
+			member := models.OrganizationMember{
+				OrganizationID: org.ID,
+				UserID:         *req.AdminUserID,
+				Role:           "admin",
+			}
+			if err := tx.Create(&member).Error; err != nil {
+				return err
+			}
+		}
+
+		return logAudit(c, tx, "organization.create", fiber.Map{
+			"organization_id":   org.ID,
+			"organization_slug": org.Slug,
+			"admin_user_id":     req.AdminUserID,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create organization",
+		})
+	}
+
+	return c.JSON(org)
+}
+
+func (h *OrganizationHandler) GetMembers(c *fiber.Ctx) error {
+	var members []models.OrganizationMember
+	if err := database.DB.Scopes(inOrganization(c)).
+		Preload("User").
+		Order("created_at ASC").
+		Find(&members).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch members",
+		})
+	}
+
+	return c.JSON(members)
+}
+
+// SetMember changes the role of a member of the current organization. A
+// user who is not a member yet can only be added with a pending invite
+// issued to their email address, which is used up; super-admins may add
+// any user.
+func (h *OrganizationHandler) SetMember(c *fiber.Ctx) error {
+	var req OrganizationMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.UserID == 0 || (req.Role != "admin" && req.Role != "viewer") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "user_id and a role of admin or viewer are required",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, req.UserID).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	member := models.OrganizationMember{
+		OrganizationID: currentOrgID(c),
+		UserID:         req.UserID,
+	}
+	if err := database.DB.Where(&member).FirstOrInit(&member).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save member",
+		})
+	}
+	member.Role = req.Role
+
+	var invite *models.Invite
+	if superAdmin, _ := c.Locals("superAdmin").(bool); member.ID == 0 && !superAdmin {
+		var found models.Invite
+		if err := database.DB.Scopes(inOrganization(c)).
+			Where("LOWER(email) = LOWER(?) AND used_at IS NULL AND expires_at > ?", user.Email, time.Now().UTC()).
+			Order("created_at ASC").
+			First(&found).Error; err != nil {
+			logAuditFailure(c, "organization.member.set", fiber.StatusForbidden, fiber.Map{
+				"user_id": req.UserID,
+				"reason":  "not_invited",
+			})
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "User is not a member of this organization and has no pending invite",
+			})
+		}
+		invite = &found
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		details := fiber.Map{
+			"user_id": req.UserID,
+			"role":    req.Role,
+		}
+		if invite != nil {
+			result := tx.Model(&models.Invite{}).
+				Where("id = ? AND used_at IS NULL", invite.ID).
+				Updates(map[string]interface{}{
+					"used_at":    time.Now().UTC(),
+					"used_by_id": user.ID,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errInviteUsed
+			}
+			details["invite_id"] = invite.ID
+		}
+
+		if err := tx.Save(&member).Error; err != nil {
+			return err
+		}
+		return logAudit(c, tx, "organization.member.set", details)
+	})
+	if errors.Is(err, errInviteUsed) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Invitation is invalid or has expired",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save member",
+		})
+	}
+
+	return c.JSON(member)
+}
+
+func (h *OrganizationHandler) RemoveMember(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("userId"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	// Access the user held in the organization goes with the membership,
+	// so that adding them back does not restore it.
+	orgID := currentOrgID(c)
+	orgGroups := database.DB.Unscoped().Model(&models.Group{}).Select("id").Where("organization_id = ?", orgID)
+	orgBots := database.DB.Unscoped().Model(&models.Bot{}).Select("id").Where("organization_id = ?", orgID)
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Scopes(inOrganization(c)).
+			Where("user_id = ?", userID).
+			Delete(&models.OrganizationMember{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		groups := tx.Exec("DELETE FROM group_members WHERE user_id = ? AND group_id IN (?)", userID, orgGroups)
+		if groups.Error != nil {
+			return groups.Error
+		}
+		acls := tx.Where("user_id = ? AND bot_id IN (?)", userID, orgBots).Delete(&models.BotACL{})
+		if acls.Error != nil {
+			return acls.Error
+		}
+		owned := tx.Unscoped().Model(&models.Bot{}).
+			Where("organization_id = ? AND owner_id = ?", orgID, userID).
+			Update("owner_id", nil)
+		if owned.Error != nil {
+			return owned.Error
+		}
+
+		return logAudit(c, tx, "organization.member.remove", fiber.Map{
+			"user_id":       userID,
+			"groups_left":   groups.RowsAffected,
+			"acl_entries":   acls.RowsAffected,
+			"bots_disowned": owned.RowsAffected,
+		})
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Member not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to remove member",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Member removed successfully",
+	})
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/FRFebi/bot-management-backend/internal/notifier"
+	"github.com/FRFebi/bot-management-backend/pkg/auth"
+	"github.com/FRFebi/bot-management-backend/pkg/logger"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PasswordHandler struct {
+	jwtManager  *auth.JWTManager
+	notifier    notifier.Notifier
+	publicURL   string
+	minLength   int
+	resetExpiry time.Duration
+	resetEvery  time.Duration
+	log         *logger.Logger
+}
+
+func NewPasswordHandler(cfg *config.Config, jwtManager *auth.JWTManager, n notifier.Notifier) *PasswordHandler {
+	return &PasswordHandler{
+		jwtManager:  jwtManager,
+		notifier:    n,
+		publicURL:   strings.TrimRight(cfg.Server.PublicURL, "/"),
+		minLength:   cfg.Password.MinLength,
+		resetExpiry: time.Duration(cfg.Password.ResetExpiryMinutes) * time.Minute,
+		resetEvery:  time.Duration(cfg.Password.ResetIntervalSeconds) * time.Second,
+		log:         logger.New(),
+	}
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+var (
+	errResetTokenInvalid = errors.New("reset token is invalid or has expired")
+	errResetThrottled    = errors.New("password reset requested too recently")
+)
+
+// ChangePassword replaces the caller's password. Every other session is
+// revoked; the response carries a fresh token for the current one.
+func (h *PasswordHandler) ChangePassword(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if user.AuthProvider != models.AuthProviderLocal {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Password is managed by your identity provider",
+		})
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Current password is incorrect",
+		})
+	}
+
+	if err := h.validatePassword(req.NewPassword); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := setPassword(tx, &user, req.NewPassword); err != nil {
+			return err
+		}
+		return logAudit(c, tx, "user.password.change", fiber.Map{
+			"target_user_id": user.ID,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to change password",
+		})
+	}
+
+	identity, err := resolveIdentity(&user, currentOrgID(c))
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	token, err := h.jwtManager.GenerateToken(identity)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Password changed successfully",
+		"token":   token,
+	})
+}
+
+// ForgotPassword sends a reset link to the address if it belongs to an
+// active account. The response is the same either way, and the account is
+// looked up and the link created and sent after responding, so neither the
+// response nor its timing reveals which emails are registered.
+func (h *PasswordHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req ForgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil || req.Email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Email is required",
+		})
+	}
+
+	entry := models.AuditLog{
+		Action:     "user.password.reset_requested",
+		StatusCode: fiber.StatusOK,
+	}
+	addRequestContext(c, &entry)
+
+	go h.sendReset(utils.CopyString(req.Email), entry)
+
+	return c.JSON(fiber.Map{
+		"message": "If the account exists, a password reset link has been sent",
+	})
+}
+
+// sendReset creates a reset token for the active local account with email,
+// if there is one, records entry for it and mails the link. An account is
+// sent at most one link per reset interval, so the endpoint cannot be used to
+// flood a mailbox.
+func (h *PasswordHandler) sendReset(email string, entry models.AuditLog) {
+	var user models.User
+	if err := database.DB.Where("email = ?", email).First(&user).Error; err != nil ||
+		!user.IsActive || user.AuthProvider != models.AuthProviderLocal {
+		return
+	}
+
+	token, tokenHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		h.log.Errorf("Failed to create reset token for user %d: %v", user.ID, err)
+		return
+	}
+
+	reset := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().UTC().Add(h.resetExpiry),
+	}
+	entry.OrganizationID = primaryOrgID(user.ID)
+	entry.UserID = &user.ID
+	entry.Details = auditDetails(fiber.Map{
+		"target_user_id": user.ID,
+	})
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Locking the user serializes concurrent requests for the account,
+		// so only one of them sees no recent token.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, user.ID).Error; err != nil {
+			return err
+		}
+		var recent int64
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND created_at > ?", user.ID, time.Now().UTC().Add(-h.resetEvery)).
+			Count(&recent).Error; err != nil {
+			return err
+		}
+		if recent > 0 {
+			return errResetThrottled
+		}
+		if err := tx.Create(&reset).Error; err != nil {
+			return err
+		}
+		return writeAuditEntry(tx, entry)
+	})
+	if errors.Is(err, errResetThrottled) {
+		return
+	}
+	if err != nil {
+		h.log.Errorf("Failed to create reset token for user %d: %v", user.ID, err)
+		return
+	}
+
+	msg := notifier.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nUse the link below to choose a new password. It expires in %d minutes and can only be used once.\n\n%s/reset-password?token=%s\n\nIf you did not request this, you can ignore this message.\n",
+			user.Name, int(h.resetExpiry.Minutes()), h.publicURL, token,
+		),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := h.notifier.Send(ctx, msg); err != nil {
+		h.log.Errorf("Failed to send password reset for user %d: %v", user.ID, err)
+	}
+}
+
+// ResetPassword consumes a reset token, sets the new password and revokes
+// every existing session of the account.
+func (h *PasswordHandler) ResetPassword(c *fiber.Ctx) error {
+	var req ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Token and new password are required",
+		})
+	}
+
+	if err := h.validatePassword(req.NewPassword); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var user models.User
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+
+		var reset models.PasswordResetToken
+		if err := tx.Where("token_hash = ?", auth.HashOpaqueToken(req.Token)).First(&reset).Error; err != nil {
+			return errResetTokenInvalid
+		}
+
+		if reset.UsedAt != nil || !now.Before(reset.ExpiresAt) {
+			return errResetTokenInvalid
+		}
+
+		if err := tx.First(&user, reset.UserID).Error; err != nil ||
+			!user.IsActive || user.AuthProvider != models.AuthProviderLocal {
+			return errResetTokenInvalid
+		}
+
+		// Consuming the token also voids any other outstanding reset links
+		// for the account.
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errResetTokenInvalid
+		}
+
+		if err := setPassword(tx, &user, req.NewPassword); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, primaryOrgID(user.ID), &user.ID, "user.password.reset", fiber.StatusOK, fiber.Map{
+			"target_user_id": user.ID,
+		})
+	})
+	if errors.Is(err, errResetTokenInvalid) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Reset token is invalid or has expired",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reset password",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Password reset successfully",
+	})
+}
+
+func (h *PasswordHandler) validatePassword(password string) error {
+	return validatePassword(password, h.minLength)
+}
+
+// validatePassword applies the password policy to a new password.
+func validatePassword(password string, minLength int) error {
+	if len(password) < minLength {
+		return fmt.Errorf("Password must be at least %d characters", minLength)
+	}
+	return nil
+}
+
+// setPassword stores a new password hash and bumps the token version, which
+// invalidates every token issued for the user so far.
+func setPassword(db *gorm.DB, user *models.User, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	user.PasswordHash = string(hashedPassword)
+	user.TokenVersion++
+	user.PasswordChangedAt = &now
+
+	return db.Model(user).Updates(map[string]interface{}{
+		"password_hash":       user.PasswordHash,
+		"token_version":       user.TokenVersion,
+		"password_changed_at": now,
+	}).Error
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// currentOrgID returns the organization the caller's token is scoped to.
+func currentOrgID(c *fiber.Ctx) uint {
+	orgID, _ := c.Locals("orgID").(uint)
+	return orgID
+}
+
+// inOrganization is a GORM scope restricting a query to rows owned by the
+// caller's current organization.
+func inOrganization(c *fiber.Ctx) func(*gorm.DB) *gorm.DB {
+	orgID := currentOrgID(c)
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("organization_id = ?", orgID)
+	}
+}
+
+// isOrgMember reports whether userID belongs to the caller's current
+// organization.
+func isOrgMember(c *fiber.Ctx, userID uint) bool {
+	var count int64
+	database.DB.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", currentOrgID(c), userID).
+		Count(&count)
+	return count > 0
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/artifacts"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/deploy"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// TrustedKeyHandler manages the ed25519 public keys an organization trusts
+// to sign bot artifacts. Bots marked verified-only deploy only artifacts
+// signed by one of the active keys.
+type TrustedKeyHandler struct{}
+
+func NewTrustedKeyHandler() *TrustedKeyHandler {
+	return &TrustedKeyHandler{}
+}
+
+type CreateTrustedKeyRequest struct {
+	Name string `json:"name"`
+	// PublicKey is a PEM "PUBLIC KEY" block or the base64 raw key.
+	PublicKey string `json:"public_key"`
+}
+
+func (h *TrustedKeyHandler) GetTrustedKeys(c *fiber.Ctx) error {
+	query := database.DB.Scopes(inOrganization(c)).Order("created_at DESC")
+	if c.Query("active") == "true" {
+		query = query.Where("revoked_at IS NULL")
+	}
+
+	var keys []models.TrustedKey
+	if err := query.Find(&keys).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch trusted keys",
+		})
+	}
+
+	return c.JSON(keys)
+}
+
+func (h *TrustedKeyHandler) CreateTrustedKey(c *fiber.Ctx) error {
+	var req CreateTrustedKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Key name is required",
+		})
+	}
+
+	publicKey, err := artifacts.ParsePublicKey(req.PublicKey)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	fingerprint := artifacts.Fingerprint(publicKey)
+
+	var existing models.TrustedKey
+	if err := database.DB.Scopes(inOrganization(c)).Where("fingerprint = ?", fingerprint).First(&existing).Error; err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Key is already registered",
+		})
+	}
+
+	userID := c.Locals("userID").(uint)
+	key := models.TrustedKey{
+		OrganizationID: currentOrgID(c),
+		Name:           req.Name,
+		PublicKey:      base64.StdEncoding.EncodeToString(publicKey),
+		Fingerprint:    fingerprint,
+		CreatedByID:    &userID,
+	}
+
+	c.Status(fiber.StatusCreated)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&key).Error; err != nil {
+			return err
+		}
+		return logAudit(c, tx, "trusted_key.create", fiber.Map{
+			"key_id":          key.ID,
+			"key_name":        key.Name,
+			"key_fingerprint": key.Fingerprint,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to register trusted key",
+		})
+	}
+
+	return c.JSON(key)
+}
+
+// RevokeTrustedKey stops a key from approving deployments. Artifacts it
+// signed can no longer be deployed to verified-only bots, including by
+// rollback.
+func (h *TrustedKeyHandler) RevokeTrustedKey(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid key ID",
+		})
+	}
+
+	var key models.TrustedKey
+	if err := database.DB.Scopes(inOrganization(c)).First(&key, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Trusted key not found",
+		})
+	}
+
+	if !key.Active() {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Key is already revoked",
+		})
+	}
+
+	userID := c.Locals("userID").(uint)
+	now := time.Now().UTC()
+	key.RevokedAt = &now
+	key.RevokedByID = &userID
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&key).Updates(map[string]interface{}{
+			"revoked_at":    now,
+			"revoked_by_id": userID,
+		}).Error; err != nil {
+			return err
+		}
+		return logAudit(c, tx, "trusted_key.revoke", fiber.Map{
+			"key_id":          key.ID,
+			"key_name":        key.Name,
+			"key_fingerprint": key.Fingerprint,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke trusted key",
+		})
+	}
+
+	return c.JSON(key)
+}
+
+// verifyArtifact checks that artifact may be deployed to bot and returns
+// the trusted key that signed it, if any. Verified-only bots refuse
+// artifacts without a valid signature; the refusal is audited under
+// action. When it returns false the error response has already been
+// written.
+func verifyArtifact(c *fiber.Ctx, bot *models.Bot, artifact *models.Artifact, action string) (*models.TrustedKey, bool) {
+	key, err := deploy.Signer(database.DB, bot, artifact)
+	if err == nil {
+		return key, true
+	}
+	if !errors.Is(err, deploy.ErrArtifactUnsigned) && !errors.Is(err, deploy.ErrSignatureUntrusted) {
+		c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to verify artifact signature",
+		})
+		return nil, false
+	}
+	if !bot.VerifiedOnly {
+		return nil, true
+	}
+
+	logAuditFailure(c, action, fiber.StatusUnprocessableEntity, fiber.Map{
+		"bot_id":   bot.ID,
+		"bot_name": bot.Name,
+		"artifact": artifact.Digest,
+		"reason":   err.Error(),
+	})
+	c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+		"error": "Bot is verified-only: " + err.Error(),
+	})
+	return nil, false
+}
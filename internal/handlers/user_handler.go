@@ -0,0 +1,460 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/loginguard"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type UserHandler struct {
+	guard     *loginguard.Guard
+	minLength int
+}
+
+func NewUserHandler(cfg *config.Config, guard *loginguard.Guard) *UserHandler {
+	return &UserHandler{guard: guard, minLength: cfg.Password.MinLength}
+}
+
+type CreateUserRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type UpdateUserRequest struct {
+	Name  *string `json:"name,omitempty"`
+	Email *string `json:"email,omitempty"`
+	Role  *string `json:"role,omitempty"`
+}
+
+// GetUsers lists the members of the caller's current organization.
+func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
+	query := database.DB.
+		Where("id IN (?)", database.DB.Model(&models.OrganizationMember{}).
+			Select("user_id").
+			Scopes(inOrganization(c))).
+		Preload("Memberships", "organization_id = ?", currentOrgID(c)).
+		Order("created_at ASC")
+
+	if active := c.Query("active"); active != "" {
+		query = query.Where("is_active = ?", active == "true")
+	}
+
+	var users []models.User
+	if err := query.Find(&users).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch users",
+		})
+	}
+
+	return c.JSON(users)
+}
+
+// CreateUser creates an account directly in the caller's current
+// organization. Unlike public registration it is not affected by the
+// registration mode and lets the admin choose the role.
+func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
+	var req CreateUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Name == "" || req.Email == "" || req.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Name, email, and password are required",
+		})
+	}
+
+	if err := validatePassword(req.Password, h.minLength); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if req.Role != "admin" && req.Role != "viewer" {
+		req.Role = "viewer"
+	}
+
+	// Deleted accounts keep their row, and with it the unique email.
+	var existingUser models.User
+	if err := database.DB.Unscoped().Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Email already exists",
+		})
+	}
+
+	user, err := newUser(req.Name, req.Email, req.Password, req.Role, currentOrgID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to hash password",
+		})
+	}
+
+	c.Status(fiber.StatusCreated)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return logAuditChange(c, tx, "user.create", fiber.Map{
+			"target_user_id": user.ID,
+			"email":          user.Email,
+			"role":           user.Role,
+		}, nil, user)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create user",
+		})
+	}
+
+	return c.JSON(user)
+}
+
+func (h *UserHandler) GetUser(c *fiber.Ctx) error {
+	user, ok := h.orgUser(c)
+	if !ok {
+		return nil
+	}
+
+	return c.JSON(user)
+}
+
+func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
+	user, ok := h.orgUser(c)
+	if !ok {
+		return nil
+	}
+
+	var req UpdateUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Role != nil && *req.Role != "admin" && *req.Role != "viewer" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Role must be admin or viewer",
+		})
+	}
+
+	// Name and email belong to the account rather than the organization, so
+	// only admins of every organization the user belongs to may change them.
+	if (req.Name != nil || req.Email != nil) && !h.ownsAccount(c, user) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "User belongs to other organizations",
+		})
+	}
+
+	if req.Email != nil && *req.Email != user.Email {
+		var existing models.User
+		if err := database.DB.Unscoped().Where("email = ?", *req.Email).First(&existing).Error; err == nil {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Email already exists",
+			})
+		}
+	}
+
+	before := *user
+	if req.Name != nil {
+		user.Name = *req.Name
+	}
+	if req.Email != nil {
+		user.Email = *req.Email
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if req.Name != nil || req.Email != nil {
+			if err := tx.Save(user).Error; err != nil {
+				return err
+			}
+		}
+
+		if req.Role != nil {
+			if err := tx.Model(&models.OrganizationMember{}).
+				Scopes(inOrganization(c)).
+				Where("user_id = ?", user.ID).
+				Update("role", *req.Role).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Preload("Memberships", "organization_id = ?", currentOrgID(c)).First(user, user.ID).Error; err != nil {
+			return err
+		}
+		return logAuditChange(c, tx, "user.update", fiber.Map{
+			"target_user_id": user.ID,
+		}, before, user)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update user",
+		})
+	}
+
+	return c.JSON(user)
+}
+
+func (h *UserHandler) DeactivateUser(c *fiber.Ctx) error {
+	return h.setActive(c, false)
+}
+
+func (h *UserHandler) ReactivateUser(c *fiber.Ctx) error {
+	return h.setActive(c, true)
+}
+
+// UnlockUser clears failed login attempts and any lockout of the user.
+func (h *UserHandler) UnlockUser(c *fiber.Ctx) error {
+	user, ok := h.orgUser(c)
+	if !ok {
+		return nil
+	}
+
+	status, _ := h.guard.Status(loginguard.AccountKey(user.ID))
+
+	details := fiber.Map{
+		"target_user_id": user.ID,
+		"email":          user.Email,
+	}
+	if status != nil {
+		details["failures"] = status.Failures
+		details["locked_until"] = status.LockedUntil
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := h.guard.Unlock(tx, loginguard.AccountKey(user.ID)); err != nil {
+			return err
+		}
+		return logAudit(c, tx, "user.unlock", details)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to unlock user",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "User unlocked successfully",
+	})
+}
+
+// GetLoginStatus reports recent failed logins and any active lockout.
+func (h *UserHandler) GetLoginStatus(c *fiber.Ctx) error {
+	user, ok := h.orgUser(c)
+	if !ok {
+		return nil
+	}
+
+	status, err := h.guard.Status(loginguard.AccountKey(user.ID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch login status",
+		})
+	}
+
+	if status == nil {
+		return c.JSON(fiber.Map{
+			"failures": 0,
+			"locked":   false,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"failures":        status.Failures,
+		"last_failure_at": status.LastFailureAt,
+		"locked":          status.LockedUntil != nil && time.Now().UTC().Before(*status.LockedUntil),
+		"locked_until":    status.LockedUntil,
+	})
+}
+
+// ResetMFA removes a user's second factor so they can enroll again, for
+// example after losing both their authenticator and recovery codes.
+func (h *UserHandler) ResetMFA(c *fiber.Ctx) error {
+	user, ok := h.orgUser(c)
+	if !ok {
+		return nil
+	}
+
+	if !h.canChangeAccount(c, user) {
+		return nil
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := disableMFA(tx, user.ID); err != nil {
+			return err
+		}
+		return logAudit(c, tx, "user.mfa.reset", fiber.Map{
+			"target_user_id": user.ID,
+			"email":          user.Email,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reset multi-factor authentication",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Multi-factor authentication reset successfully",
+	})
+}
+
+func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
+	user, ok := h.orgUser(c)
+	if !ok {
+		return nil
+	}
+
+	if !h.canChangeAccount(c, user) {
+		return nil
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.BotACL{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("group_members").Where("user_id = ?", user.ID).Delete(nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(user).Error; err != nil {
+			return err
+		}
+		return logAuditChange(c, tx, "user.delete", fiber.Map{
+			"target_user_id": user.ID,
+			"email":          user.Email,
+		}, user, nil)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete user",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "User deleted successfully",
+	})
+}
+
+func (h *UserHandler) setActive(c *fiber.Ctx, active bool) error {
+	user, ok := h.orgUser(c)
+	if !ok {
+		return nil
+	}
+
+	if !h.canChangeAccount(c, user) {
+		return nil
+	}
+
+	if user.IsActive == active {
+		return c.JSON(user)
+	}
+
+	before := *user
+	var deactivatedAt *time.Time
+	action := "user.reactivate"
+	if !active {
+		now := time.Now().UTC()
+		deactivatedAt = &now
+		action = "user.deactivate"
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"is_active":      active,
+			"deactivated_at": deactivatedAt,
+		}).Error; err != nil {
+			return err
+		}
+		user.IsActive = active
+		user.DeactivatedAt = deactivatedAt
+
+		return logAuditChange(c, tx, action, fiber.Map{
+			"target_user_id": user.ID,
+			"email":          user.Email,
+		}, before, user)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update user",
+		})
+	}
+
+	return c.JSON(user)
+}
+
+// orgUser loads the user named in the route, provided they belong to the
+// caller's current organization. When it returns false the error response
+// has already been written.
+func (h *UserHandler) orgUser(c *fiber.Ctx) (*models.User, bool) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+		return nil, false
+	}
+
+	var user models.User
+	if !isOrgMember(c, uint(id)) ||
+		database.DB.Preload("Memberships", "organization_id = ?", currentOrgID(c)).First(&user, id).Error != nil {
+		c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+		return nil, false
+	}
+
+	return &user, true
+}
+
+// canChangeAccount guards account-wide changes (deactivation, deletion).
+// Callers cannot change their own account this way, and org admins can
+// only change accounts that belong to no other organization. When it
+// returns false the error response has already been written.
+func (h *UserHandler) canChangeAccount(c *fiber.Ctx, user *models.User) bool {
+	if user.ID == c.Locals("userID").(uint) {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "You cannot change your own account",
+		})
+		return false
+	}
+
+	if !h.ownsAccount(c, user) {
+		c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "User belongs to other organizations",
+		})
+		return false
+	}
+
+	return true
+}
+
+// ownsAccount reports whether the caller administers every organization
+// user belongs to.
+func (h *UserHandler) ownsAccount(c *fiber.Ctx, user *models.User) bool {
+	if superAdmin, _ := c.Locals("superAdmin").(bool); superAdmin {
+		return true
+	}
+
+	if user.IsSuperAdmin {
+		return false
+	}
+
+	var otherOrgs int64
+	database.DB.Model(&models.OrganizationMember{}).
+		Where("user_id = ? AND organization_id <> ?", user.ID, currentOrgID(c)).
+		Count(&otherOrgs)
+
+	return otherOrgs == 0
+}
@@ -0,0 +1,227 @@
+// Package loginguard slows down and temporarily locks out repeated failed
+// logins, counted per account and per client IP.
+//
+// Accounts are identified by AccountKey, derived from the user ID rather
+// than the login the client typed, so every alias of an account (email in
+// any case, directory uid) shares one count, and the admin status and
+// unlock endpoints see the same row the login path updates.
+package loginguard
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Decision is the outcome of checking whether a login may be attempted.
+type Decision struct {
+	Allowed    bool
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+// Failure reports the state after recording a failed attempt.
+type Failure struct {
+	AccountFailures int
+	// Locked is true when this failure triggered a lockout of the account
+	// or of the IP.
+	Locked bool
+}
+
+type Guard struct {
+	cfg config.LoginProtectionConfig
+}
+
+func New(cfg config.LoginProtectionConfig) *Guard {
+	return &Guard{cfg: cfg}
+}
+
+// AccountKey is the throttle key of the account of userID.
+func AccountKey(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// Check reports whether a login for account from ip may be attempted now.
+// An empty account, for a login that matches no known user, only checks
+// the IP.
+func (g *Guard) Check(account, ip string) (Decision, error) {
+	now := time.Now().UTC()
+	decision := Decision{Allowed: true}
+
+	var rows []models.LoginThrottle
+	if err := database.DB.
+		Where("(scope = ? AND key = ?) OR (scope = ? AND key = ?)",
+			models.ThrottleScopeAccount, normalize(account),
+			models.ThrottleScopeIP, ip).
+		Find(&rows).Error; err != nil {
+		return decision, err
+	}
+
+	for _, row := range rows {
+		if g.expired(row, now) {
+			continue
+		}
+
+		if row.LockedUntil != nil && now.Before(*row.LockedUntil) {
+			decision.Allowed = false
+			decision.Locked = true
+			decision.RetryAfter = max(decision.RetryAfter, row.LockedUntil.Sub(now))
+		} else if row.NextAttemptAt != nil && now.Before(*row.NextAttemptAt) {
+			decision.Allowed = false
+			decision.RetryAfter = max(decision.RetryAfter, row.NextAttemptAt.Sub(now))
+		}
+	}
+
+	return decision, nil
+}
+
+// RecordFailure counts a failed attempt against both the account and ip. An
+// empty account only counts against the IP.
+func (g *Guard) RecordFailure(account, ip string) (Failure, error) {
+	var failure Failure
+
+	if account != "" {
+		accountRow, locked, err := g.increment(models.ThrottleScopeAccount, normalize(account), g.cfg.AccountMaxFailures)
+		if err != nil {
+			return failure, err
+		}
+		failure.AccountFailures = accountRow.Failures
+		failure.Locked = locked
+	}
+
+	if ip != "" {
+		_, locked, err := g.increment(models.ThrottleScopeIP, ip, g.cfg.IPMaxFailures)
+		if err != nil {
+			return failure, err
+		}
+		failure.Locked = failure.Locked || locked
+	}
+
+	return failure, nil
+}
+
+// RecordSuccess clears the failure count of an account once it has fully
+// logged in. IP counts are only forgotten as they expire, so a successful
+// login cannot reset the throttling of attempts on other accounts from the
+// same address.
+func (g *Guard) RecordSuccess(account string) error {
+	return database.DB.
+		Where("scope = ? AND key = ?", models.ThrottleScopeAccount, normalize(account)).
+		Delete(&models.LoginThrottle{}).Error
+}
+
+// Unlock lifts a lockout and the failure count of an account. It runs in
+// db so the caller can commit it together with its audit entry.
+func (g *Guard) Unlock(db *gorm.DB, account string) error {
+	return db.
+		Where("scope = ? AND key = ?", models.ThrottleScopeAccount, normalize(account)).
+		Delete(&models.LoginThrottle{}).Error
+}
+
+// Status returns the current throttle state of an account, or nil if it
+// has no recent failures.
+func (g *Guard) Status(account string) (*models.LoginThrottle, error) {
+	var row models.LoginThrottle
+	err := database.DB.
+		Where("scope = ? AND key = ?", models.ThrottleScopeAccount, normalize(account)).
+		First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && g.expired(row, time.Now().UTC())) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+func (g *Guard) increment(scope, key string, maxFailures int) (models.LoginThrottle, bool, error) {
+	var row models.LoginThrottle
+	locked := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("scope = ? AND key = ?", scope, key).
+			First(&row).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			row = models.LoginThrottle{Scope: scope, Key: key}
+		} else if err != nil {
+			return err
+		}
+
+		if g.expired(row, now) {
+			row.Failures = 0
+			row.NextAttemptAt = nil
+			row.LockedUntil = nil
+		}
+
+		row.Failures++
+		row.LastFailureAt = now
+
+		if delay := g.delay(row.Failures); delay > 0 {
+			next := now.Add(delay)
+			row.NextAttemptAt = &next
+		}
+
+		if row.Failures >= maxFailures && (row.LockedUntil == nil || !now.Before(*row.LockedUntil)) {
+			until := now.Add(time.Duration(g.cfg.LockoutMinutes) * time.Minute)
+			row.LockedUntil = &until
+			locked = true
+		}
+
+		if row.ID != 0 {
+			return tx.Save(&row).Error
+		}
+
+		// Upsert so two replicas racing on a brand new key do not fail on
+		// the unique index.
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "scope"}, {Name: "key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"failures", "last_failure_at", "next_attempt_at", "locked_until", "updated_at"}),
+		}).Create(&row).Error
+	})
+
+	return row, locked, err
+}
+
+// delay is the minimum wait before the next attempt after the given number
+// of consecutive failures: nothing for the first few, then doubling up to
+// the configured maximum.
+func (g *Guard) delay(failures int) time.Duration {
+	over := failures - g.cfg.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+
+	base := time.Duration(g.cfg.BaseDelaySeconds) * time.Second
+	maxDelay := time.Duration(g.cfg.MaxDelaySeconds) * time.Second
+
+	delay := time.Duration(float64(base) * math.Pow(2, float64(over-1)))
+	if delay > maxDelay || delay <= 0 {
+		return maxDelay
+	}
+	return delay
+}
+
+// expired reports whether a row's failures are old enough to be forgotten.
+// Rows under an active lockout never expire.
+func (g *Guard) expired(row models.LoginThrottle, now time.Time) bool {
+	if row.LockedUntil != nil && now.Before(*row.LockedUntil) {
+		return false
+	}
+	window := time.Duration(g.cfg.WindowMinutes) * time.Minute
+	return now.Sub(row.LastFailureAt) > window
+}
+
+func normalize(account string) string {
+	return strings.ToLower(strings.TrimSpace(account))
+}
@@ -1,16 +1,21 @@
 package middleware
 
 import (
+	"slices"
 	"strings"
 
-	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
 	"github.com/FRFebi/bot-management-backend/pkg/auth"
 	"github.com/gofiber/fiber/v2"
 )
 
-func AuthMiddleware(cfg *config.Config) fiber.Handler {
-	jwtManager := auth.NewJWTManager(cfg.JWT.SecretKey, cfg.JWT.ExpiryHours)
-
+// AuthMiddleware accepts access tokens, plus tokens carrying any of the
+// listed purposes. The purpose of the token is exposed as the "purpose"
+// local so handlers can tell them apart. The role and super-admin status
+// are read from the database rather than the token, so demotions and
+// removals from the organization take effect immediately.
+func AuthMiddleware(jwtManager *auth.JWTManager, allowedPurposes ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -27,15 +32,44 @@ func AuthMiddleware(cfg *config.Config) fiber.Handler {
 		}
 
 		claims, err := jwtManager.ValidateToken(token)
-		if err != nil {
+		if err != nil || (claims.Purpose != "" && !slices.Contains(allowedPurposes, claims.Purpose)) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid or expired token",
+			})
+		}
+
+		var user models.User
+		if err := database.DB.Select("id", "is_active", "token_version", "is_super_admin").First(&user, claims.UserID).Error; err != nil || !user.IsActive {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Account is deactivated or no longer exists",
+			})
+		}
+
+		if claims.TokenVersion != user.TokenVersion {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "Invalid or expired token",
 			})
 		}
 
+		role := "admin"
+		if !user.IsSuperAdmin {
+			var membership models.OrganizationMember
+			if err := database.DB.Select("role").
+				Where("organization_id = ? AND user_id = ?", claims.OrganizationID, user.ID).
+				First(&membership).Error; err != nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": "No longer a member of this organization",
+				})
+			}
+			role = membership.Role
+		}
+
 		c.Locals("userID", claims.UserID)
 		c.Locals("email", claims.Email)
-		c.Locals("role", claims.Role)
+		c.Locals("role", role)
+		c.Locals("orgID", claims.OrganizationID)
+		c.Locals("superAdmin", user.IsSuperAdmin)
+		c.Locals("purpose", claims.Purpose)
 
 		return c.Next()
 	}
@@ -60,4 +94,18 @@ func RequireRole(allowedRoles ...string) fiber.Handler {
 			"error": "Insufficient permissions",
 		})
 	}
-}
\ No newline at end of file
+}
+
+// RequireSuperAdmin allows only global super-admins, regardless of their
+// role in the current organization.
+func RequireSuperAdmin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if superAdmin, _ := c.Locals("superAdmin").(bool); superAdmin {
+			return c.Next()
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Insufficient permissions",
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BodyLimit answers 413 for request bodies larger than limit. The server
+// streams bodies above its own limit instead of buffering them, and reads
+// chunked bodies lazily, so this must run before anything reads the body.
+// Requests for which skip returns true are left to their route, which
+// must check the size itself before reading.
+func BodyLimit(limit int, skip func(c *fiber.Ctx) bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if skip != nil && skip(c) {
+			return c.Next()
+		}
+
+		req := c.Request()
+		length := req.Header.ContentLength()
+		if length > limit {
+			return bodyTooLarge(c)
+		}
+
+		// Chunked bodies have no declared length, so read at most limit.
+		if length == -1 && req.IsBodyStream() {
+			body, err := io.ReadAll(io.LimitReader(req.BodyStream(), int64(limit)+1))
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Failed to read request body",
+				})
+			}
+			if len(body) > limit {
+				return bodyTooLarge(c)
+			}
+			req.SetBody(body)
+		}
+
+		return c.Next()
+	}
+}
+
+// bodyTooLarge refuses the request and closes the connection, since the
+// rest of the body has not been read.
+func bodyTooLarge(c *fiber.Ctx) error {
+	c.Context().SetConnectionClose()
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+		"error": "Request body too large",
+	})
+}
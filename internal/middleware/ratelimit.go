@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/FRFebi/bot-management-backend/internal/ratelimit"
+	"github.com/FRFebi/bot-management-backend/pkg/logger"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RateLimit counts requests against policy, per user when the request is
+// authenticated and per client IP otherwise, so it must run after
+// AuthMiddleware on protected routes. It sets the RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers and
+// answers 429 once the budget is spent. A request is counted once per
+// policy even when nested route groups apply the same policy twice. If
+// the store is unreachable, requests are let through; a nil store
+// disables limiting.
+func RateLimit(store ratelimit.Store, policy ratelimit.Policy) fiber.Handler {
+	if store == nil {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	log := logger.New()
+	applied := "rateLimit:" + policy.Name
+
+	return func(c *fiber.Ctx) error {
+		if c.Locals(applied) != nil {
+			return c.Next()
+		}
+		c.Locals(applied, true)
+
+		client := "ip:" + c.IP()
+		if userID, ok := c.Locals("userID").(uint); ok {
+			client = fmt.Sprintf("user:%d", userID)
+		}
+
+		result, err := ratelimit.Take(c.UserContext(), store, policy, client)
+		if err != nil {
+			log.Errorf("Rate limit store error: %v", err)
+			return c.Next()
+		}
+
+		reset := int(math.Ceil(result.ResetIn.Seconds()))
+		c.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(reset))
+		c.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, int(policy.Window.Seconds())))
+
+		if !result.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(reset))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error":       "Rate limit exceeded",
+				"retry_after": reset,
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// RateLimitByMethod applies the read policy to GET and HEAD requests and
+// the write policy to everything else.
+func RateLimitByMethod(store ratelimit.Store, read, write ratelimit.Policy) fiber.Handler {
+	readLimit := RateLimit(store, read)
+	writeLimit := RateLimit(store, write)
+
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead:
+			return readLimit(c)
+		default:
+			return writeLimit(c)
+		}
+	}
+}
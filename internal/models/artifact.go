@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// Artifact is a file uploaded for a bot to run: a script, a binary or a
+// tarball. The content lives in the artifact store under Digest, the
+// SHA-256 of the content, and is shared by every upload of the same bytes.
+// Signature is an optional detached ed25519 signature of Digest, checked
+// against the organization's trusted keys when the artifact is deployed.
+type Artifact struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	OrganizationID uint      `gorm:"not null;index" json:"organization_id"`
+	BotID          uint      `gorm:"not null;uniqueIndex:idx_artifacts_bot_digest" json:"bot_id"`
+	Digest         string    `gorm:"type:varchar(71);not null;uniqueIndex:idx_artifacts_bot_digest;index" json:"digest"`
+	Size           int64     `gorm:"not null" json:"size"`
+	Filename       string    `gorm:"type:varchar(255)" json:"filename"`
+	Kind           string    `gorm:"type:varchar(20);not null;check:kind IN ('script', 'binary', 'tarball')" json:"kind"`
+	Signature      string    `gorm:"type:text" json:"signature,omitempty"`
+	UploadedByID   *uint     `gorm:"index" json:"uploaded_by_id"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	Bot        *Bot  `gorm:"foreignKey:BotID;constraint:OnDelete:CASCADE" json:"-"`
+	UploadedBy *User `gorm:"foreignKey:UploadedByID;constraint:OnDelete:SET NULL" json:"uploaded_by,omitempty"`
+}
+
+func (Artifact) TableName() string {
+	return "artifacts"
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditArchive records a contiguous range of the audit chain moved out of
+// audit_logs into a compressed NDJSON file. File and Manifest are relative
+// to the archive directory; SHA256 is the checksum of File. Entries under
+// a legal hold stay online as well, and RestoredAt is set once the whole
+// range has been loaded back.
+type AuditArchive struct {
+	ID            uint       `gorm:"primarykey" json:"id"`
+	FirstSequence int64      `gorm:"uniqueIndex;not null" json:"first_sequence"`
+	LastSequence  int64      `gorm:"uniqueIndex;not null" json:"last_sequence"`
+	FirstPrevHash string     `gorm:"type:varchar(64)" json:"first_prev_hash"`
+	LastHash      string     `gorm:"type:varchar(64);not null" json:"last_hash"`
+	EntryCount    int64      `gorm:"not null" json:"entry_count"`
+	OldestAt      time.Time  `json:"oldest_at"`
+	NewestAt      time.Time  `json:"newest_at"`
+	File          string     `gorm:"type:varchar(255);not null" json:"file"`
+	Manifest      string     `gorm:"type:varchar(255);not null" json:"manifest"`
+	SHA256        string     `gorm:"column:sha256;type:varchar(64);not null" json:"sha256"`
+	Size          int64      `gorm:"not null" json:"size"`
+	RestoredAt    *time.Time `json:"restored_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (AuditArchive) TableName() string {
+	return "audit_archives"
+}
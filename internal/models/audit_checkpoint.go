@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// AuditCheckpoint commits to the audit chain up to Sequence. Signature is
+// a compact JWS over the sequence and hash, signed with the token signing
+// key KeyID. PublicKey is that key's JWK for auditors to archive; the
+// server itself only verifies checkpoints with keys it trusts.
+type AuditCheckpoint struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	Sequence  int64          `gorm:"uniqueIndex;not null" json:"sequence"`
+	Hash      string         `gorm:"type:varchar(64);not null" json:"hash"`
+	KeyID     string         `gorm:"type:varchar(64);not null" json:"kid"`
+	PublicKey datatypes.JSON `gorm:"type:jsonb" json:"jwk"`
+	Signature string         `gorm:"type:text;not null" json:"signature"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+func (AuditCheckpoint) TableName() string {
+	return "audit_checkpoints"
+}
@@ -1,21 +1,119 @@
 package models
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"time"
 
 	"gorm.io/datatypes"
 )
 
+// Audit outcomes, derived from the HTTP status of the audited request.
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeDenied  = "denied"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditLog records an action together with the request it came from and,
+// for changes to a resource, the fields that changed. Entries form a hash
+// chain: Hash covers the entry's content and
+// the Hash of the entry before it (PrevHash), and Sequence numbers entries
+// without gaps. Editing, deleting or reordering rows breaks the chain.
 type AuditLog struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	UserID    *uint          `gorm:"index" json:"user_id"`
-	Action    string         `gorm:"type:varchar(255);not null" json:"action"`
-	Details   datatypes.JSON `gorm:"type:jsonb" json:"details"`
-	CreatedAt time.Time      `json:"created_at"`
+	ID             uint           `gorm:"primarykey" json:"id"`
+	OrganizationID uint           `gorm:"index" json:"organization_id"`
+	UserID         *uint          `gorm:"index" json:"user_id"`
+	Action         string         `gorm:"type:varchar(255);not null" json:"action"`
+	Details        datatypes.JSON `gorm:"type:jsonb" json:"details"`
+	Changes        datatypes.JSON `gorm:"type:jsonb" json:"changes,omitempty"`
+	Outcome        string         `gorm:"type:varchar(10);index" json:"outcome,omitempty"`
+	StatusCode     int            `json:"status_code,omitempty"`
+	IPAddress      string         `gorm:"type:varchar(45)" json:"ip_address,omitempty"`
+	UserAgent      string         `gorm:"type:varchar(512)" json:"user_agent,omitempty"`
+	RequestID      string         `gorm:"type:varchar(64);index" json:"request_id,omitempty"`
+	CreatedAt      time.Time      `gorm:"index" json:"created_at"`
+	Sequence       int64          `gorm:"uniqueIndex" json:"sequence"`
+	PrevHash       string         `gorm:"type:varchar(64)" json:"prev_hash"`
+	Hash           string         `gorm:"type:varchar(64)" json:"hash"`
 
-	User *User `gorm:"foreignKey:UserID;constraint:OnDelete:SET NULL" json:"user,omitempty"`
+	User *User `gorm:"foreignKey:UserID;constraint:OnDelete:RESTRICT" json:"user,omitempty"`
 }
 
 func (AuditLog) TableName() string {
 	return "audit_logs"
-}
\ No newline at end of file
+}
+
+// ComputeHash returns the SHA-256 of the entry's chained fields. JSON
+// columns are hashed in a canonical form so the result survives the jsonb
+// round trip, and CreatedAt at the microsecond precision Postgres stores.
+// Fields added after the chain was introduced are omitted when empty, so
+// older entries keep their hash.
+func (a *AuditLog) ComputeHash() string {
+	payload, _ := json.Marshal(struct {
+		Sequence       int64           `json:"seq"`
+		OrganizationID uint            `json:"org"`
+		UserID         *uint           `json:"user"`
+		Action         string          `json:"action"`
+		Details        json.RawMessage `json:"details"`
+		Changes        json.RawMessage `json:"changes,omitempty"`
+		Outcome        string          `json:"outcome,omitempty"`
+		StatusCode     int             `json:"status,omitempty"`
+		IPAddress      string          `json:"ip,omitempty"`
+		UserAgent      string          `json:"ua,omitempty"`
+		RequestID      string          `json:"request_id,omitempty"`
+		CreatedAt      string          `json:"created_at"`
+		PrevHash       string          `json:"prev"`
+	}{
+		Sequence:       a.Sequence,
+		OrganizationID: a.OrganizationID,
+		UserID:         a.UserID,
+		Action:         a.Action,
+		Details:        canonicalJSON(a.Details),
+		Changes:        optionalJSON(a.Changes),
+		Outcome:        a.Outcome,
+		StatusCode:     a.StatusCode,
+		IPAddress:      a.IPAddress,
+		UserAgent:      a.UserAgent,
+		RequestID:      a.RequestID,
+		CreatedAt:      a.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		PrevHash:       a.PrevHash,
+	})
+
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalJSON re-encodes data with sorted object keys and no
+// insignificant whitespace, keeping numbers as written.
+func canonicalJSON(data []byte) json.RawMessage {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return json.RawMessage("null")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return json.RawMessage("null")
+	}
+
+	out, err := json.Marshal(value)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return out
+}
+
+func optionalJSON(data []byte) json.RawMessage {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if canonical := canonicalJSON(data); string(canonical) != "null" {
+		return canonical
+	}
+	return nil
+}
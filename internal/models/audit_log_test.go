@@ -0,0 +1,108 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+func TestAuditLogComputeHash(t *testing.T) {
+	userID := uint(7)
+	otherUserID := uint(8)
+	createdAt := time.Date(2026, 3, 1, 12, 0, 0, 123456000, time.UTC)
+
+	base := func() AuditLog {
+		return AuditLog{
+			OrganizationID: 1,
+			UserID:         &userID,
+			Action:         "bot.update",
+			Details:        datatypes.JSON(`{"bot_id":3,"name":"alpha"}`),
+			Changes:        datatypes.JSON(`[{"field":"name","before":"a","after":"alpha"}]`),
+			Outcome:        AuditOutcomeSuccess,
+			StatusCode:     200,
+			IPAddress:      "203.0.113.5",
+			UserAgent:      "curl/8.0",
+			RequestID:      "req-1",
+			CreatedAt:      createdAt,
+			Sequence:       42,
+			PrevHash:       "abc",
+		}
+	}
+	want := func() string {
+		entry := base()
+		return entry.ComputeHash()
+	}()
+
+	tests := []struct {
+		name   string
+		modify func(*AuditLog)
+		same   bool
+	}{
+		{"unchanged", func(a *AuditLog) {}, true},
+		{"details key order and spacing", func(a *AuditLog) { a.Details = datatypes.JSON(`{ "name": "alpha", "bot_id": 3 }`) }, true},
+		{"created at in another zone", func(a *AuditLog) { a.CreatedAt = createdAt.In(time.FixedZone("UTC+2", 2*3600)) }, true},
+		{"created at below a microsecond", func(a *AuditLog) { a.CreatedAt = createdAt.Add(999 * time.Nanosecond) }, true},
+		{"ID and stored hash", func(a *AuditLog) { a.ID = 9; a.Hash = "ignored" }, true},
+		{"sequence", func(a *AuditLog) { a.Sequence = 43 }, false},
+		{"previous hash", func(a *AuditLog) { a.PrevHash = "abd" }, false},
+		{"organization", func(a *AuditLog) { a.OrganizationID = 2 }, false},
+		{"user", func(a *AuditLog) { a.UserID = &otherUserID }, false},
+		{"no user", func(a *AuditLog) { a.UserID = nil }, false},
+		{"action", func(a *AuditLog) { a.Action = "bot.delete" }, false},
+		{"details", func(a *AuditLog) { a.Details = datatypes.JSON(`{"bot_id":4,"name":"alpha"}`) }, false},
+		{"details number as written", func(a *AuditLog) { a.Details = datatypes.JSON(`{"bot_id":3.0,"name":"alpha"}`) }, false},
+		{"changes", func(a *AuditLog) { a.Changes = nil }, false},
+		{"outcome", func(a *AuditLog) { a.Outcome = AuditOutcomeFailure }, false},
+		{"status code", func(a *AuditLog) { a.StatusCode = 500 }, false},
+		{"IP address", func(a *AuditLog) { a.IPAddress = "203.0.113.6" }, false},
+		{"user agent", func(a *AuditLog) { a.UserAgent = "curl/8.1" }, false},
+		{"request ID", func(a *AuditLog) { a.RequestID = "req-2" }, false},
+		{"created at", func(a *AuditLog) { a.CreatedAt = createdAt.Add(time.Microsecond) }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := base()
+			tt.modify(&entry)
+			got := entry.ComputeHash()
+			if len(got) != 64 {
+				t.Fatalf("expected a hex SHA-256, got %q", got)
+			}
+			if tt.same && got != want {
+				t.Errorf("expected the hash to stay %s, got %s", want, got)
+			}
+			if !tt.same && got == want {
+				t.Error("expected the hash to change")
+			}
+		})
+	}
+}
+
+// Fields added after the chain was introduced must not change the hash of
+// entries that leave them empty.
+func TestAuditLogComputeHashOptionalFields(t *testing.T) {
+	createdAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	legacy := AuditLog{OrganizationID: 1, Action: "login", Details: datatypes.JSON(`{}`), CreatedAt: createdAt, Sequence: 1}
+	want := legacy.ComputeHash()
+
+	tests := []struct {
+		name    string
+		changes datatypes.JSON
+	}{
+		{"nil changes", nil},
+		{"empty changes", datatypes.JSON(``)},
+		{"blank changes", datatypes.JSON(`  `)},
+		{"null changes", datatypes.JSON(`null`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := legacy
+			entry.Changes = tt.changes
+			if got := entry.ComputeHash(); got != want {
+				t.Errorf("expected %s, got %s", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// AuditOutbox holds audit entries that could not be appended to the chain
+// when their action committed. They are retried until they succeed and are
+// never dropped; Entry is the serialized AuditLog.
+type AuditOutbox struct {
+	ID            uint           `gorm:"primarykey" json:"id"`
+	Entry         datatypes.JSON `gorm:"type:jsonb;not null" json:"entry"`
+	Attempts      int            `gorm:"not null;default:0" json:"attempts"`
+	LastError     string         `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptAt time.Time      `gorm:"index" json:"next_attempt_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+func (AuditOutbox) TableName() string {
+	return "audit_outbox"
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditSinkCursor tracks delivery of the audit chain to an external sink:
+// every entry up to LastSequence has been delivered. A failed delivery
+// leaves the cursor in place, records the error and schedules the next
+// attempt, so each entry reaches the sink at least once.
+type AuditSinkCursor struct {
+	Sink          string     `gorm:"primaryKey;type:varchar(50)" json:"sink"`
+	LastSequence  int64      `gorm:"not null;default:0" json:"last_sequence"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastError     string     `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (AuditSinkCursor) TableName() string {
+	return "audit_sink_cursors"
+}
@@ -8,20 +8,29 @@ import (
 )
 
 type Bot struct {
-	ID          uint           `gorm:"primarykey" json:"id"`
-	Name        string         `gorm:"type:varchar(100);not null" json:"name"`
-	Description string         `gorm:"type:text" json:"description"`
-	Version     string         `gorm:"type:varchar(50)" json:"version"`
-	Config      datatypes.JSON `gorm:"type:jsonb" json:"config"`
-	Status      string         `gorm:"type:varchar(20);default:'stopped'" json:"status"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uint           `gorm:"primarykey" json:"id"`
+	OrganizationID uint           `gorm:"index" json:"organization_id"`
+	Name           string         `gorm:"type:varchar(100);not null" json:"name"`
+	Description    string         `gorm:"type:text" json:"description"`
+	Version        string         `gorm:"type:varchar(50)" json:"version"`
+	ArtifactDigest string         `gorm:"type:varchar(71)" json:"artifact_digest,omitempty"`
+	Config         datatypes.JSON `gorm:"type:jsonb" json:"config"`
+	ConfigRevision int            `gorm:"not null;default:0" json:"config_revision"`
+	VerifiedOnly   bool           `gorm:"not null;default:false" json:"verified_only"`
+	HealthCheckURL string         `gorm:"type:varchar(2048)" json:"health_check_url,omitempty"`
+	Replicas       int            `gorm:"not null;default:1" json:"replicas"`
+	Status         string         `gorm:"type:varchar(20);default:'stopped'" json:"status"`
+	OwnerID        *uint          `gorm:"index" json:"owner_id"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 
+	Owner     *User      `gorm:"foreignKey:OwnerID;constraint:OnDelete:SET NULL" json:"owner,omitempty"`
 	Schedules []Schedule `gorm:"foreignKey:BotID" json:"schedules,omitempty"`
 	Runs      []Run      `gorm:"foreignKey:BotID" json:"runs,omitempty"`
+	ACL       []BotACL   `gorm:"foreignKey:BotID" json:"acl,omitempty"`
 }
 
 func (Bot) TableName() string {
 	return "bots"
-}
\ No newline at end of file
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+// BotPermission is a level of access to a single bot. Each level includes
+// every level below it: manage > deploy > operate > read.
+type BotPermission string
+
+const (
+	BotPermissionRead    BotPermission = "read"
+	BotPermissionOperate BotPermission = "operate"
+	BotPermissionDeploy  BotPermission = "deploy"
+	BotPermissionManage  BotPermission = "manage"
+)
+
+var botPermissionRank = map[BotPermission]int{
+	BotPermissionRead:    1,
+	BotPermissionOperate: 2,
+	BotPermissionDeploy:  3,
+	BotPermissionManage:  4,
+}
+
+func (p BotPermission) Valid() bool {
+	_, ok := botPermissionRank[p]
+	return ok
+}
+
+// Includes reports whether holding p is enough to perform an action that
+// requires other.
+func (p BotPermission) Includes(other BotPermission) bool {
+	return botPermissionRank[p] >= botPermissionRank[other]
+}
+
+// BotACL grants a user or a group a permission on a bot. Exactly one of
+// UserID and GroupID is set.
+type BotACL struct {
+	ID         uint          `gorm:"primarykey" json:"id"`
+	BotID      uint          `gorm:"not null;index" json:"bot_id"`
+	UserID     *uint         `gorm:"index" json:"user_id,omitempty"`
+	GroupID    *uint         `gorm:"index" json:"group_id,omitempty"`
+	Permission BotPermission `gorm:"type:varchar(20);not null;check:permission IN ('read', 'operate', 'deploy', 'manage')" json:"permission"`
+	CreatedAt  time.Time     `json:"created_at"`
+
+	Bot   *Bot   `gorm:"foreignKey:BotID;constraint:OnDelete:CASCADE" json:"-"`
+	User  *User  `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"user,omitempty"`
+	Group *Group `gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE" json:"group,omitempty"`
+}
+
+func (BotACL) TableName() string {
+	return "bot_acls"
+}
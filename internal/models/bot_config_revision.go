@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// BotConfigRevision is an immutable snapshot of a bot's configuration.
+// Revisions are numbered from 1 per bot; RolledBackFrom is set when the
+// revision restored the config of an earlier one.
+type BotConfigRevision struct {
+	ID             uint           `gorm:"primarykey" json:"id"`
+	BotID          uint           `gorm:"not null;uniqueIndex:idx_bot_config_revision" json:"bot_id"`
+	Revision       int            `gorm:"not null;uniqueIndex:idx_bot_config_revision" json:"revision"`
+	Config         datatypes.JSON `gorm:"type:jsonb" json:"config"`
+	Message        string         `gorm:"type:text" json:"message"`
+	AuthorID       *uint          `gorm:"index" json:"author_id"`
+	RolledBackFrom *int           `json:"rolled_back_from,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+
+	Author *User `gorm:"foreignKey:AuthorID;constraint:OnDelete:SET NULL" json:"author,omitempty"`
+}
+
+func (BotConfigRevision) TableName() string {
+	return "bot_config_revisions"
+}
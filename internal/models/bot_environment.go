@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Deployment environments, in promotion order.
+const (
+	EnvironmentDevelopment = "development"
+	EnvironmentStaging     = "staging"
+	EnvironmentProduction  = "production"
+)
+
+// Environments lists the deployment environments in promotion order.
+var Environments = []string{EnvironmentDevelopment, EnvironmentStaging, EnvironmentProduction}
+
+// BotEnvironment is the release a bot runs in one environment and the
+// config overlay, a JSON merge patch (RFC 7396), applied on top of the
+// bot's config there. The production release is the bot's own version,
+// artifact and config, which this row mirrors.
+type BotEnvironment struct {
+	ID             uint           `gorm:"primarykey" json:"-"`
+	BotID          uint           `gorm:"not null;uniqueIndex:idx_bot_environment" json:"bot_id"`
+	Name           string         `gorm:"type:varchar(20);not null;uniqueIndex:idx_bot_environment" json:"name"`
+	ConfigOverlay  datatypes.JSON `gorm:"type:jsonb" json:"config_overlay"`
+	Version        string         `gorm:"type:varchar(50)" json:"version"`
+	ArtifactDigest string         `gorm:"type:varchar(71)" json:"artifact_digest,omitempty"`
+	ConfigRevision int            `gorm:"not null;default:0" json:"config_revision"`
+	DeploymentID   *uint          `json:"deployment_id"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+
+	Bot *Bot `gorm:"foreignKey:BotID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (BotEnvironment) TableName() string {
+	return "bot_environments"
+}
+
+// NextEnvironment returns the environment releases in env are promoted
+// to, or "" for production and unknown names.
+func NextEnvironment(env string) string {
+	for i, name := range Environments[:len(Environments)-1] {
+		if name == env {
+			return Environments[i+1]
+		}
+	}
+	return ""
+}
+
+// ValidEnvironment reports whether env names a deployment environment.
+func ValidEnvironment(env string) bool {
+	for _, name := range Environments {
+		if name == env {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// BotReplica is one of the Replicas instances of a bot, numbered from 0,
+// and the release it should run. Runners poll their replica to learn what to run; during a
+// rolling or canary deployment replicas run different releases.
+type BotReplica struct {
+	ID             uint      `gorm:"primarykey" json:"-"`
+	BotID          uint      `gorm:"not null;uniqueIndex:idx_bot_replica" json:"bot_id"`
+	Number         int       `gorm:"not null;uniqueIndex:idx_bot_replica" json:"number"`
+	DeploymentID   *uint     `gorm:"index" json:"deployment_id"`
+	Version        string    `gorm:"type:varchar(50)" json:"version"`
+	ArtifactDigest string    `gorm:"type:varchar(71)" json:"artifact_digest,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	Bot *Bot `gorm:"foreignKey:BotID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (BotReplica) TableName() string {
+	return "bot_replicas"
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+)
+
+// Deployment outcomes.
+const (
+	DeploymentInProgress = "in_progress"
+	DeploymentSucceeded  = "succeeded"
+	DeploymentFailed     = "failed"
+	DeploymentSuperseded = "superseded"
+)
+
+// Deployment strategies. All at once updates every replica of the bot
+// together; rolling updates them in batches; canary updates a share of
+// them and compares it with the rest before updating the others.
+const (
+	StrategyAllAtOnce = "all_at_once"
+	StrategyRolling   = "rolling"
+	StrategyCanary    = "canary"
+)
+
+// Deployment records a release of a bot: the artifact, version label and
+// config revision that went out, who deployed it and how it ended.
+// SigningKeyID names the trusted key whose signature approved the
+// artifact, and RollbackOfID the earlier deployment a rollback redeployed.
+// A deployment under the health gate stays in progress until
+// HealthCheckUntil; OutcomeReason says why it failed or was superseded.
+// UpdatedReplicas counts the replicas running it so far, and BaselineID
+// is the release it replaces, which a canary is compared with. Only
+// production deployments run on replicas and pass the health gate; they
+// name the staging deployment they were promoted from in PromotedFromID.
+type Deployment struct {
+	ID               uint       `gorm:"primarykey" json:"id"`
+	OrganizationID   uint       `gorm:"not null;index" json:"organization_id"`
+	BotID            uint       `gorm:"not null;index" json:"bot_id"`
+	Environment      string     `gorm:"type:varchar(20);not null;default:'production';index" json:"environment"`
+	Version          string     `gorm:"type:varchar(50);not null" json:"version"`
+	ArtifactDigest   string     `gorm:"type:varchar(71)" json:"artifact_digest,omitempty"`
+	SigningKeyID     *uint      `gorm:"index" json:"signing_key_id,omitempty"`
+	ConfigRevision   int        `gorm:"not null" json:"config_revision"`
+	DeployerID       *uint      `gorm:"index" json:"deployer_id"`
+	RollbackOfID     *uint      `json:"rollback_of_id,omitempty"`
+	PromotedFromID   *uint      `json:"promoted_from_id,omitempty"`
+	Outcome          string     `gorm:"type:varchar(20);not null;index" json:"outcome"`
+	OutcomeReason    string     `gorm:"type:text" json:"outcome_reason,omitempty"`
+	Strategy         string     `gorm:"type:varchar(20);not null;default:'all_at_once'" json:"strategy"`
+	BatchSize        int        `gorm:"not null;default:0" json:"batch_size,omitempty"`
+	CanaryPercent    int        `gorm:"not null;default:0" json:"canary_percent,omitempty"`
+	UpdatedReplicas  int        `gorm:"not null;default:0" json:"updated_replicas"`
+	BaselineID       *uint      `json:"baseline_id,omitempty"`
+	StepStartedAt    *time.Time `json:"step_started_at,omitempty"`
+	HealthCheckUntil *time.Time `json:"health_check_until,omitempty"`
+	ProbeFailures    int        `gorm:"not null;default:0" json:"probe_failures"`
+	Message          string     `gorm:"type:text" json:"message,omitempty"`
+	StartedAt        time.Time  `gorm:"not null" json:"started_at"`
+	FinishedAt       *time.Time `json:"finished_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+
+	Bot      *Bot  `gorm:"foreignKey:BotID;constraint:OnDelete:CASCADE" json:"-"`
+	Deployer *User `gorm:"foreignKey:DeployerID;constraint:OnDelete:SET NULL" json:"deployer,omitempty"`
+}
+
+func (Deployment) TableName() string {
+	return "deployments"
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Group struct {
+	ID             uint           `gorm:"primarykey" json:"id"`
+	OrganizationID uint           `gorm:"uniqueIndex:idx_groups_org_name" json:"organization_id"`
+	Name           string         `gorm:"type:varchar(100);uniqueIndex:idx_groups_org_name;not null" json:"name"`
+	Description    string         `gorm:"type:text" json:"description"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Members []User `gorm:"many2many:group_members" json:"members,omitempty"`
+}
+
+func (Group) TableName() string {
+	return "groups"
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// Invite lets someone register with a pre-assigned role in an organization.
+// Only the SHA-256 of the invite token is stored.
+type Invite struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	OrganizationID uint       `gorm:"not null;index" json:"organization_id"`
+	Email          string     `gorm:"type:varchar(255)" json:"email,omitempty"`
+	Role           string     `gorm:"type:varchar(20);not null;check:role IN ('admin', 'viewer')" json:"role"`
+	TokenHash      string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt         *time.Time `json:"used_at,omitempty"`
+	UsedByID       *uint      `json:"used_by_id,omitempty"`
+	CreatedByID    uint       `gorm:"not null" json:"created_by_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+
+	Organization *Organization `gorm:"foreignKey:OrganizationID;constraint:OnDelete:CASCADE" json:"-"`
+	UsedBy       *User         `gorm:"foreignKey:UsedByID;constraint:OnDelete:SET NULL" json:"-"`
+	CreatedBy    *User         `gorm:"foreignKey:CreatedByID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (Invite) TableName() string {
+	return "invites"
+}
+
+func (i *Invite) Usable(now time.Time) bool {
+	return i.UsedAt == nil && now.Before(i.ExpiresAt)
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// LegalHold keeps the audit entries about a user or a bot online past the
+// retention period until it is released. Exactly one of UserID and BotID
+// is set. Holds outlive the users and bots they name, so neither is a
+// foreign key.
+type LegalHold struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	UserID       *uint      `gorm:"index" json:"user_id,omitempty"`
+	BotID        *uint      `gorm:"index" json:"bot_id,omitempty"`
+	Reason       string     `gorm:"type:text;not null" json:"reason"`
+	CreatedByID  uint       `gorm:"not null" json:"created_by_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ReleasedAt   *time.Time `json:"released_at,omitempty"`
+	ReleasedByID *uint      `json:"released_by_id,omitempty"`
+}
+
+func (LegalHold) TableName() string {
+	return "legal_holds"
+}
+
+// Active reports whether the hold still applies.
+func (h *LegalHold) Active() bool {
+	return h.ReleasedAt == nil
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	ThrottleScopeAccount = "account"
+	ThrottleScopeIP      = "ip"
+)
+
+// LoginThrottle counts recent failed logins for one account or one client
+// IP. NextAttemptAt enforces the progressive delay between attempts and
+// LockedUntil the temporary lockout once too many attempts have failed.
+type LoginThrottle struct {
+	ID            uint       `gorm:"primarykey" json:"id"`
+	Scope         string     `gorm:"type:varchar(10);not null;uniqueIndex:idx_login_throttles_scope_key" json:"scope"`
+	Key           string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_login_throttles_scope_key" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (LoginThrottle) TableName() string {
+	return "login_throttles"
+}
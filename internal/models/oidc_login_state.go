@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// OIDCLoginState tracks an authorization request between redirecting the
+// user to the identity provider and handling the callback. The PKCE
+// verifier and nonce never leave the server.
+type OIDCLoginState struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	StateHash    string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	CodeVerifier string    `gorm:"type:varchar(128);not null" json:"-"`
+	Nonce        string    `gorm:"type:varchar(64);not null" json:"-"`
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (OIDCLoginState) TableName() string {
+	return "oidc_login_states"
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultOrganizationSlug identifies the organization that existing data is
+// migrated into and that public registrations join.
+const DefaultOrganizationSlug = "default"
+
+type Organization struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	Name      string         `gorm:"type:varchar(100);not null" json:"name"`
+	Slug      string         `gorm:"type:varchar(100);uniqueIndex;not null" json:"slug"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+// OrganizationMember gives a user a role inside an organization. A user can
+// belong to several organizations with a different role in each.
+type OrganizationMember struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	OrganizationID uint      `gorm:"not null;uniqueIndex:idx_org_members_org_user" json:"organization_id"`
+	UserID         uint      `gorm:"not null;uniqueIndex:idx_org_members_org_user;index" json:"user_id"`
+	Role           string    `gorm:"type:varchar(20);not null;check:role IN ('admin', 'viewer')" json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	Organization *Organization `gorm:"foreignKey:OrganizationID;constraint:OnDelete:CASCADE" json:"organization,omitempty"`
+	User         *User         `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"user,omitempty"`
+}
+
+func (OrganizationMember) TableName() string {
+	return "organization_members"
+}
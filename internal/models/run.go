@@ -7,20 +7,27 @@ import (
 	"gorm.io/gorm"
 )
 
+// Run is one execution of a bot. Runners set DeploymentID and Replica to
+// the release and the number of the replica that ran it, which the health
+// gate uses to judge a deployment and to compare a canary with the old
+// version.
 type Run struct {
-	ID         uint           `gorm:"primarykey" json:"id"`
-	BotID      uint           `gorm:"not null;index" json:"bot_id"`
-	StartedAt  time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"started_at"`
-	FinishedAt *time.Time     `json:"finished_at"`
-	Success    *bool          `json:"success"`
-	Log        string         `gorm:"type:text" json:"log"`
-	Metrics    datatypes.JSON `gorm:"type:jsonb" json:"metrics"`
-	CreatedAt  time.Time      `json:"created_at"`
-	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uint           `gorm:"primarykey" json:"id"`
+	OrganizationID uint           `gorm:"index" json:"organization_id"`
+	BotID          uint           `gorm:"not null;index" json:"bot_id"`
+	DeploymentID   *uint          `gorm:"index" json:"deployment_id,omitempty"`
+	Replica        *int           `json:"replica,omitempty"`
+	StartedAt      time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"started_at"`
+	FinishedAt     *time.Time     `json:"finished_at"`
+	Success        *bool          `json:"success"`
+	Log            string         `gorm:"type:text" json:"log"`
+	Metrics        datatypes.JSON `gorm:"type:jsonb" json:"metrics"`
+	CreatedAt      time.Time      `json:"created_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 
 	Bot *Bot `gorm:"foreignKey:BotID;constraint:OnDelete:CASCADE" json:"bot,omitempty"`
 }
 
 func (Run) TableName() string {
 	return "runs"
-}
\ No newline at end of file
+}
@@ -8,6 +8,7 @@ import (
 
 type Schedule struct {
 	ID             uint           `gorm:"primarykey" json:"id"`
+	OrganizationID uint           `gorm:"index" json:"organization_id"`
 	BotID          uint           `gorm:"not null;index" json:"bot_id"`
 	CronExpression string         `gorm:"type:varchar(100);not null" json:"cron_expression"`
 	IsActive       bool           `gorm:"default:true" json:"is_active"`
@@ -20,4 +21,4 @@ type Schedule struct {
 
 func (Schedule) TableName() string {
 	return "schedules"
-}
\ No newline at end of file
+}
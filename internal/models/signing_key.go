@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// SigningKey is a token signing key pair. PrivateKey holds the PKCS#8 key
+// encrypted with the server secret. A key signs new tokens until RetiredAt
+// and still verifies them until ExpiresAt, so tokens issued just before a
+// rotation stay valid. After that the private key is erased, but the row
+// and PublicKey, a JWK, are kept so audit checkpoints the key signed keep
+// verifying.
+type SigningKey struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	KID        string     `gorm:"column:kid;type:varchar(64);uniqueIndex;not null" json:"kid"`
+	Algorithm  string     `gorm:"type:varchar(10);not null" json:"algorithm"`
+	PrivateKey string     `gorm:"type:text;not null" json:"-"`
+	PublicKey  string     `gorm:"type:text" json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RetiredAt  *time.Time `json:"retired_at,omitempty"`
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at,omitempty"`
+}
+
+func (SigningKey) TableName() string {
+	return "signing_keys"
+}
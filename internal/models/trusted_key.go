@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// TrustedKey is an ed25519 public key an organization trusts to sign bot
+// artifacts, typically held by its build pipeline. Revoked keys are kept so
+// past deployments still name the key that approved them.
+type TrustedKey struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	OrganizationID uint       `gorm:"not null;uniqueIndex:idx_trusted_keys_org_fingerprint" json:"organization_id"`
+	Name           string     `gorm:"type:varchar(100);not null" json:"name"`
+	PublicKey      string     `gorm:"type:text;not null" json:"public_key"`
+	Fingerprint    string     `gorm:"type:varchar(64);not null;uniqueIndex:idx_trusted_keys_org_fingerprint" json:"fingerprint"`
+	CreatedByID    *uint      `json:"created_by_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	RevokedByID    *uint      `json:"revoked_by_id,omitempty"`
+}
+
+func (TrustedKey) TableName() string {
+	return "trusted_keys"
+}
+
+// Active reports whether the key may still approve deployments.
+func (k *TrustedKey) Active() bool {
+	return k.RevokedAt == nil
+}
@@ -6,17 +6,46 @@ import (
 	"gorm.io/gorm"
 )
 
+// User is an account that can sign in. Role is the role the user was
+// created with; the role that applies to a request comes from the user's
+// OrganizationMember row for the organization the token is scoped to.
+//
+// AuthProvider records where the account was provisioned from ("local" or
+// an external identity provider) and ExternalID is its subject there.
+//
+// TokenVersion is embedded in issued tokens; bumping it revokes every token
+// issued before. MFASecret is set when TOTP enrollment starts and only
+// takes effect once a confirmed code sets MFAEnabled.
 type User struct {
-	ID           uint           `gorm:"primarykey" json:"id"`
-	Name         string         `gorm:"type:varchar(100);not null" json:"name"`
-	Email        string         `gorm:"type:varchar(255);uniqueIndex;not null" json:"email"`
-	PasswordHash string         `gorm:"type:text;not null" json:"-"`
-	Role         string         `gorm:"type:varchar(20);not null;check:role IN ('admin', 'viewer')" json:"role"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                uint           `gorm:"primarykey" json:"id"`
+	Name              string         `gorm:"type:varchar(100);not null" json:"name"`
+	Email             string         `gorm:"type:varchar(255);uniqueIndex;not null" json:"email"`
+	PasswordHash      string         `gorm:"type:text;not null" json:"-"`
+	Role              string         `gorm:"type:varchar(20);not null;check:role IN ('admin', 'viewer')" json:"role"`
+	IsSuperAdmin      bool           `gorm:"default:false" json:"is_super_admin"`
+	IsActive          bool           `gorm:"default:true;not null" json:"is_active"`
+	DeactivatedAt     *time.Time     `json:"deactivated_at,omitempty"`
+	TokenVersion      int            `gorm:"not null;default:0" json:"-"`
+	PasswordChangedAt *time.Time     `json:"password_changed_at,omitempty"`
+	MFASecret         string         `gorm:"type:varchar(64)" json:"-"`
+	MFAEnabled        bool           `gorm:"default:false;not null" json:"mfa_enabled"`
+	MFAEnrolledAt     *time.Time     `json:"mfa_enrolled_at,omitempty"`
+	MFALastUsedStep   int64          `gorm:"not null;default:0" json:"-"`
+	AuthProvider      string         `gorm:"type:varchar(20);not null;default:'local';uniqueIndex:idx_users_provider_external,priority:1" json:"auth_provider"`
+	ExternalID        *string        `gorm:"type:varchar(255);uniqueIndex:idx_users_provider_external,priority:2" json:"-"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Memberships []OrganizationMember `gorm:"foreignKey:UserID" json:"memberships,omitempty"`
 }
 
+const (
+	AuthProviderLocal = "local"
+	AuthProviderOIDC  = "oidc"
+	AuthProviderLDAP  = "ldap"
+)
+
 func (User) TableName() string {
 	return "users"
-}
\ No newline at end of file
+}
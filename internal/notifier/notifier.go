@@ -0,0 +1,34 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+)
+
+// Message is a plain-text notification addressed to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to users, for example password reset links.
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the notifier selected by cfg.Driver.
+func New(cfg config.NotifierConfig) (Notifier, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTPNotifier(cfg), nil
+	case "file":
+		return NewFileNotifier(cfg.FilePath), nil
+	case "log", "":
+		return NewLogNotifier(), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier driver %q", cfg.Driver)
+	}
+}
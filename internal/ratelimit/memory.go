@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often expired counters are dropped from memory.
+const sweepInterval = time.Minute
+
+type memoryCounter struct {
+	count   int64
+	resetAt time.Time
+}
+
+// MemoryStore keeps counters in process memory. Each replica enforces its
+// own budget, so use the Redis store when running more than one.
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]*memoryCounter
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters:  make(map[string]*memoryCounter),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *MemoryStore) Increment(_ context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		for k, counter := range s.counters {
+			if !now.Before(counter.resetAt) {
+				delete(s.counters, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	counter, ok := s.counters[key]
+	if !ok || !now.Before(counter.resetAt) {
+		counter = &memoryCounter{resetAt: now.Add(window)}
+		s.counters[key] = counter
+	}
+	counter.count++
+
+	return counter.count, counter.resetAt.Sub(now), nil
+}
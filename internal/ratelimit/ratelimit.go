@@ -0,0 +1,84 @@
+// Package ratelimit counts requests per key in fixed time windows. Counts
+// live in memory for single-instance deployments or in Redis so that all
+// replicas share the same budget.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+)
+
+// Store drivers.
+const (
+	StoreMemory = "memory"
+	StoreRedis  = "redis"
+)
+
+// Policy is a request budget: Limit requests per Window. Name separates
+// the counters of different policies for the same client.
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// Result describes the state of a counter after a request was counted.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	ResetIn   time.Duration
+}
+
+// Store counts hits per key.
+type Store interface {
+	// Increment counts one hit for key and returns the total in the
+	// current window and the time until the window resets.
+	Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error)
+}
+
+// Take counts one request from client against policy.
+func Take(ctx context.Context, store Store, policy Policy, client string) (Result, error) {
+	key := fmt.Sprintf("ratelimit:%s:%s", policy.Name, client)
+
+	count, resetIn, err := store.Increment(ctx, key, policy.Window)
+	if err != nil {
+		return Result{Allowed: true, Limit: policy.Limit, Remaining: policy.Limit}, err
+	}
+
+	remaining := policy.Limit - int(count)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return Result{
+		Allowed:   count <= int64(policy.Limit),
+		Limit:     policy.Limit,
+		Remaining: remaining,
+		ResetIn:   resetIn,
+	}, nil
+}
+
+// New returns the store selected by cfg.Store.
+func New(cfg config.RateLimitConfig, redisCfg config.RedisConfig) (Store, error) {
+	switch cfg.Store {
+	case StoreMemory, "":
+		return NewMemoryStore(), nil
+	case StoreRedis:
+		return NewRedisStore(redisCfg)
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", cfg.Store)
+	}
+}
+
+// NewPolicy builds a policy from a configured rule.
+func NewPolicy(name string, rule config.RateLimitRule) Policy {
+	return Policy{
+		Name:   name,
+		Limit:  rule.Requests,
+		Window: time.Duration(rule.WindowSeconds) * time.Second,
+	}
+}
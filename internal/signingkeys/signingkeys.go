@@ -0,0 +1,266 @@
+// Package signingkeys keeps the token signing keys in the database and
+// rotates them on a schedule. Every server instance loads the same keys,
+// so a token signed by one instance verifies on all of them.
+package signingkeys
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/FRFebi/bot-management-backend/internal/config"
+	"github.com/FRFebi/bot-management-backend/internal/database"
+	"github.com/FRFebi/bot-management-backend/internal/models"
+	"github.com/FRFebi/bot-management-backend/pkg/auth"
+	"github.com/FRFebi/bot-management-backend/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// rotationLockID serializes rotation across server instances.
+const rotationLockID = 0x6b657973
+
+// checkInterval is how often the background loop reloads keys and checks
+// whether the current one is due for rotation.
+const checkInterval = time.Minute
+
+// refreshInterval is the shortest time between two reloads triggered by a
+// token signed with a key this instance has not loaded yet.
+const refreshInterval = 10 * time.Second
+
+type Manager struct {
+	cfg  config.JWTConfig
+	keys *auth.KeySet
+	aead cipher.AEAD
+	log  *logger.Logger
+}
+
+func New(cfg config.JWTConfig) (*Manager, error) {
+	sum := sha256.Sum256([]byte(cfg.SecretKey))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		cfg:  cfg,
+		keys: auth.NewKeySet(nil),
+		aead: aead,
+		log:  logger.New(),
+	}
+	m.keys.SetRefresh(m.Load, refreshInterval)
+	return m, nil
+}
+
+// KeySet returns the in-memory key set kept up to date by the manager.
+func (m *Manager) KeySet() *auth.KeySet {
+	return m.keys
+}
+
+// Start makes sure a signing key exists, then keeps rotating and
+// reloading keys in the background.
+func (m *Manager) Start() error {
+	if err := m.Rotate(false); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := m.Rotate(false); err != nil {
+				m.log.Errorf("Failed to rotate signing keys: %v", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Rotate generates a new signing key when there is none, when the current
+// one is older than the rotation period or uses a different algorithm than
+// configured, or when force is set. The replaced key is retired but keeps
+// verifying tokens for the overlap period. Keys past their overlap lose
+// their private half; the public half stays to verify audit checkpoints.
+// The key set is reloaded either way.
+func (m *Manager) Rotate(force bool) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", rotationLockID).Error; err != nil {
+			return err
+		}
+
+		if err := m.recordPublicKeys(tx); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		if err := tx.Model(&models.SigningKey{}).
+			Where("expires_at IS NOT NULL AND expires_at < ? AND private_key <> ''", now).
+			Update("private_key", "").Error; err != nil {
+			return err
+		}
+
+		var current models.SigningKey
+		err := tx.Where("retired_at IS NULL").Order("created_at DESC").First(&current).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		hasCurrent := err == nil
+
+		rotation := time.Duration(m.cfg.RotationDays) * 24 * time.Hour
+		due := !hasCurrent ||
+			current.Algorithm != m.cfg.Algorithm ||
+			(rotation > 0 && now.Sub(current.CreatedAt) >= rotation)
+		if !force && !due {
+			return nil
+		}
+
+		key, err := auth.GenerateSigningKey(m.cfg.Algorithm, m.cfg.RSAKeyBits)
+		if err != nil {
+			return err
+		}
+		sealed, err := m.seal(key.PrivateKey)
+		if err != nil {
+			return err
+		}
+
+		if hasCurrent {
+			expiresAt := now.Add(time.Duration(m.cfg.OverlapHours) * time.Hour)
+			if err := tx.Model(&models.SigningKey{}).
+				Where("retired_at IS NULL").
+				Updates(map[string]interface{}{"retired_at": now, "expires_at": expiresAt}).Error; err != nil {
+				return err
+			}
+		}
+
+		public, err := json.Marshal(key.JWK())
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Create(&models.SigningKey{
+			KID:        key.ID,
+			Algorithm:  key.Algorithm,
+			PrivateKey: sealed,
+			PublicKey:  string(public),
+			CreatedAt:  key.CreatedAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		m.log.Infof("Rotated token signing key, new kid %s (%s)", key.ID, key.Algorithm)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return m.Load()
+}
+
+// recordPublicKeys stores the public half of keys created before it was
+// kept, while their private half is still there to derive it from.
+func (m *Manager) recordPublicKeys(tx *gorm.DB) error {
+	var rows []models.SigningKey
+	if err := tx.Where("COALESCE(public_key, '') = '' AND private_key <> ''").Find(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		private, err := m.open(row.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", row.KID, err)
+		}
+		key := auth.SigningKey{ID: row.KID, Algorithm: row.Algorithm, PrivateKey: private}
+		public, err := json.Marshal(key.JWK())
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&row).Update("public_key", string(public)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load replaces the in-memory key set with the keys stored in the
+// database. Expired keys, which only have their public half left, are not
+// loaded.
+func (m *Manager) Load() error {
+	var rows []models.SigningKey
+	if err := database.DB.Where("private_key <> ''").Find(&rows).Error; err != nil {
+		return err
+	}
+
+	keys := make([]*auth.SigningKey, 0, len(rows))
+	for _, row := range rows {
+		private, err := m.open(row.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", row.KID, err)
+		}
+
+		keys = append(keys, &auth.SigningKey{
+			ID:         row.KID,
+			Algorithm:  row.Algorithm,
+			PrivateKey: private,
+			CreatedAt:  row.CreatedAt,
+			RetiredAt:  row.RetiredAt,
+			ExpiresAt:  row.ExpiresAt,
+		})
+	}
+
+	m.keys.Replace(keys)
+	return nil
+}
+
+func (m *Manager) seal(key crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, m.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(m.aead.Seal(nonce, nonce, der, nil)), nil
+}
+
+func (m *Manager) open(sealed string) (crypto.Signer, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < m.aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := data[:m.aead.NonceSize()], data[m.aead.NonceSize():]
+	der, err := m.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("cannot decrypt private key, was JWT_SECRET changed?")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	return signer, nil
+}
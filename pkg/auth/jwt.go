@@ -2,52 +2,119 @@ package auth
 
 import (
 	"errors"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID         uint   `json:"user_id"`
+	Email          string `json:"email"`
+	Role           string `json:"role"`
+	OrganizationID uint   `json:"org_id"`
+	SuperAdmin     bool   `json:"super_admin,omitempty"`
+	TokenVersion   int    `json:"tv"`
+	Purpose        string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Token purposes. Access tokens have no purpose; tokens issued for an
+// intermediate login step carry one and are only accepted by the endpoints
+// completing that step.
+const (
+	PurposeMFAChallenge  = "mfa_challenge"
+	PurposeMFAEnrollment = "mfa_enrollment"
+)
+
+// Identity describes who a token is issued to. Role is the user's role in
+// OrganizationID, the organization the token is scoped to.
+type Identity struct {
+	UserID         uint
+	Email          string
+	Role           string
+	OrganizationID uint
+	SuperAdmin     bool
+	TokenVersion   int
+}
+
+// JWTManager issues and validates tokens signed with the keys in a KeySet.
+// Issued tokens carry the signing key's ID in the "kid" header and the
+// configured issuer and audience. ValidateToken enforces the issuer and
+// accepts tokens for any of the configured audiences.
 type JWTManager struct {
-	secretKey   string
+	keys        *KeySet
+	issuer      string
+	audience    []string
 	expiryHours int
 }
 
-func NewJWTManager(secretKey string, expiryHours int) *JWTManager {
+func NewJWTManager(keys *KeySet, issuer string, audience []string, expiryHours int) *JWTManager {
 	return &JWTManager{
-		secretKey:   secretKey,
+		keys:        keys,
+		issuer:      issuer,
+		audience:    audience,
 		expiryHours: expiryHours,
 	}
 }
 
-func (j *JWTManager) GenerateToken(userID uint, email, role string) (string, error) {
+func (j *JWTManager) GenerateToken(identity Identity) (string, error) {
+	return j.generate(identity, "", time.Duration(j.expiryHours)*time.Hour)
+}
+
+// GeneratePurposeToken issues a short-lived token for an intermediate login
+// step.
+func (j *JWTManager) GeneratePurposeToken(identity Identity, purpose string, ttl time.Duration) (string, error) {
+	return j.generate(identity, purpose, ttl)
+}
+
+func (j *JWTManager) generate(identity Identity, purpose string, ttl time.Duration) (string, error) {
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
+		UserID:         identity.UserID,
+		Email:          identity.Email,
+		Role:           identity.Role,
+		OrganizationID: identity.OrganizationID,
+		SuperAdmin:     identity.SuperAdmin,
+		TokenVersion:   identity.TokenVersion,
+		Purpose:        purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(j.expiryHours) * time.Hour)),
+			Issuer:    j.issuer,
+			Subject:   strconv.FormatUint(uint64(identity.UserID), 10),
+			Audience:  j.audience,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.secretKey))
+	key, err := j.keys.Signing()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
 }
 
 func (j *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{AlgorithmRS256, AlgorithmEdDSA}),
+		jwt.WithIssuer(j.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(j.audience...),
+	}
+
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := j.keys.Verification(kid)
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		if token.Method.Alg() != key.Algorithm {
 			return nil, errors.New("unexpected signing method")
 		}
-		return []byte(j.secretKey), nil
-	})
+		return key.PrivateKey.Public(), nil
+	}, options...)
 
 	if err != nil {
 		return nil, err
@@ -66,5 +133,20 @@ func (j *JWTManager) RefreshToken(tokenString string) (string, error) {
 		return "", err
 	}
 
-	return j.GenerateToken(claims.UserID, claims.Email, claims.Role)
-}
\ No newline at end of file
+	if claims.Purpose != "" {
+		return "", errors.New("only access tokens can be refreshed")
+	}
+
+	return j.GenerateToken(claims.Identity())
+}
+
+func (c *Claims) Identity() Identity {
+	return Identity{
+		UserID:         c.UserID,
+		Email:          c.Email,
+		Role:           c.Role,
+		OrganizationID: c.OrganizationID,
+		SuperAdmin:     c.SuperAdmin,
+		TokenVersion:   c.TokenVersion,
+	}
+}
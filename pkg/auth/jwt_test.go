@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestManager(t *testing.T, keys *KeySet, audience ...string) *JWTManager {
+	t.Helper()
+	return NewJWTManager(keys, "bot-management", audience, 1)
+}
+
+func TestValidateTokenAudience(t *testing.T) {
+	key, err := GenerateSigningKey(AlgorithmEdDSA, 0)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	keys := NewKeySet([]*SigningKey{key})
+	validator := newTestManager(t, keys, "api", "console")
+
+	tests := []struct {
+		name     string
+		audience []string
+		valid    bool
+	}{
+		{"first audience", []string{"api"}, true},
+		{"second audience", []string{"console"}, true},
+		{"all audiences", []string{"api", "console"}, true},
+		{"one of several", []string{"reports", "console"}, true},
+		{"other audience", []string{"reports"}, false},
+		{"no audience", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := newTestManager(t, keys, tt.audience...).GenerateToken(Identity{UserID: 1, Email: "user@example.com"})
+			if err != nil {
+				t.Fatalf("generate token: %v", err)
+			}
+
+			_, err = validator.ValidateToken(token)
+			if tt.valid && err != nil {
+				t.Errorf("expected token for %v to be accepted, got %v", tt.audience, err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("expected token for %v to be rejected", tt.audience)
+			}
+		})
+	}
+}
+
+func TestValidateTokenIssuer(t *testing.T) {
+	key, err := GenerateSigningKey(AlgorithmEdDSA, 0)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	keys := NewKeySet([]*SigningKey{key})
+
+	token, err := NewJWTManager(keys, "someone-else", []string{"api"}, 1).GenerateToken(Identity{UserID: 1})
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+
+	if _, err := newTestManager(t, keys, "api").ValidateToken(token); err == nil {
+		t.Error("expected token from another issuer to be rejected")
+	}
+}
+
+func TestValidateTokenRefreshesUnknownKey(t *testing.T) {
+	oldKey, newKey := mustGenerateKey(t), mustGenerateKey(t)
+
+	// Another instance has rotated to newKey and signs with it.
+	token, err := newTestManager(t, NewKeySet([]*SigningKey{newKey}), "api").GenerateToken(Identity{UserID: 1})
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+
+	keys := NewKeySet([]*SigningKey{oldKey})
+	refreshes := 0
+	keys.SetRefresh(func() error {
+		refreshes++
+		keys.Replace([]*SigningKey{oldKey, newKey})
+		return nil
+	}, time.Hour)
+	validator := newTestManager(t, keys, "api")
+
+	if _, err := validator.ValidateToken(token); err != nil {
+		t.Fatalf("expected token signed with a newly rotated key to be accepted, got %v", err)
+	}
+
+	forged, err := newTestManager(t, NewKeySet([]*SigningKey{mustGenerateKey(t)}), "api").GenerateToken(Identity{UserID: 1})
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := validator.ValidateToken(forged); err == nil {
+			t.Fatal("expected token signed with an unknown key to be rejected")
+		}
+	}
+	if refreshes != 1 {
+		t.Errorf("expected unknown keys to reload the key set once per interval, got %d reloads", refreshes)
+	}
+}
+
+func mustGenerateKey(t *testing.T) *SigningKey {
+	t.Helper()
+	key, err := GenerateSigningKey(AlgorithmEdDSA, 0)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
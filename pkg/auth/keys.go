@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms.
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// SigningKey is an asymmetric key used to sign tokens, identified in token
+// headers by ID (the "kid"). A key signs new tokens until RetiredAt and
+// keeps verifying them until ExpiresAt.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+	CreatedAt  time.Time
+	RetiredAt  *time.Time
+	ExpiresAt  *time.Time
+}
+
+// GenerateSigningKey creates a new key for algorithm. rsaBits is only used
+// for RS256.
+func GenerateSigningKey(algorithm string, rsaBits int) (*SigningKey, error) {
+	var private crypto.Signer
+	switch algorithm {
+	case AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaBits)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	case AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{
+		ID:         hex.EncodeToString(id),
+		Algorithm:  algorithm,
+		PrivateKey: private,
+		CreatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	if k.Algorithm == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// JWK is a public key in JSON Web Key format.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set as served from /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k *SigningKey) JWK() JWK {
+	jwk := JWK{
+		KeyID:     k.ID,
+		Use:       "sig",
+		Algorithm: k.Algorithm,
+	}
+
+	switch public := k.PrivateKey.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+
+	return jwk
+}
+
+// KeySet holds the keys currently known to this process. It is safe for
+// concurrent use and can be swapped wholesale when keys are rotated or
+// reloaded from storage.
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []*SigningKey
+
+	refreshMu       sync.Mutex
+	refresh         func() error
+	refreshInterval time.Duration
+	refreshedAt     time.Time
+}
+
+func NewKeySet(keys []*SigningKey) *KeySet {
+	s := &KeySet{}
+	s.Replace(keys)
+	return s
+}
+
+// Replace swaps in a new list of keys.
+func (s *KeySet) Replace(keys []*SigningKey) {
+	sorted := append([]*SigningKey(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	s.mu.Lock()
+	s.keys = sorted
+	s.mu.Unlock()
+}
+
+// Signing returns the newest key that has not been retired.
+func (s *KeySet) Signing() (*SigningKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.keys {
+		if key.RetiredAt == nil {
+			return key, nil
+		}
+	}
+	return nil, errors.New("no active signing key")
+}
+
+// SetRefresh makes Verification call refresh, at most once per interval,
+// when asked for a key it does not know. Another instance may have rotated
+// in a new key and signed with it before this one reloaded its keys.
+func (s *KeySet) SetRefresh(refresh func() error, interval time.Duration) {
+	s.refreshMu.Lock()
+	s.refresh = refresh
+	s.refreshInterval = interval
+	s.refreshMu.Unlock()
+}
+
+// Verification returns the key with the given ID if it may still be used
+// to verify tokens.
+func (s *KeySet) Verification(kid string) (*SigningKey, bool) {
+	key := s.lookup(kid)
+	if key == nil {
+		s.refreshUnknown()
+		key = s.lookup(kid)
+	}
+
+	if key == nil || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+		return nil, false
+	}
+	return key, true
+}
+
+func (s *KeySet) lookup(kid string) *SigningKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.keys {
+		if key.ID == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// refreshUnknown reloads the keys unless that was done within the refresh
+// interval, so tokens with made-up key IDs cannot hammer the key storage.
+func (s *KeySet) refreshUnknown() {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	if s.refresh == nil || time.Since(s.refreshedAt) < s.refreshInterval {
+		return
+	}
+	s.refreshedAt = time.Now()
+	s.refresh()
+}
+
+// JWKS returns the public halves of every key that can still verify tokens.
+func (s *KeySet) JWKS() JWKS {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range s.keys {
+		if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, key.JWK())
+	}
+	return jwks
+}
+
+// Sign signs claims as a compact JWS with the current signing key, for
+// documents other than access tokens.
+func (s *KeySet) Sign(claims jwt.Claims) (string, *SigningKey, error) {
+	key, err := s.Signing()
+	if err != nil {
+		return "", nil, err
+	}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, key, nil
+}
+
+// PublicKey decodes the key described by the JWK.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if k.Curve != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("unsupported OKP key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+}
@@ -0,0 +1,177 @@
+// Package jsonpatch computes RFC 6902 JSON Patch documents that turn one
+// JSON document into another, and applies RFC 7396 JSON merge patches.
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operation is a single JSON Patch operation. Diff only produces add,
+// remove and replace.
+type Operation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON leaves out the value of remove operations. Add and replace
+// keep theirs even when it is null.
+func (o Operation) MarshalJSON() ([]byte, error) {
+	if o.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{o.Op, o.Path})
+	}
+
+	type operation Operation
+	return json.Marshal(operation(o))
+}
+
+// Diff returns the operations that turn from into to. Empty input is
+// treated as null. Object members are visited in key order so the result
+// is deterministic; arrays are compared index by index, with elements
+// added or removed at the end.
+func Diff(from, to []byte) ([]Operation, error) {
+	a, err := decode(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := decode(to)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := []Operation{}
+	diff(&ops, "", a, b)
+	return ops, nil
+}
+
+// MergePatch applies the JSON merge patch patch to doc: members of an
+// object patch replace those of doc recursively, null members remove
+// them, and any other patch replaces doc entirely. An empty patch leaves
+// doc unchanged.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(patch)) == 0 {
+		return doc, nil
+	}
+
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := decode(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(merge(target, changes))
+}
+
+func merge(target, patch interface{}) interface{} {
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	result, ok := target.(map[string]interface{})
+	if !ok {
+		result = map[string]interface{}{}
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(result, key)
+		} else {
+			result[key] = merge(result[key], value)
+		}
+	}
+	return result
+}
+
+func decode(data []byte) (interface{}, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func diff(ops *[]Operation, path string, a, b interface{}) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		if bv, ok := b.(map[string]interface{}); ok {
+			diffObjects(ops, path, av, bv)
+			return
+		}
+	case []interface{}:
+		if bv, ok := b.([]interface{}); ok {
+			diffArrays(ops, path, av, bv)
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*ops = append(*ops, Operation{Op: "replace", Path: path, Value: b})
+	}
+}
+
+func diffObjects(ops *[]Operation, path string, a, b map[string]interface{}) {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		child := path + "/" + escape(key)
+		av, inA := a[key]
+		bv, inB := b[key]
+
+		switch {
+		case !inB:
+			*ops = append(*ops, Operation{Op: "remove", Path: child})
+		case !inA:
+			*ops = append(*ops, Operation{Op: "add", Path: child, Value: bv})
+		default:
+			diff(ops, child, av, bv)
+		}
+	}
+}
+
+func diffArrays(ops *[]Operation, path string, a, b []interface{}) {
+	common := min(len(a), len(b))
+	for i := 0; i < common; i++ {
+		diff(ops, path+"/"+strconv.Itoa(i), a[i], b[i])
+	}
+
+	for i := common; i < len(b); i++ {
+		*ops = append(*ops, Operation{Op: "add", Path: path + "/" + strconv.Itoa(i), Value: b[i]})
+	}
+
+	// Remove from the end so earlier indexes stay valid.
+	for i := len(a) - 1; i >= common; i-- {
+		*ops = append(*ops, Operation{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+	}
+}
+
+// escape encodes a member name as a JSON Pointer reference token.
+func escape(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
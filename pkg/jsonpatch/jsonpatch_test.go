@@ -0,0 +1,118 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     string
+	}{
+		{"equal", `{"a":1,"b":[1,2]}`, `{"b":[1,2],"a":1}`, `[]`},
+		{"empty input is null", ``, `null`, `[]`},
+		{"add member", `{"a":1}`, `{"a":1,"b":null}`, `[{"op":"add","path":"/b","value":null}]`},
+		{"remove member", `{"a":1,"b":2}`, `{"a":1}`, `[{"op":"remove","path":"/b"}]`},
+		{"replace member", `{"a":1}`, `{"a":"1"}`, `[{"op":"replace","path":"/a","value":"1"}]`},
+		{"nested member", `{"a":{"b":1}}`, `{"a":{"b":2}}`, `[{"op":"replace","path":"/a/b","value":2}]`},
+		{"members in key order", `{"c":1,"a":1}`, `{"b":1}`, `[{"op":"remove","path":"/a"},{"op":"add","path":"/b","value":1},{"op":"remove","path":"/c"}]`},
+		{"numbers as written", `{"a":1.0}`, `{"a":1}`, `[{"op":"replace","path":"/a","value":1}]`},
+		{"replace element", `[1,2,3]`, `[1,5,3]`, `[{"op":"replace","path":"/1","value":5}]`},
+		{"append elements", `[1]`, `[1,2,3]`, `[{"op":"add","path":"/1","value":2},{"op":"add","path":"/2","value":3}]`},
+		{"remove elements from the end", `[1,2,3]`, `[1]`, `[{"op":"remove","path":"/2"},{"op":"remove","path":"/1"}]`},
+		{"object becomes array", `{"a":{"b":1}}`, `{"a":[1]}`, `[{"op":"replace","path":"/a","value":[1]}]`},
+		{"replace document", `{"a":1}`, `[1]`, `[{"op":"replace","path":"","value":[1]}]`},
+		{"escaped member", `{"a/b":1,"c~d":1}`, `{}`, `[{"op":"remove","path":"/a~1b"},{"op":"remove","path":"/c~0d"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops, err := Diff([]byte(tt.from), []byte(tt.to))
+			if err != nil {
+				t.Fatalf("diff: %v", err)
+			}
+			got, err := json.Marshal(ops)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDiffInvalidJSON(t *testing.T) {
+	if _, err := Diff([]byte(`{"a":`), []byte(`{}`)); err == nil {
+		t.Error("expected invalid from to be rejected")
+	}
+	if _, err := Diff([]byte(`{}`), []byte(`[1,`)); err == nil {
+		t.Error("expected invalid to to be rejected")
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		doc, patch string
+		want       string
+	}{
+		{"empty patch", `{"a":1}`, ``, `{"a":1}`},
+		{"replace member", `{"a":1,"b":2}`, `{"a":3}`, `{"a":3,"b":2}`},
+		{"add member", `{"a":1}`, `{"b":{"c":2}}`, `{"a":1,"b":{"c":2}}`},
+		{"null removes member", `{"a":1,"b":2}`, `{"b":null}`, `{"a":1}`},
+		{"null for missing member", `{"a":1}`, `{"b":null}`, `{"a":1}`},
+		{"nested merge", `{"a":{"b":1,"c":2}}`, `{"a":{"c":null,"d":3}}`, `{"a":{"b":1,"d":3}}`},
+		{"array replaced whole", `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{"object patch on scalar", `{"a":1}`, `{"a":{"b":1}}`, `{"a":{"b":1}}`},
+		{"object patch on empty document", ``, `{"a":1}`, `{"a":1}`},
+		{"non-object patch replaces document", `{"a":1}`, `[1]`, `[1]`},
+		{"null patch", `{"a":1}`, `null`, `null`},
+		{"numbers as written", `{"a":1.50}`, `{"b":2.0}`, `{"a":1.50,"b":2.0}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("merge patch: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMergePatchInvalidJSON(t *testing.T) {
+	if _, err := MergePatch([]byte(`{"a":`), []byte(`{}`)); err == nil {
+		t.Error("expected invalid document to be rejected")
+	}
+	if _, err := MergePatch([]byte(`{}`), []byte(`{"a":`)); err == nil {
+		t.Error("expected invalid patch to be rejected")
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"plain", "plain"},
+		{"", ""},
+		{"a/b", "a~1b"},
+		{"a~b", "a~0b"},
+		{"~1", "~01"},
+		{"/~", "~1~0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := escape(tt.key); got != tt.want {
+				t.Errorf("escape(%q): expected %q, got %q", tt.key, tt.want, got)
+			}
+		})
+	}
+}
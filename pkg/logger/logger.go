@@ -49,4 +49,4 @@ func (l *Logger) Fatal(v ...interface{}) {
 
 func (l *Logger) Fatalf(format string, v ...interface{}) {
 	l.error.Fatalf(format, v...)
-}
\ No newline at end of file
+}